@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// cacheEndpoints lists the endpoint names accepted in -cache-ttl overrides.
+var cacheEndpoints = []string{
+	jdw.EndpointVenues,
+	jdw.EndpointVenueDetails,
+	jdw.EndpointMenus,
+	jdw.EndpointMenuItems,
+	jdw.EndpointSettings,
+	jdw.EndpointBanners,
+}
+
+// parseCacheTTL parses a -cache-ttl value. It accepts a comma-separated list
+// of durations where a bare duration sets the default TTL and endpoint=duration
+// overrides it, e.g. "1h,menu_items=6h,venues=24h".
+func parseCacheTTL(value string) (jdw.CacheOptions, error) {
+	var opts jdw.CacheOptions
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		endpoint, durStr, hasEndpoint := strings.Cut(part, "=")
+		if !hasEndpoint {
+			durStr = endpoint
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(durStr))
+		if err != nil {
+			return opts, fmt.Errorf("invalid cache TTL %q: %w", part, err)
+		}
+
+		if !hasEndpoint {
+			opts.DefaultTTL = d
+			continue
+		}
+		endpoint = strings.TrimSpace(endpoint)
+		if !isCacheEndpoint(endpoint) {
+			return opts, fmt.Errorf("unknown cache endpoint %q (valid: %s)", endpoint, strings.Join(cacheEndpoints, ", "))
+		}
+		if opts.TTLs == nil {
+			opts.TTLs = make(map[string]time.Duration)
+		}
+		opts.TTLs[endpoint] = d
+	}
+	return opts, nil
+}
+
+func isCacheEndpoint(name string) bool {
+	for _, e := range cacheEndpoints {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// configureCache enables the on-disk cache on client when cacheDir is set.
+func configureCache(client *jdw.Client, cacheDir, ttl string, offline bool) error {
+	if cacheDir == "" {
+		if offline {
+			return fmt.Errorf("-offline requires -cache-dir")
+		}
+		return nil
+	}
+
+	opts, err := parseCacheTTL(ttl)
+	if err != nil {
+		return err
+	}
+	opts.Offline = offline
+
+	cache, err := jdw.NewDiskCache(cacheDir)
+	if err != nil {
+		return err
+	}
+	client.SetCache(cache, opts)
+	return nil
+}
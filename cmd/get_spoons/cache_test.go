@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+func TestParseCacheTTL(t *testing.T) {
+	opts, err := parseCacheTTL("2h, menu_items=6h,venues=24h")
+	if err != nil {
+		t.Fatalf("parseCacheTTL failed: %v", err)
+	}
+	if opts.DefaultTTL != 2*time.Hour {
+		t.Errorf("Expected default TTL 2h, got %v", opts.DefaultTTL)
+	}
+	if opts.TTLs[jdw.EndpointMenuItems] != 6*time.Hour || opts.TTLs[jdw.EndpointVenues] != 24*time.Hour {
+		t.Errorf("Unexpected endpoint TTLs: %v", opts.TTLs)
+	}
+
+	for _, bad := range []string{"soon", "menus=forever", "unknown=1h"} {
+		if _, err := parseCacheTTL(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestRunCache(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, `{"success": true, "data": [{"id": 1, "venueRef": 10, "name": "Cached Pub"}]}`)
+	}))
+	defer server.Close()
+
+	os.Setenv("JDW_API_URL", server.URL)
+	defer os.Unsetenv("JDW_API_URL")
+	os.Setenv("JDW_TOKEN", "test-token")
+	defer os.Unsetenv("JDW_TOKEN")
+
+	cacheDir := t.TempDir()
+	if err := Run([]string{"-cache-dir", cacheDir, "-output", cacheDir + "/out.json"}); err != nil {
+		t.Fatalf("Run with cache failed: %v", err)
+	}
+	server.Close()
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := Run([]string{"-cache-dir", cacheDir, "-offline"})
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	if err != nil {
+		t.Fatalf("Run -offline failed: %v", err)
+	}
+	out, _ := io.ReadAll(r)
+	if !strings.Contains(string(out), "Cached Pub") {
+		t.Errorf("Expected cached venue in offline output, got %s", string(out))
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected a single request to the server, got %d", got)
+	}
+
+	if err := Run([]string{"-offline"}); err == nil {
+		t.Error("Expected error for -offline without -cache-dir")
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// clientFlags holds the flags shared by every command that talks to the JDW API.
+type clientFlags struct {
+	configPath   string
+	profile      string
+	apiURL       string
+	appVersion   string
+	versionCheck string
+	token        string
+	tokenFile    string
+	tokenCmd     string
+	userAgent    string
+	debug        bool
+	logFormat    string
+	logLevel     string
+	trace        string
+	cacheDir     string
+	cacheTTL     string
+	offline      bool
+	recordDir    string
+	replayDir    string
+	timeout      time.Duration
+	validate     bool
+
+	// eff is set by parse and records where each flag's value came from.
+	eff *effectiveConfig
+}
+
+// register adds the client flags to fs. Defaults can be overridden by
+// environment variables and config profiles; see parseFlags.
+func (c *clientFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.configPath, "config", "", "Config file (env JDW_CONFIG, default $XDG_CONFIG_HOME/get_spoons/config.yaml)")
+	fs.StringVar(&c.profile, "profile", "", "Config profile to use (env JDW_PROFILE)")
+	fs.StringVar(&c.apiURL, "api-url", jdw.DefaultBaseURL, "JDW API base URL (env JDW_API_URL)")
+	fs.StringVar(&c.appVersion, "app-version", "6.7.1", "JDW App Version (env JDW_APP_VERSION)")
+	fs.StringVar(&c.versionCheck, "version-check", versionCheckOff, "Compare -app-version with the API's minimum version: off, warn or bump")
+	fs.StringVar(&c.token, "token", "", "JDW Bearer Token (env JDW_TOKEN)")
+	fs.StringVar(&c.tokenFile, "token-file", "", "Read the JDW Bearer Token from this file ('-' for stdin)")
+	fs.StringVar(&c.tokenCmd, "token-cmd", "", "Read the JDW Bearer Token from the output of this command (e.g. 'pass show jdw')")
+	fs.StringVar(&c.userAgent, "user-agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 18_7 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148", "User Agent (env JDW_USER_AGENT)")
+	fs.BoolVar(&c.debug, "debug", false, "Enable debug logging (same as -log-level debug)")
+	fs.StringVar(&c.logFormat, "log-format", logFormatText, "Log format on stderr: text or json (env JDW_LOG_FORMAT)")
+	fs.StringVar(&c.logLevel, "log-level", "info", "Log level: debug, info, warn or error (env JDW_LOG_LEVEL)")
+	fs.StringVar(&c.trace, "trace", traceNone, "Export OpenTelemetry traces: none, otlp or stdout (env OTEL_TRACES_EXPORTER)")
+	fs.StringVar(&c.cacheDir, "cache-dir", "", "Cache API responses in this directory (env JDW_CACHE_DIR)")
+	fs.StringVar(&c.cacheTTL, "cache-ttl", "1h", "Cache TTL, optionally per endpoint (e.g. '1h,menu_items=6h,venues=24h')")
+	fs.BoolVar(&c.offline, "offline", false, "Serve responses only from the cache (requires -cache-dir)")
+	fs.StringVar(&c.recordDir, "record", "", "Record API requests and responses as fixtures in this directory (Authorization is scrubbed)")
+	fs.StringVar(&c.replayDir, "replay", "", "Serve API responses from fixtures in this directory instead of the network")
+	fs.DurationVar(&c.timeout, "timeout", 0, "Time limit for each API request (e.g. '30s'; 0 for none)")
+	fs.BoolVar(&c.validate, "validate-schema", false, "Warn when API responses differ from the bundled OpenAPI spec")
+}
+
+// parse parses args into fs, applying environment variables and the config
+// profile (see parseFlags), installs the logger selected by -log-format and
+// -log-level as the slog default and sets up tracing for -trace.
+func (c *clientFlags) parse(fs *flag.FlagSet, args []string) error {
+	eff, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	c.eff = eff
+
+	level := c.logLevel
+	if c.debug {
+		level = "debug"
+	}
+	logger, err := newLogger(os.Stderr, c.logFormat, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return setupTracing(c.trace)
+}
+
+// newClient builds a jdw.Client from the parsed flags.
+func (c *clientFlags) newClient() (*jdw.Client, error) {
+	token, err := c.resolveToken()
+	if err != nil {
+		return nil, err
+	}
+	return c.newClientWithToken(token)
+}
+
+// newClientWithToken builds a jdw.Client from the parsed flags and an already
+// resolved token.
+func (c *clientFlags) newClientWithToken(token string) (*jdw.Client, error) {
+	client := jdw.NewClient(c.appVersion, token, c.userAgent, jdw.WithTimeout(c.timeout), jdw.WithLogger(slog.Default()))
+	client.SetBaseURL(c.apiURL)
+	if c.validate {
+		client.SetSchemaValidator(jdw.DefaultSchemaValidator())
+	}
+	if err := configureCache(client, c.cacheDir, c.cacheTTL, c.offline); err != nil {
+		return nil, err
+	}
+	if err := configureFixtures(client, c.recordDir, c.replayDir); err != nil {
+		return nil, err
+	}
+	if err := checkAppVersion(client, c.versionCheck); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Modes for -version-check.
+const (
+	versionCheckOff  = "off"
+	versionCheckWarn = "warn"
+	versionCheckBump = "bump"
+)
+
+// checkAppVersion compares the client's App-Version with the API's minimum
+// version according to mode. A failed check is only a warning, so that
+// commands still run when the settings endpoint is unavailable.
+func checkAppVersion(client *jdw.Client, mode string) error {
+	switch mode {
+	case versionCheckOff:
+		return nil
+	case versionCheckWarn, versionCheckBump:
+	default:
+		return fmt.Errorf("invalid -version-check %q: must be off, warn or bump", mode)
+	}
+
+	check, err := client.NegotiateAppVersion(mode == versionCheckBump)
+	switch {
+	case err != nil:
+		slog.Warn("Could not check the minimum app version", "error", err)
+	case check.Bumped:
+		slog.Info("App version is below the minimum; using the minimum", "app_version", check.Configured, "min_version", check.Minimum)
+	case !check.Compatible:
+		slog.Warn("App version is below the minimum; requests may fail. Use -app-version or -version-check bump", "app_version", check.Configured, "min_version", check.Minimum)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"gopkg.in/yaml.v3"
+)
+
+// envForFlag maps flags to the environment variables that can set them.
+var envForFlag = map[string]string{
+	"config":      "JDW_CONFIG",
+	"profile":     "JDW_PROFILE",
+	"api-url":     "JDW_API_URL",
+	"app-version": "JDW_APP_VERSION",
+	"token":       "JDW_TOKEN",
+	"user-agent":  "JDW_USER_AGENT",
+	"cache-dir":   "JDW_CACHE_DIR",
+	"log-format":  "JDW_LOG_FORMAT",
+	"log-level":   "JDW_LOG_LEVEL",
+	"secret":      "JDW_WEBHOOK_SECRET",
+	"trace":       "OTEL_TRACES_EXPORTER",
+}
+
+// secretFlags are masked by "config show".
+var secretFlags = map[string]bool{"token": true, "secret": true}
+
+// Sources of an effective setting, as shown by "config show".
+const (
+	sourceFlag    = "flag"
+	sourceEnv     = "env"
+	sourceProfile = "profile"
+	sourceDefault = "default"
+)
+
+// configFile is the YAML configuration file. Settings are keyed by flag name;
+// Defaults apply to every profile and Profiles[name] overrides them.
+type configFile struct {
+	// Profile is used when neither -profile nor JDW_PROFILE is set.
+	Profile  string                            `yaml:"profile"`
+	Defaults map[string]interface{}            `yaml:"defaults"`
+	Profiles map[string]map[string]interface{} `yaml:"profiles"`
+}
+
+// effectiveConfig records where each flag's value came from.
+type effectiveConfig struct {
+	Path    string
+	Profile string
+	Sources map[string]string
+	// Unused holds profile settings for flags the command doesn't have.
+	Unused map[string]interface{}
+}
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/get_spoons/config.yaml, falling
+// back to ~/.config when XDG_CONFIG_HOME is unset.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "get_spoons", "config.yaml")
+}
+
+func loadConfigFile(path string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg configFile
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// parseFlags parses args and then fills every flag that was not set on the
+// command line from, in order of precedence, its environment variable and
+// the selected config profile. Flags set nowhere keep their defaults.
+func parseFlags(fs *flag.FlagSet, args []string) (*effectiveConfig, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	eff := &effectiveConfig{Sources: make(map[string]string)}
+	fs.VisitAll(func(f *flag.Flag) { eff.Sources[f.Name] = sourceDefault })
+	fs.Visit(func(f *flag.Flag) { eff.Sources[f.Name] = sourceFlag })
+
+	for name, env := range envForFlag {
+		if fs.Lookup(name) == nil || eff.Sources[name] == sourceFlag {
+			continue
+		}
+		if value, ok := os.LookupEnv(env); ok {
+			if err := fs.Set(name, value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", env, err)
+			}
+			eff.Sources[name] = sourceEnv
+		}
+	}
+
+	settings, err := eff.loadProfile(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	eff.Unused = make(map[string]interface{})
+	for name, value := range settings {
+		if fs.Lookup(name) == nil {
+			eff.Unused[name] = value
+			continue
+		}
+		if eff.Sources[name] != sourceDefault {
+			continue
+		}
+		if err := setFlagFromConfig(fs, name, value); err != nil {
+			return nil, fmt.Errorf("config %s: %w", eff.Path, err)
+		}
+		eff.Sources[name] = sourceProfile
+	}
+	return eff, nil
+}
+
+// loadProfile reads the config file named by -config (or the default path)
+// and returns the settings of the selected profile merged over the defaults.
+func (eff *effectiveConfig) loadProfile(fs *flag.FlagSet) (map[string]interface{}, error) {
+	explicit := false
+	if f := fs.Lookup("config"); f != nil && f.Value.String() != "" {
+		eff.Path, explicit = f.Value.String(), true
+	} else {
+		eff.Path = defaultConfigPath()
+	}
+	if eff.Path == "" {
+		return nil, nil
+	}
+
+	cfg, err := loadConfigFile(eff.Path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		eff.Path = ""
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	eff.Profile = cfg.Profile
+	if f := fs.Lookup("profile"); f != nil && f.Value.String() != "" {
+		eff.Profile = f.Value.String()
+	}
+
+	settings := make(map[string]interface{})
+	for k, v := range cfg.Defaults {
+		settings[k] = v
+	}
+	if eff.Profile != "" {
+		profile, ok := cfg.Profiles[eff.Profile]
+		if !ok {
+			var names []string
+			for name := range cfg.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("profile %q not found in %s (available: %s)", eff.Profile, eff.Path, strings.Join(names, ", "))
+		}
+		for k, v := range profile {
+			settings[k] = v
+		}
+	}
+	return settings, nil
+}
+
+// setFlagFromConfig sets a flag from a YAML value. Lists set repeatable
+// flags once per element.
+func setFlagFromConfig(fs *flag.FlagSet, name string, value interface{}) error {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+	for _, v := range values {
+		if err := fs.Set(name, fmt.Sprint(v)); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", fmt.Sprint(v), name, err)
+		}
+	}
+	return nil
+}
+
+// runConfig implements the "config" subcommand.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return errors.New("usage: get_spoons config show [-config path] [-profile name]")
+	}
+	return runConfigShow(args[1:], os.Stdout)
+}
+
+// runConfigShow prints the effective client configuration, and any other
+// settings from the selected profile, with where each value came from.
+// Secrets are masked.
+func runConfigShow(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("get_spoons config show", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	eff, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	path, profile := eff.Path, eff.Profile
+	if path == "" {
+		path = "(none; default is " + defaultConfigPath() + ")"
+	}
+	if profile == "" {
+		profile = "(none)"
+	}
+	fmt.Fprintf(w, "Config file: %s\nProfile:     %s\n\n", path, profile)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "profile" {
+			return
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Name, displayValue(f.Name, f.Value.String()), eff.Sources[f.Name])
+	})
+
+	var unused []string
+	for name := range eff.Unused {
+		unused = append(unused, name)
+	}
+	sort.Strings(unused)
+	for _, name := range unused {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, displayValue(name, fmt.Sprint(eff.Unused[name])), sourceProfile)
+	}
+	return tw.Flush()
+}
+
+func displayValue(name, value string) string {
+	if secretFlags[name] {
+		value = jdw.MaskToken(value)
+	}
+	if value == "" {
+		return `""`
+	}
+	return value
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `profile: prod
+defaults:
+  user-agent: team-agent
+  concurrency: 6
+profiles:
+  prod:
+    token: prod-token-0123456789abcdef
+  mock:
+    api-url: http://localhost:8081
+    token: 1|jdwtest
+    concurrency: 2
+    webhook:
+      - https://example.com/a
+      - slack=https://example.com/b
+`
+
+func writeTestConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatalf("Writing config failed: %v", err)
+	}
+	return path
+}
+
+func newTestFlagSet() (*flag.FlagSet, *clientFlags, *int, *webhookFlags) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	concurrency := fs.Int("concurrency", 1, "")
+	var hooks webhookFlags
+	fs.Var(&hooks, "webhook", "")
+	return fs, &cf, concurrency, &hooks
+}
+
+func TestParseFlagsPrecedence(t *testing.T) {
+	path := writeTestConfig(t)
+
+	// The file's default profile applies over defaults.
+	fs, cf, concurrency, _ := newTestFlagSet()
+	eff, err := parseFlags(fs, []string{"-config", path})
+	if err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if eff.Profile != "prod" || cf.token != "prod-token-0123456789abcdef" || cf.userAgent != "team-agent" || *concurrency != 6 {
+		t.Errorf("Unexpected prod settings: profile=%s token=%s ua=%s concurrency=%d", eff.Profile, cf.token, cf.userAgent, *concurrency)
+	}
+	if eff.Sources["token"] != sourceProfile || eff.Sources["app-version"] != sourceDefault {
+		t.Errorf("Unexpected sources: %v", eff.Sources)
+	}
+
+	// Env beats the profile, and flags beat env.
+	os.Setenv("JDW_TOKEN", "env-token")
+	defer os.Unsetenv("JDW_TOKEN")
+	os.Setenv("JDW_PROFILE", "mock")
+	defer os.Unsetenv("JDW_PROFILE")
+	fs, cf, concurrency, hooks := newTestFlagSet()
+	eff, err = parseFlags(fs, []string{"-config", path, "-concurrency", "9"})
+	if err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if eff.Profile != "mock" || cf.apiURL != "http://localhost:8081" {
+		t.Errorf("Expected the mock profile from JDW_PROFILE, got %s (%s)", eff.Profile, cf.apiURL)
+	}
+	if cf.token != "env-token" || eff.Sources["token"] != sourceEnv {
+		t.Errorf("Expected JDW_TOKEN to override the profile, got %s (%s)", cf.token, eff.Sources["token"])
+	}
+	if *concurrency != 9 || eff.Sources["concurrency"] != sourceFlag {
+		t.Errorf("Expected -concurrency to override the profile, got %d", *concurrency)
+	}
+	if len(*hooks) != 2 || (*hooks)[1].Format != formatSlack {
+		t.Errorf("Expected webhooks from the profile list, got %+v", *hooks)
+	}
+
+	fs, _, _, _ = newTestFlagSet()
+	if _, err := parseFlags(fs, []string{"-config", path, "-profile", "staging"}); err == nil || !strings.Contains(err.Error(), "mock, prod") {
+		t.Errorf("Expected an error listing available profiles, got %v", err)
+	}
+}
+
+func TestParseFlagsConfigFile(t *testing.T) {
+	// A missing default config file is fine; a missing explicit one is not.
+	fs, _, _, _ := newTestFlagSet()
+	eff, err := parseFlags(fs, nil)
+	if err != nil || eff.Path != "" {
+		t.Errorf("Expected no config file, got %q, %v", eff.Path, err)
+	}
+	fs, _, _, _ = newTestFlagSet()
+	if _, err := parseFlags(fs, []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("Expected an error for a missing -config file")
+	}
+
+	// The XDG path is used by default.
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "get_spoons"), 0o755)
+	os.WriteFile(filepath.Join(dir, "get_spoons", "config.yaml"), []byte(testConfig), 0o644)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	fs, cf, _, _ := newTestFlagSet()
+	if _, err := parseFlags(fs, nil); err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if cf.token != "prod-token-0123456789abcdef" {
+		t.Errorf("Expected the token from the XDG config, got %s", cf.token)
+	}
+
+	os.WriteFile(filepath.Join(dir, "get_spoons", "config.yaml"), []byte("defaults:\n  concurrency: lots\n"), 0o644)
+	fs, _, _, _ = newTestFlagSet()
+	if _, err := parseFlags(fs, nil); err == nil {
+		t.Error("Expected an error for an invalid value")
+	}
+}
+
+func TestConfigShow(t *testing.T) {
+	path := writeTestConfig(t)
+	var out strings.Builder
+	if err := runConfigShow([]string{"-config", path, "-profile", "mock", "-debug"}, &out); err != nil {
+		t.Fatalf("config show failed: %v", err)
+	}
+	got := out.String()
+	if !strings.Contains(got, "Profile:     mock") {
+		t.Errorf("Expected the mock profile:\n%s", got)
+	}
+
+	rows := make(map[string][]string)
+	for _, line := range strings.Split(got, "\n") {
+		if fields := strings.Fields(line); len(fields) == 3 {
+			rows[fields[0]] = fields[1:]
+		}
+	}
+	for setting, want := range map[string][2]string{
+		"api-url":     {"http://localhost:8081", sourceProfile},
+		"debug":       {"true", sourceFlag},
+		"concurrency": {"2", sourceProfile},
+		"user-agent":  {"team-agent", sourceProfile},
+	} {
+		if row := rows[setting]; len(row) != 2 || row[0] != want[0] || row[1] != want[1] {
+			t.Errorf("Expected %s to be %v, got %v", setting, want, row)
+		}
+	}
+	if strings.Contains(got, "1|jdwtest") {
+		t.Errorf("Expected the token to be masked:\n%s", got)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// dietFilterQuery returns the item query for the -diet and
+// -exclude-allergen flags: items suitable for every one of the
+// comma-separated diets that neither contain nor may contain any of the
+// comma-separated allergens. It returns "" when both are empty.
+func dietFilterQuery(diets, excludeAllergens string) (string, error) {
+	var terms []string
+	for _, d := range splitList(diets) {
+		diet, ok := jdw.CanonicalDiet(d)
+		if !ok {
+			return "", fmt.Errorf("invalid -diet: unknown diet %q (valid diets: %s)", d, strings.Join(jdw.Diets, ", "))
+		}
+		terms = append(terms, fieldDiet+":"+diet)
+	}
+	allergens, err := parseAllergens(excludeAllergens)
+	if err != nil {
+		return "", err
+	}
+	for _, a := range allergens {
+		terms = append(terms, "-"+fieldAllergen+`:"`+a+`"`)
+	}
+	return strings.Join(terms, " "), nil
+}
+
+// parseAllergens parses a comma-separated -exclude-allergen list into the
+// names jdw.DietaryInfo uses, rejecting anything that isn't one of the 14
+// major allergens or an alias of one.
+func parseAllergens(list string) ([]string, error) {
+	var allergens []string
+	for _, a := range splitList(list) {
+		allergen, ok := jdw.LookupAllergen(a)
+		if !ok {
+			return nil, fmt.Errorf("invalid -exclude-allergen: unknown allergen %q (valid allergens: %s)", a, strings.Join(jdw.Allergens, ", "))
+		}
+		allergens = append(allergens, allergen)
+	}
+	return allergens, nil
+}
+
+// dietReportRow is the number of menu options a venue offers per diet.
+type dietReportRow struct {
+	VenueID   int    `json:"venueId"`
+	VenueName string `json:"venueName"`
+	Town      string `json:"town,omitempty"`
+	// Items is the number of distinct in-stock items, and Diets the number
+	// of those suitable for each of jdw.Diets.
+	Items int            `json:"items"`
+	Diets map[string]int `json:"diets"`
+}
+
+// newDietReportRow counts the distinct in-stock items of a venue, skipping
+// those that contain or may contain any of excluded.
+func newDietReportRow(v jdw.Venue, items []menuItem, excluded []string) dietReportRow {
+	row := dietReportRow{VenueID: v.ID, VenueName: v.Name, Town: v.Address.Town, Diets: make(map[string]int)}
+	for _, d := range jdw.Diets {
+		row.Diets[d] = 0
+	}
+	seen := make(map[string]bool)
+items:
+	for _, it := range items {
+		if it.OutOfStock {
+			continue
+		}
+		for _, a := range excluded {
+			if it.MayContainAllergen(a) {
+				continue items
+			}
+		}
+		// The same item is often listed on several menus.
+		key := strconv.Itoa(it.ID)
+		if it.ID == 0 {
+			key = strings.ToLower(it.Name)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		row.Items++
+		for _, d := range jdw.Diets {
+			if it.Suits(d) {
+				row.Diets[d]++
+			}
+		}
+	}
+	return row
+}
+
+// runDietReport implements the "diet-report" subcommand: it fetches the
+// menus of the selected venues and reports how many items each offers for
+// each diet.
+func runDietReport(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("get_spoons diet-report", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	near := fs.String("near", "", "Only include venues near this location, as 'lat,lng'")
+	radius := fs.Float64("radius", 5, "Radius in km for -near")
+	venueIDs := fs.String("venues", "", "Only include these venue IDs (comma-separated)")
+	limit := fs.Int("limit", 0, "Limit number of venues (0 for all)")
+	excludeAllergens := fs.String("exclude-allergen", "", "Don't count items that contain or may contain these allergens (comma-separated); items without allergen information are still counted")
+	format := fs.String("format", "text", "Output format: text, json or csv")
+	output := fs.String("output", "", "Output file path (default: stdout)")
+	concurrency := fs.Int("concurrency", 4, "Number of concurrent requests")
+	retries := fs.Int("retries", 1, "Number of times to retry failed detail, menu and item requests")
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" && *format != "csv" {
+		return fmt.Errorf("invalid -format %q: must be text, json or csv", *format)
+	}
+	excluded, err := parseAllergens(*excludeAllergens)
+	if err != nil {
+		return err
+	}
+	filter, err := parseWatchFilter(*near, *radius, *venueIDs)
+	if err != nil {
+		return err
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+	venues, items, partial, err := fetchVenueItems(client, filter, *limit, expandOptions{Concurrency: *concurrency, Retries: *retries})
+	if err != nil {
+		return err
+	}
+
+	rows := make([]dietReportRow, 0, len(venues))
+	for _, v := range venues {
+		rows = append(rows, newDietReportRow(v, items[v.ID], excluded))
+	}
+	slog.Info("Built diet report", "venues", len(rows))
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writeDietReport(w, rows, *format); err != nil {
+		return err
+	}
+	return partial
+}
+
+func writeDietReport(w io.Writer, rows []dietReportRow, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(append([]string{"venueId", "venueName", "town", "items"}, jdw.Diets...))
+		for _, r := range rows {
+			record := []string{strconv.Itoa(r.VenueID), r.VenueName, r.Town, strconv.Itoa(r.Items)}
+			for _, d := range jdw.Diets {
+				record = append(record, strconv.Itoa(r.Diets[d]))
+			}
+			cw.Write(record)
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := []string{"VENUE", "TOWN", "ITEMS"}
+	for _, d := range jdw.Diets {
+		header = append(header, strings.ToUpper(d))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%d", r.VenueName, r.Town, r.Items)
+		for _, d := range jdw.Diets {
+			fmt.Fprintf(tw, "\t%d", r.Diets[d])
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
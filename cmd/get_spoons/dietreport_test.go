@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+)
+
+func TestDietFilterQuery(t *testing.T) {
+	got, err := dietFilterQuery("VG, gluten free", "peanuts,tree nuts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `diet:vegan diet:gluten-free -allergen:"peanuts" -allergen:"nuts"`; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if _, err := parseItemQuery(got, false); err != nil {
+		t.Errorf("Expected the query to parse, got %v", err)
+	}
+	if got, err := dietFilterQuery("", "Almonds"); err != nil || got != `-allergen:"nuts"` {
+		t.Errorf("Expected almonds to exclude nuts, got %q, %v", got, err)
+	}
+	if _, err := dietFilterQuery("", "peanuts,peanutz"); err == nil || !strings.Contains(err.Error(), `invalid -exclude-allergen: unknown allergen "peanutz"`) {
+		t.Errorf("Expected an unknown allergen to fail, got %v", err)
+	}
+	if _, err := dietFilterQuery("keto", ""); err == nil || !strings.Contains(err.Error(), "valid diets: vegan, vegetarian, gluten-free") {
+		t.Errorf("Expected an unknown diet to fail, got %v", err)
+	}
+}
+
+func TestRunDietFilters(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 3})
+	defer fake.Close()
+	os.Setenv("JDW_API_URL", fake.URL)
+	defer os.Unsetenv("JDW_API_URL")
+	os.Setenv("JDW_TOKEN", fake.Token())
+	defer os.Unsetenv("JDW_TOKEN")
+
+	output := t.TempDir() + "/venues.json"
+	if err := Run([]string{"-diet", "vegan", "-exclude-allergen", "soy", "-output", output}); err != nil {
+		t.Fatalf("Run -diet failed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var venues []map[string]interface{}
+	if err := json.Unmarshal(data, &venues); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(venues) != 3 {
+		t.Errorf("Expected all 3 venues to have vegan options, got %d", len(venues))
+	}
+	var names []string
+	for _, v := range venues {
+		for _, it := range extractItems(v) {
+			names = append(names, it.Name)
+		}
+	}
+	for _, name := range names {
+		if name != "Superfood Salad" {
+			t.Errorf("Expected only Superfood Salad, got %v", names)
+			break
+		}
+	}
+
+	if err := Run([]string{"-diet", "keto"}); err == nil || !strings.Contains(err.Error(), "invalid -diet") {
+		t.Errorf("Expected an unknown diet to fail, got %v", err)
+	}
+	if err := Run([]string{"-exclude-allergen", "peanutz"}); err == nil || !strings.Contains(err.Error(), "invalid -exclude-allergen") {
+		t.Errorf("Expected an unknown allergen to fail, got %v", err)
+	}
+}
+
+func TestDietReport(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 4})
+	defer fake.Close()
+	first := fake.Dataset.Venues[0]
+
+	var out strings.Builder
+	args := []string{"-api-url", fake.URL, "-token", fake.Token(), "-format", "json"}
+	if err := runDietReport(args, &out); err != nil {
+		t.Fatalf("diet-report failed: %v", err)
+	}
+	var rows []dietReportRow
+	if err := json.Unmarshal([]byte(out.String()), &rows); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(rows) != 4 || rows[0].VenueID != first.ID {
+		t.Fatalf("Expected a row for each of 4 venues, got %+v", rows)
+	}
+	r := rows[0]
+	if r.Items == 0 || r.Diets[jdw.DietVegan] == 0 || r.Diets[jdw.DietVegetarian] < r.Diets[jdw.DietVegan] || r.Diets[jdw.DietVegan] >= r.Items {
+		t.Errorf("Expected some but not all items to be vegan, got %+v", r)
+	}
+
+	// Excluding an allergen drops the items containing it from the counts.
+	out.Reset()
+	args = []string{"-api-url", fake.URL, "-token", fake.Token(), "-format", "json", "-venues", strconv.Itoa(first.ID), "-exclude-allergen", "gluten"}
+	if err := runDietReport(args, &out); err != nil {
+		t.Fatalf("diet-report -exclude-allergen failed: %v", err)
+	}
+	rows = nil
+	if err := json.Unmarshal([]byte(out.String()), &rows); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(rows) != 1 || rows[0].Items >= r.Items || rows[0].Diets[jdw.DietVegan] >= r.Diets[jdw.DietVegan] {
+		t.Errorf("Expected fewer items and vegan options without gluten than %+v, got %+v", r, rows)
+	}
+
+	if err := runDietReport([]string{"-api-url", fake.URL, "-token", fake.Token(), "-exclude-allergen", "peanutz"}, &out); err == nil {
+		t.Error("Expected an unknown allergen to fail")
+	}
+
+	// A venue whose menus couldn't be fetched is left out, not reported as
+	// having no items, and the run is partial.
+	out.Reset()
+	fake.FailNext(0, http.StatusServiceUnavailable)
+	args = []string{"-api-url", fake.URL, "-token", fake.Token(), "-format", "json", "-concurrency", "1", "-retries", "0"}
+	err := runDietReport(args, &out)
+	var pe *partialError
+	if !errors.As(err, &pe) || exitCode(err) != exitPartial {
+		t.Fatalf("Expected a partial error, got %v", err)
+	}
+	rows = nil
+	if err := json.Unmarshal([]byte(out.String()), &rows); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(rows) != 3 || rows[0].VenueID == first.ID {
+		t.Errorf("Expected the 3 venues that could be fetched, got %+v", rows)
+	}
+
+	fake.FailEndpoint(jdw.EndpointMenus, http.StatusServiceUnavailable)
+	err = runDietReport(args, &out)
+	if err == nil || exitCode(err) != exitFailure || !strings.Contains(err.Error(), "no venues could be fetched") {
+		t.Errorf("Expected a failure when no venue could be fetched, got %v", err)
+	}
+	fake.FailEndpoint(jdw.EndpointMenus, 0)
+
+	out.Reset()
+	if err := runDietReport([]string{"-api-url", fake.URL, "-token", fake.Token(), "-limit", "1"}, &out); err != nil {
+		t.Fatalf("diet-report failed: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "VENUE") || !strings.Contains(out.String(), "GLUTEN-FREE") || !strings.Contains(out.String(), first.Name) {
+		t.Errorf("Expected a table with %s:\n%s", first.Name, out.String())
+	}
+}
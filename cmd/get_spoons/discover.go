@@ -0,0 +1,366 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+)
+
+// Output formats for discover.
+const (
+	discoverJSONSchema = "jsonschema"
+	discoverOpenAPI    = "openapi"
+)
+
+// jsonSchemaDialect is the JSON Schema version discover emits.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// shape accumulates every value observed at one position in a set of
+// payloads, such as data.salesAreas[].name.
+type shape struct {
+	// Types counts the values seen of each JSON type.
+	Types map[string]int
+	// Props holds the shapes of object properties; Props[k].Count over
+	// Types["object"] is how often the property is present.
+	Props map[string]*shape
+	Items *shape
+	// Strings counts distinct string values, until there are more than
+	// maxStrings of them.
+	Strings map[string]int
+	// TooManyStrings is set once Strings is abandoned.
+	TooManyStrings bool
+}
+
+// maxStrings bounds the distinct string values tracked per shape.
+const maxStrings = 100
+
+func newShape() *shape {
+	return &shape{Types: make(map[string]int)}
+}
+
+// Count returns the number of values observed.
+func (s *shape) Count() int {
+	n := 0
+	for _, c := range s.Types {
+		n += c
+	}
+	return n
+}
+
+// observe merges a value decoded by encoding/json into the shape.
+func (s *shape) observe(value interface{}) {
+	typ := inferredType(value)
+	s.Types[typ]++
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if s.Props == nil {
+			s.Props = make(map[string]*shape)
+		}
+		for k, child := range v {
+			if s.Props[k] == nil {
+				s.Props[k] = newShape()
+			}
+			s.Props[k].observe(child)
+		}
+	case []interface{}:
+		if s.Items == nil {
+			s.Items = newShape()
+		}
+		for _, elem := range v {
+			s.Items.observe(elem)
+		}
+	case string:
+		if s.TooManyStrings {
+			return
+		}
+		if s.Strings == nil {
+			s.Strings = make(map[string]int)
+		}
+		s.Strings[v]++
+		if len(s.Strings) > maxStrings {
+			s.Strings, s.TooManyStrings = nil, true
+		}
+	}
+}
+
+// inferredType returns the JSON Schema type of a value decoded by
+// encoding/json, treating whole numbers as integers.
+func inferredType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// schemaDoc is an inferred schema, in either JSON Schema or OpenAPI 3.0 form.
+// Field order is the output order.
+type schemaDoc struct {
+	Schema     string                `json:"$schema,omitempty" yaml:"$schema,omitempty"`
+	Type       interface{}           `json:"type,omitempty" yaml:"type,omitempty"`
+	OneOf      []*schemaDoc          `json:"oneOf,omitempty" yaml:"oneOf,omitempty"`
+	Nullable   bool                  `json:"nullable,omitempty" yaml:"nullable,omitempty"`
+	Enum       []interface{}         `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Properties map[string]*schemaDoc `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Required   []string              `json:"required,omitempty" yaml:"required,omitempty"`
+	Items      *schemaDoc            `json:"items,omitempty" yaml:"items,omitempty"`
+	// Presence is the fraction of objects in which a property was present.
+	Presence *float64 `json:"x-presence,omitempty" yaml:"x-presence,omitempty"`
+	// Observed is the number of values the schema was inferred from.
+	Observed int                   `json:"x-observed,omitempty" yaml:"x-observed,omitempty"`
+	Defs     map[string]*schemaDoc `json:"$defs,omitempty" yaml:"$defs,omitempty"`
+}
+
+// inferOptions controls how a shape is turned into a schema.
+type inferOptions struct {
+	// OpenAPI selects OpenAPI 3.0 schemas, which use nullable and oneOf
+	// instead of type lists.
+	OpenAPI bool
+	// EnumMax is the most distinct values a string field may have to be
+	// given an enum; 0 disables enums.
+	EnumMax int
+}
+
+// schema converts the shape to a schema.
+func (s *shape) schema(opts inferOptions) *schemaDoc {
+	doc := &schemaDoc{Observed: s.Count()}
+
+	var types []string
+	for t := range s.Types {
+		if t != "null" {
+			types = append(types, t)
+		}
+	}
+	// Whole and fractional numbers are both numbers.
+	if s.Types["integer"] > 0 && s.Types["number"] > 0 {
+		types = removeString(types, "integer")
+	}
+	sort.Strings(types)
+	nullable := s.Types["null"] > 0
+
+	switch {
+	case opts.OpenAPI && len(types) == 1:
+		doc.Type = types[0]
+		doc.Nullable = nullable
+	case opts.OpenAPI && len(types) > 1:
+		for _, t := range types {
+			doc.OneOf = append(doc.OneOf, &schemaDoc{Type: t})
+		}
+		doc.Nullable = nullable
+	case opts.OpenAPI:
+		// Only nulls were seen.
+		doc.Nullable = nullable
+	default:
+		if nullable {
+			types = append(types, "null")
+		}
+		if len(types) == 1 {
+			doc.Type = types[0]
+		} else if len(types) > 1 {
+			doc.Type = types
+		}
+	}
+
+	if s.Props != nil {
+		objects := s.Types["object"]
+		doc.Properties = make(map[string]*schemaDoc, len(s.Props))
+		for name, prop := range s.Props {
+			child := prop.schema(opts)
+			presence := math.Round(float64(prop.Count())/float64(objects)*100) / 100
+			child.Presence = &presence
+			doc.Properties[name] = child
+			if prop.Count() == objects {
+				doc.Required = append(doc.Required, name)
+			}
+		}
+		sort.Strings(doc.Required)
+	}
+	if s.Types["array"] > 0 {
+		doc.Items = &schemaDoc{}
+		if s.Items != nil {
+			doc.Items = s.Items.schema(opts)
+		}
+	}
+	if enum := s.enum(opts.EnumMax); enum != nil {
+		doc.Enum = enum
+		if nullable && !opts.OpenAPI {
+			doc.Enum = append(doc.Enum, nil)
+		}
+	}
+	return doc
+}
+
+// enum returns the observed values of a string field with few enough distinct
+// values, each seen at least twice on average, to look like an enumeration.
+func (s *shape) enum(max int) []interface{} {
+	if max <= 0 || s.TooManyStrings || len(s.Strings) == 0 || len(s.Strings) > max {
+		return nil
+	}
+	if s.Types["string"] < 2*len(s.Strings) || len(s.Types) > 2 || (len(s.Types) == 2 && s.Types["null"] == 0) {
+		return nil
+	}
+	values := make([]string, 0, len(s.Strings))
+	for v := range s.Strings {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	enum := make([]interface{}, len(values))
+	for i, v := range values {
+		enum[i] = v
+	}
+	return enum
+}
+
+func removeString(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// discovery holds the shapes inferred for each untyped payload.
+type discovery struct {
+	VenueDetails *shape
+	Menus        *shape
+	MenuItems    *shape
+}
+
+func newDiscovery() *discovery {
+	return &discovery{VenueDetails: newShape(), Menus: newShape(), MenuItems: newShape()}
+}
+
+// observeVenue splits a venue expanded by expandVenue back into the payloads
+// of the requests that built it.
+func (d *discovery) observeVenue(details map[string]interface{}) {
+	venue := make(map[string]interface{}, len(details))
+	for k, v := range details {
+		venue[k] = v
+	}
+	menus, hasMenus := venue["menus"].([]interface{})
+	delete(venue, "menus")
+	d.VenueDetails.observe(venue)
+	if !hasMenus {
+		return
+	}
+
+	summaries := make([]interface{}, 0, len(menus))
+	for _, m := range menus {
+		menu, ok := m.(map[string]interface{})
+		if !ok {
+			summaries = append(summaries, m)
+			continue
+		}
+		summary := make(map[string]interface{}, len(menu))
+		for k, v := range menu {
+			summary[k] = v
+		}
+		if items, ok := summary["details"]; ok {
+			d.MenuItems.observe(items)
+			delete(summary, "details")
+		}
+		summaries = append(summaries, summary)
+	}
+	d.Menus.observe(summaries)
+}
+
+// schemas returns a schema per payload that was observed, keyed by the name
+// used in the output.
+func (d *discovery) schemas(opts inferOptions) map[string]*schemaDoc {
+	schemas := make(map[string]*schemaDoc)
+	for name, s := range map[string]*shape{"VenueDetails": d.VenueDetails, "Menus": d.Menus, "MenuItems": d.MenuItems} {
+		if s.Count() > 0 {
+			schemas[name] = s.schema(opts)
+		}
+	}
+	return schemas
+}
+
+// runDiscover implements the "discover" subcommand.
+func runDiscover(args []string) error {
+	return runDiscoverTo(args, os.Stdout)
+}
+
+// runDiscoverTo crawls a sample of venues and writes the inferred schemas of
+// the venue details, menus and menu items payloads to w, or to -output.
+func runDiscoverTo(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("get_spoons discover", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	sample := fs.Int("venues", 5, "Number of venues to sample, spread evenly across the estate (0 for all)")
+	items := fs.Bool("items", false, "Also fetch menu items")
+	format := fs.String("format", discoverJSONSchema, "Output format: jsonschema or openapi (a components.schemas YAML fragment)")
+	enumMax := fs.Int("enum-max", 10, "Most distinct values a string field may have to be inferred as an enum (0 to disable)")
+	concurrency := fs.Int("concurrency", 1, "Number of concurrent requests")
+	outputFile := fs.String("output", "", "Output file path (default: stdout)")
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+	if *format != discoverJSONSchema && *format != discoverOpenAPI {
+		return fmt.Errorf("invalid -format %q: must be %s or %s", *format, discoverJSONSchema, discoverOpenAPI)
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+	venues, err := client.GetVenues()
+	if err != nil {
+		return fmt.Errorf("fetching venues: %w", err)
+	}
+	expanded, failures := expandVenues(client, sampleVenues(venues, *sample), expandOptions{
+		Concurrency:  *concurrency,
+		IncludeMenus: true,
+		IncludeItems: *items,
+	})
+	if len(expanded) == 0 {
+		return fmt.Errorf("no venue details could be fetched (%d failed requests)", len(failures))
+	}
+
+	d := newDiscovery()
+	for _, details := range expanded {
+		d.observeVenue(details)
+	}
+	slog.Info("Inferred schemas", "venues", d.VenueDetails.Count(), "menu_lists", d.Menus.Count(), "menus", d.MenuItems.Count(), "failures", len(failures))
+
+	if *outputFile != "" {
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	opts := inferOptions{OpenAPI: *format == discoverOpenAPI, EnumMax: *enumMax}
+	if opts.OpenAPI {
+		err = writeYAML(w, map[string]interface{}{
+			"components": map[string]interface{}{"schemas": d.schemas(opts)},
+		})
+	} else {
+		err = writeJSON(w, &schemaDoc{Schema: jsonSchemaDialect, Defs: d.schemas(opts)})
+	}
+	if err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
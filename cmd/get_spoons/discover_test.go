@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+	"gopkg.in/yaml.v3"
+)
+
+func TestShapeSchema(t *testing.T) {
+	var samples []interface{}
+	if err := json.Unmarshal([]byte(`[
+		{"id": 1, "status": "open", "price": 1, "phone": "0161", "tags": [], "town": null},
+		{"id": 2, "status": "open", "price": 2.5, "tags": ["a"], "town": "Leeds"},
+		{"id": 3, "status": "closed", "price": 3, "tags": ["b"], "town": "York"},
+		{"id": 4, "status": "open", "price": 4, "tags": [1], "town": null}
+	]`), &samples); err != nil {
+		t.Fatal(err)
+	}
+	s := newShape()
+	for _, sample := range samples {
+		s.observe(sample)
+	}
+
+	doc := s.schema(inferOptions{EnumMax: 3})
+	if doc.Type != "object" || doc.Observed != 4 {
+		t.Errorf("Expected an object observed 4 times, got %v (%d)", doc.Type, doc.Observed)
+	}
+	if want := []string{"id", "price", "status", "tags", "town"}; !reflect.DeepEqual(doc.Required, want) {
+		t.Errorf("Expected required %v, got %v", want, doc.Required)
+	}
+	props := doc.Properties
+	if *props["phone"].Presence != 0.25 || *props["id"].Presence != 1 {
+		t.Errorf("Unexpected presence: phone %v, id %v", *props["phone"].Presence, *props["id"].Presence)
+	}
+	if props["id"].Type != "integer" || props["price"].Type != "number" {
+		t.Errorf("Expected integer id and number price, got %v and %v", props["id"].Type, props["price"].Type)
+	}
+	if !reflect.DeepEqual(props["status"].Enum, []interface{}{"closed", "open"}) {
+		t.Errorf("Expected a status enum, got %v", props["status"].Enum)
+	}
+	if props["town"].Enum != nil {
+		t.Errorf("Expected no enum for distinct towns, got %v", props["town"].Enum)
+	}
+	if !reflect.DeepEqual(props["town"].Type, []string{"string", "null"}) {
+		t.Errorf("Expected a nullable string town, got %v", props["town"].Type)
+	}
+	if !reflect.DeepEqual(props["tags"].Items.Type, []string{"integer", "string"}) {
+		t.Errorf("Expected mixed tag types, got %v", props["tags"].Items.Type)
+	}
+
+	openapi := s.schema(inferOptions{OpenAPI: true, EnumMax: 3}).Properties
+	if openapi["town"].Type != "string" || !openapi["town"].Nullable {
+		t.Errorf("Expected a nullable string town in OpenAPI form, got %+v", openapi["town"])
+	}
+	if len(openapi["tags"].Items.OneOf) != 2 || openapi["tags"].Items.Type != nil {
+		t.Errorf("Expected oneOf for mixed tag types, got %+v", openapi["tags"].Items)
+	}
+
+	if props := s.schema(inferOptions{}).Properties; props["status"].Enum != nil {
+		t.Errorf("Expected -enum-max 0 to disable enums, got %v", props["status"].Enum)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 6})
+	defer fake.Close()
+	base := []string{"-api-url", fake.URL, "-token", fake.Token(), "-venues", "4", "-items"}
+
+	var out strings.Builder
+	if err := runDiscoverTo(base, &out); err != nil {
+		t.Fatalf("discover failed: %v", err)
+	}
+	var doc schemaDoc
+	if err := json.Unmarshal([]byte(out.String()), &doc); err != nil {
+		t.Fatalf("Invalid JSON Schema: %v\n%s", err, out.String())
+	}
+	if doc.Schema != jsonSchemaDialect {
+		t.Errorf("Expected $schema %q, got %q", jsonSchemaDialect, doc.Schema)
+	}
+	venue := doc.Defs["VenueDetails"]
+	if venue == nil || venue.Observed != 4 {
+		t.Fatalf("Expected VenueDetails inferred from 4 venues, got %+v", venue)
+	}
+	if _, ok := venue.Properties["menus"]; ok {
+		t.Error("Expected the expanded menus to be split out of VenueDetails")
+	}
+	if venue.Properties["salesAreas"].Items.Properties["name"].Type != "string" {
+		t.Errorf("Unexpected salesAreas schema: %+v", venue.Properties["salesAreas"])
+	}
+	if _, ok := doc.Defs["Menus"].Items.Properties["details"]; ok {
+		t.Error("Expected menu items to be split out of Menus")
+	}
+	if doc.Defs["MenuItems"] == nil || doc.Defs["MenuItems"].Properties["categories"] == nil {
+		t.Errorf("Expected MenuItems with categories, got %+v", doc.Defs["MenuItems"])
+	}
+
+	out.Reset()
+	if err := runDiscoverTo(append(base, "-format", "openapi"), &out); err != nil {
+		t.Fatalf("discover -format openapi failed: %v", err)
+	}
+	var fragment struct {
+		Components struct {
+			Schemas map[string]map[string]interface{} `yaml:"schemas"`
+		} `yaml:"components"`
+	}
+	if err := yaml.Unmarshal([]byte(out.String()), &fragment); err != nil {
+		t.Fatalf("Invalid OpenAPI fragment: %v\n%s", err, out.String())
+	}
+	if fragment.Components.Schemas["VenueDetails"]["type"] != "object" {
+		t.Errorf("Expected an object VenueDetails schema, got %v", fragment.Components.Schemas["VenueDetails"])
+	}
+	if !strings.Contains(out.String(), "x-presence: 1") {
+		t.Errorf("Expected presence annotations:\n%s", out.String())
+	}
+
+	if err := runDiscoverTo(append(base, "-format", "xml"), &out); err == nil {
+		t.Error("Expected an invalid -format to fail")
+	}
+}
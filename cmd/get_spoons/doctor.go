@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// Doctor check results.
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "FAIL"
+)
+
+// runDoctor implements the "doctor" subcommand.
+func runDoctor(args []string) error {
+	return runDoctorTo(args, os.Stdout)
+}
+
+// runDoctorTo checks the token, connectivity, app version compatibility and
+// authentication in one go, writing a line per check to w. It returns an
+// error if any check failed.
+func runDoctorTo(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("get_spoons doctor", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+
+	failed := 0
+	report := func(result, name, format string, a ...interface{}) {
+		if result == checkFail {
+			failed++
+		}
+		fmt.Fprintf(w, "%-6s %s: %s\n", "["+result+"]", name, fmt.Sprintf(format, a...))
+	}
+
+	token, tokenErr := cf.resolveToken()
+	switch {
+	case tokenErr != nil:
+		report(checkFail, "Token", "%v", tokenErr)
+	case token == "":
+		report(checkWarn, "Token", "none configured; responses come from -replay or -offline")
+	default:
+		report(checkOK, "Token", "configured (%s)", jdw.MaskToken(token))
+	}
+
+	// The doctor runs its own version check below.
+	mode := cf.versionCheck
+	cf.versionCheck = versionCheckOff
+	client, err := cf.newClientWithToken(token)
+	if err != nil {
+		report(checkFail, "Client", "%v", err)
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+
+	start := time.Now()
+	check, err := client.NegotiateAppVersion(true)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	var apiErr *jdw.APIError
+	switch {
+	case err == nil:
+		report(checkOK, "Connectivity", "%s responded in %v", cf.apiURL, elapsed)
+	case errors.As(err, &apiErr):
+		report(checkOK, "Connectivity", "%s responded in %v (settings returned %s)", cf.apiURL, elapsed, apiErr.Status)
+	default:
+		report(checkFail, "Connectivity", "cannot reach %s: %v", cf.apiURL, err)
+	}
+
+	switch {
+	case err != nil:
+		report(checkFail, "App version", "could not fetch the minimum version: %v", err)
+	case check.Minimum == "":
+		report(checkWarn, "App version", "%s (the API did not report a minimum version)", check.Configured)
+	case check.Compatible:
+		report(checkOK, "App version", "%s meets the minimum %s", check.Configured, check.Minimum)
+	case mode == versionCheckBump:
+		report(checkWarn, "App version", "%s is below the minimum %s; -version-check bump will send %s", check.Configured, check.Minimum, check.Minimum)
+	default:
+		report(checkFail, "App version", "%s is below the minimum %s; use -app-version %s or -version-check bump", check.Configured, check.Minimum, check.Minimum)
+	}
+
+	// Authentication is checked with a compatible App-Version (bumped above
+	// if necessary) so that a stale version isn't mistaken for a bad token.
+	if tokenErr == nil {
+		venues, err := client.GetVenues()
+		switch {
+		case err == nil:
+			report(checkOK, "Authentication", "token accepted (%d venues)", len(venues))
+		case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden):
+			report(checkFail, "Authentication", "token rejected (%s)", apiErr.Status)
+		default:
+			report(checkFail, "Authentication", "could not list venues: %v", err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
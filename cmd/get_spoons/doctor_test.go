@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+)
+
+func TestDoctor(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 3})
+	defer fake.Close()
+	base := []string{"-api-url", fake.URL, "-token", fake.Token()}
+
+	var out strings.Builder
+	if err := runDoctorTo(base, &out); err != nil {
+		t.Fatalf("Expected all checks to pass, got %v:\n%s", err, out.String())
+	}
+	for _, want := range []string{
+		"[ok]   Token: configured",
+		"[ok]   Connectivity: " + fake.URL,
+		"[ok]   App version: 6.7.1 meets the minimum 6.5.0",
+		"[ok]   Authentication: token accepted (3 venues)",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := runDoctorTo(append(base, "-app-version", "6.4.9"), &out); err == nil {
+		t.Error("Expected an old app version to fail")
+	}
+	if !strings.Contains(out.String(), "[FAIL] App version: 6.4.9 is below the minimum 6.5.0") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runDoctorTo(append(base, "-app-version", "6.4.9", "-version-check", "bump"), &out); err != nil {
+		t.Errorf("Expected -version-check bump to only warn, got %v:\n%s", err, out.String())
+	}
+
+	fake.RejectAuth(true)
+	out.Reset()
+	if err := runDoctorTo(base, &out); err == nil {
+		t.Error("Expected a rejected token to fail")
+	}
+	if !strings.Contains(out.String(), "[FAIL] Authentication: token rejected (401 Unauthorized)") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+	fake.RejectAuth(false)
+
+	out.Reset()
+	if err := runDoctorTo([]string{"-api-url", fake.URL}, &out); err == nil {
+		t.Error("Expected a missing token to fail")
+	}
+	if !strings.Contains(out.String(), "[FAIL] Token: no JDW token configured") || strings.Contains(out.String(), "Authentication") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runDoctorTo([]string{"-api-url", "http://127.0.0.1:1", "-token", "x"}, &out); err == nil {
+		t.Error("Expected an unreachable API to fail")
+	}
+	if !strings.Contains(out.String(), "[FAIL] Connectivity: cannot reach http://127.0.0.1:1") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+}
+
+func TestVersionCheckFlag(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 1})
+	defer fake.Close()
+
+	cf := parseClientFlags(t, "-api-url", fake.URL, "-token", fake.Token(), "-app-version", "6.0.0", "-version-check", "bump")
+	client, err := cf.newClient()
+	if err != nil {
+		t.Fatalf("newClient failed: %v", err)
+	}
+	if client.AppVersion() != "6.5.0" {
+		t.Errorf("Expected the app version to be bumped to 6.5.0, got %s", client.AppVersion())
+	}
+
+	cf = parseClientFlags(t, "-api-url", fake.URL, "-token", fake.Token(), "-app-version", "6.0.0", "-version-check", "warn")
+	if client, err = cf.newClient(); err != nil || client.AppVersion() != "6.0.0" {
+		t.Errorf("Expected -version-check warn to keep 6.0.0, got %v", err)
+	}
+
+	cf = parseClientFlags(t, "-token", "x", "-version-check", "always")
+	if _, err := cf.newClient(); err == nil {
+		t.Error("Expected an error for an invalid -version-check")
+	}
+}
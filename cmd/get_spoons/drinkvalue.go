@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// Groupings and rankings of the drink-value command.
+const (
+	drinkGroupAll    = "all"
+	drinkGroupVenue  = "venue"
+	drinkGroupTown   = "town"
+	drinkGroupCounty = "county"
+
+	drinkRankUnit  = "unit"
+	drinkRankLitre = "litre"
+)
+
+// drinkValue is the value of one portion of a drink at a venue.
+type drinkValue struct {
+	VenueID   int     `json:"venueId"`
+	VenueName string  `json:"venueName"`
+	Town      string  `json:"town,omitempty"`
+	County    string  `json:"county,omitempty"`
+	Item      string  `json:"item"`
+	Category  string  `json:"category,omitempty"`
+	Portion   string  `json:"portion"`
+	VolumeML  float64 `json:"volumeMl"`
+	ABV       float64 `json:"abv"`
+	Price     float64 `json:"price"`
+	// Units is the portion's UK units of alcohol, and PricePerUnit its price
+	// per unit, which is 0 for alcohol-free drinks.
+	Units         float64 `json:"units"`
+	PricePerLitre float64 `json:"pricePerLitre"`
+	PricePerUnit  float64 `json:"pricePerUnit,omitempty"`
+}
+
+// drinkValues returns the value of each in-stock, priced portion of the
+// venue's drinks: the items with a stated ABV whose portions have a volume.
+// A portion listed on several menus is included once.
+func drinkValues(v jdw.Venue, items []menuItem) []drinkValue {
+	var out []drinkValue
+	seen := make(map[string]bool)
+	for _, it := range items {
+		if it.OutOfStock {
+			continue
+		}
+		abv, ok := jdw.ParseABV(it.Name)
+		if !ok {
+			if abv, ok = jdw.ParseABV(it.Description); !ok {
+				continue
+			}
+		}
+		for _, p := range it.Portions {
+			ml, ok := jdw.PortionVolume(p.Label, it.Description)
+			if !ok || p.Price <= 0 {
+				continue
+			}
+			key := strings.ToLower(it.Name + "\x00" + p.Label)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			d := drinkValue{
+				VenueID:       v.ID,
+				VenueName:     v.Name,
+				Town:          v.Address.Town,
+				County:        v.Address.County,
+				Item:          it.Name,
+				Category:      it.Category,
+				Portion:       p.Label,
+				VolumeML:      math.Round(ml),
+				ABV:           abv,
+				Price:         p.Price,
+				Units:         math.Round(jdw.AlcoholUnits(ml, abv)*100) / 100,
+				PricePerLitre: math.Round(p.Price/ml*1000*100) / 100,
+			}
+			if units := jdw.AlcoholUnits(ml, abv); units > 0 {
+				d.PricePerUnit = math.Round(p.Price/units*100) / 100
+			}
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// group returns the name of the group d is ranked in.
+func (d drinkValue) group(by string) string {
+	switch by {
+	case drinkGroupVenue:
+		// Venue names aren't unique.
+		return d.VenueName + "\x00" + strconv.Itoa(d.VenueID)
+	case drinkGroupTown:
+		return d.Town
+	case drinkGroupCounty:
+		return d.County
+	}
+	return ""
+}
+
+// rankDrinkValues orders values by group, then best value first: cheapest
+// per unit of alcohol, or with rank "litre" per litre, keeping the top (0
+// for all) of each group. Ranking by unit leaves out alcohol-free drinks.
+func rankDrinkValues(values []drinkValue, by, rank string, top int) []drinkValue {
+	metric := func(d drinkValue) float64 { return d.PricePerUnit }
+	if rank == drinkRankLitre {
+		metric = func(d drinkValue) float64 { return d.PricePerLitre }
+	}
+	var ranked []drinkValue
+	for _, d := range values {
+		if metric(d) > 0 {
+			ranked = append(ranked, d)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if gi, gj := ranked[i].group(by), ranked[j].group(by); gi != gj {
+			return gi < gj
+		}
+		return metric(ranked[i]) < metric(ranked[j])
+	})
+	if top <= 0 {
+		return ranked
+	}
+
+	out := ranked[:0]
+	n := 0
+	for i, d := range ranked {
+		if i > 0 && d.group(by) != ranked[i-1].group(by) {
+			n = 0
+		}
+		if n < top {
+			out = append(out, d)
+		}
+		n++
+	}
+	return out
+}
+
+// runDrinkValue implements the "drink-value" subcommand: it fetches the
+// menus of the selected venues and ranks drinks by price per unit of
+// alcohol or per litre, across the selection or per venue or region.
+func runDrinkValue(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("get_spoons drink-value", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	near := fs.String("near", "", "Only include venues near this location, as 'lat,lng'")
+	radius := fs.Float64("radius", 5, "Radius in km for -near")
+	venueIDs := fs.String("venues", "", "Only include these venue IDs (comma-separated)")
+	limit := fs.Int("limit", 0, "Limit number of venues (0 for all)")
+	by := fs.String("by", drinkGroupAll, "Rank drinks per venue, town or county, or across all selected venues (all)")
+	rank := fs.String("rank", drinkRankUnit, "Rank by price per unit of alcohol (unit) or per litre (litre)")
+	top := fs.Int("top", 10, "Number of drinks listed per group (0 for all)")
+	format := fs.String("format", "text", "Output format: text, json or csv")
+	output := fs.String("output", "", "Output file path (default: stdout)")
+	concurrency := fs.Int("concurrency", 4, "Number of concurrent requests")
+	retries := fs.Int("retries", 1, "Number of times to retry failed detail, menu and item requests")
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+	switch *by {
+	case drinkGroupAll, drinkGroupVenue, drinkGroupTown, drinkGroupCounty:
+	default:
+		return fmt.Errorf("invalid -by %q: must be all, venue, town or county", *by)
+	}
+	if *rank != drinkRankUnit && *rank != drinkRankLitre {
+		return fmt.Errorf("invalid -rank %q: must be unit or litre", *rank)
+	}
+	if *format != "text" && *format != "json" && *format != "csv" {
+		return fmt.Errorf("invalid -format %q: must be text, json or csv", *format)
+	}
+	filter, err := parseWatchFilter(*near, *radius, *venueIDs)
+	if err != nil {
+		return err
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+	venues, items, partial, err := fetchVenueItems(client, filter, *limit, expandOptions{Concurrency: *concurrency, Retries: *retries})
+	if err != nil {
+		return err
+	}
+	var values []drinkValue
+	for _, v := range venues {
+		values = append(values, drinkValues(v, items[v.ID])...)
+	}
+	ranked := rankDrinkValues(values, *by, *rank, *top)
+	slog.Info("Ranked drinks by value", "venues", len(venues), "portions", len(values), "by", *by, "rank", *rank)
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(ranked)
+	case "csv":
+		err = writeDrinkValueCSV(w, ranked)
+	default:
+		err = writeDrinkValueText(w, ranked, *by)
+	}
+	if err != nil {
+		return err
+	}
+	return partial
+}
+
+func writeDrinkValueText(w io.Writer, values []drinkValue, by string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	// Venues and towns have their own columns.
+	grouped := by == drinkGroupCounty
+	if grouped {
+		fmt.Fprint(tw, "COUNTY\t")
+	}
+	fmt.Fprintln(tw, "ITEM\tPORTION\tML\tABV\tPRICE\t£/LITRE\t£/UNIT\tVENUE\tTOWN")
+	for _, d := range values {
+		if grouped {
+			fmt.Fprintf(tw, "%s\t", d.County)
+		}
+		perUnit := "-"
+		if d.PricePerUnit > 0 {
+			perUnit = fmt.Sprintf("£%.2f", d.PricePerUnit)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.0f\t%.1f%%\t£%.2f\t£%.2f\t%s\t%s\t%s\n",
+			d.Item, d.Portion, d.VolumeML, d.ABV, d.Price, d.PricePerLitre, perUnit, d.VenueName, d.Town)
+	}
+	return tw.Flush()
+}
+
+func writeDrinkValueCSV(w io.Writer, values []drinkValue) error {
+	cw := csv.NewWriter(w)
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	cw.Write([]string{"venueId", "venueName", "town", "county", "item", "category", "portion", "volumeMl", "abv", "price", "units", "pricePerLitre", "pricePerUnit"})
+	for _, d := range values {
+		cw.Write([]string{strconv.Itoa(d.VenueID), d.VenueName, d.Town, d.County, d.Item, d.Category, d.Portion,
+			f(d.VolumeML), f(d.ABV), f(d.Price), f(d.Units), f(d.PricePerLitre), f(d.PricePerUnit)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
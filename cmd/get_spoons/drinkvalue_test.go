@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+)
+
+func TestDrinkValues(t *testing.T) {
+	v := jdw.Venue{ID: 1, Name: "The Moon Under Water", Address: jdw.Address{Town: "Manchester", County: "Greater Manchester"}}
+	items := []menuItem{
+		{Name: "Stella Artois", Description: "Premium Belgian lager, 4.6% ABV", Portions: []portionPrice{{"Half", 2.35}, {"Pint", 4.49}}},
+		// Listed again on another menu.
+		{Name: "Stella Artois", Description: "Premium Belgian lager, 4.6% ABV", Portions: []portionPrice{{"Pint", 4.49}}},
+		{Name: "Corona", Description: "Mexican beer. 330ml bottle, 4.5% ABV.", Portions: []portionPrice{{"Bottle", 3.99}}},
+		{Name: "Heineken 0.0", Description: "Alcohol-free lager, 0.0% ABV", Portions: []portionPrice{{"330ml bottle", 2.5}}},
+		{Name: "Guinness", Description: "Irish stout, 4.1% ABV", OutOfStock: true, Portions: []portionPrice{{"Pint", 4.69}}},
+		// Without an ABV, portions named like measures aren't drinks.
+		{Name: "Beef Burger", Portions: []portionPrice{{"Single", 7.99}, {"Double", 9.99}}},
+		{Name: "Coca-Cola", Portions: []portionPrice{{"Half pint", 1.5}}},
+	}
+
+	values := drinkValues(v, items)
+	if len(values) != 4 {
+		t.Fatalf("Expected 4 portions, got %+v", values)
+	}
+	pint := values[1]
+	if pint.Portion != "Pint" || pint.VolumeML != 568 || pint.Units != 2.61 || pint.PricePerLitre != 7.9 || pint.PricePerUnit != 1.72 || pint.County != "Greater Manchester" {
+		t.Errorf("Unexpected value for a pint of Stella: %+v", pint)
+	}
+	if corona := values[2]; corona.VolumeML != 330 || corona.PricePerUnit != 2.69 {
+		t.Errorf("Expected Corona's volume from its description, got %+v", corona)
+	}
+
+	byUnit := rankDrinkValues(values, drinkGroupAll, drinkRankUnit, 0)
+	if len(byUnit) != 3 || byUnit[0].Portion != "Pint" || byUnit[2].Item != "Corona" {
+		t.Errorf("Expected a pint of Stella first and no alcohol-free drinks, got %+v", byUnit)
+	}
+	byLitre := rankDrinkValues(values, drinkGroupAll, drinkRankLitre, 1)
+	if len(byLitre) != 1 || byLitre[0].Item != "Heineken 0.0" || byLitre[0].PricePerLitre != 7.58 {
+		t.Errorf("Expected Heineken 0.0 to be cheapest per litre, got %+v", byLitre)
+	}
+
+	other := jdw.Venue{ID: 2, Name: "The Moon Under Water", Address: jdw.Address{Town: "Leeds"}}
+	values = append(values, drinkValues(other, items[:1])...)
+	var got []string
+	for _, d := range rankDrinkValues(values, drinkGroupVenue, drinkRankUnit, 1) {
+		got = append(got, d.Town+" "+d.Portion)
+	}
+	if strings.Join(got, ",") != "Manchester Pint,Leeds Pint" {
+		t.Errorf("Expected the best drink at each of the two venues, got %v", got)
+	}
+}
+
+func TestDrinkValue(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 4})
+	defer fake.Close()
+	client := []string{"-api-url", fake.URL, "-token", fake.Token()}
+
+	var out strings.Builder
+	if err := runDrinkValue(append(client, "-format", "json", "-by", "town", "-top", "1"), &out); err != nil {
+		t.Fatalf("drink-value failed: %v", err)
+	}
+	var values []drinkValue
+	if err := json.Unmarshal([]byte(out.String()), &values); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out.String())
+	}
+	towns := make(map[string]bool)
+	for _, v := range fake.Dataset.Venues {
+		towns[v.Address.Town] = true
+	}
+	if len(values) != len(towns) {
+		t.Errorf("Expected the best drink in each of %d towns, got %+v", len(towns), values)
+	}
+	for _, d := range values {
+		// Ruddles Best is the cheapest beer per unit by some way.
+		if d.Item != "Ruddles Best" || d.PricePerUnit <= 0 {
+			t.Errorf("Expected Ruddles Best to be the best value, got %+v", d)
+		}
+	}
+
+	out.Reset()
+	if err := runDrinkValue(append(client, "-format", "csv", "-rank", "litre", "-top", "0", "-limit", "1"), &out); err != nil {
+		t.Fatalf("drink-value -format csv failed: %v", err)
+	}
+	// 5 beers in halves and pints.
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) > 11 || !strings.HasPrefix(lines[0], "venueId,venueName") {
+		t.Errorf("Expected a CSV header and up to 10 rows, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runDrinkValue(append(client, "-by", "county", "-top", "2"), &out); err != nil {
+		t.Fatalf("drink-value failed: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "COUNTY") || !strings.Contains(out.String(), "£/UNIT") {
+		t.Errorf("Expected a table by county:\n%s", out.String())
+	}
+
+	for _, args := range [][]string{{"-by", "region"}, {"-rank", "flavour"}, {"-format", "xml"}} {
+		if err := runDrinkValue(append(client, args...), &out); err == nil {
+			t.Errorf("Expected %v to fail", args)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// runExporter implements the "exporter" subcommand: a Prometheus exporter
+// serving estate and scrape-health metrics on /metrics, refreshed on a
+// schedule.
+func runExporter(args []string) error {
+	fs := flag.NewFlagSet("get_spoons exporter", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	addr := fs.String("addr", "localhost:9477", "Address to listen on")
+	refresh := fs.Duration("refresh", 15*time.Minute, "How often to refresh the metrics")
+	items := fs.Bool("items", true, "Fetch menus and items for open venues (out-of-stock and price metrics)")
+	concurrency := fs.Int("concurrency", 4, "Number of concurrent requests when fetching items")
+	retries := fs.Int("retries", 1, "Number of times to retry failed detail, menu and item requests")
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+	if *refresh <= 0 {
+		return fmt.Errorf("-refresh must be positive")
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+
+	e := newExporter(client, expandOptions{
+		Concurrency:  *concurrency,
+		IncludeMenus: *items,
+		IncludeItems: *items,
+		Retries:      *retries,
+	})
+	e.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The first refresh runs in the background so /metrics is available
+	// (with scrape-health metrics) while a full crawl is in progress.
+	go e.refreshEvery(ctx, *refresh)
+
+	srv := &http.Server{Addr: *addr, Handler: e.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("Serving metrics", "url", "http://"+*addr+"/metrics")
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// exporter holds the Prometheus metrics published by "exporter".
+type exporter struct {
+	client   *jdw.Client
+	opts     expandOptions
+	registry *prometheus.Registry
+
+	estate *estateCollector
+
+	apiRequests *prometheus.CounterVec
+	apiErrors   *prometheus.CounterVec
+	apiDuration *prometheus.HistogramVec
+
+	refreshes       *prometheus.CounterVec
+	lastRefresh     prometheus.Gauge
+	refreshDuration prometheus.Gauge
+	crawlFailures   prometheus.Gauge
+}
+
+func newExporter(client *jdw.Client, opts expandOptions) *exporter {
+	e := &exporter{
+		client:   client,
+		opts:     opts,
+		registry: prometheus.NewRegistry(),
+
+		estate: newEstateCollector(),
+
+		apiRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "jdw_api_requests_total",
+			Help: "JDW API calls by endpoint and HTTP status code (\"cached\" for cache hits, \"error\" when no response was received).",
+		}, []string{"endpoint", "code"}),
+		apiErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "jdw_api_errors_total",
+			Help: "Failed JDW API calls by endpoint and error class.",
+		}, []string{"endpoint", "class"}),
+		apiDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "jdw_api_request_duration_seconds",
+			Help:    "Latency of JDW API calls by endpoint.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		}, []string{"endpoint"}),
+
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "jdw_exporter_refreshes_total",
+			Help: "Metric refreshes by result (success or failure).",
+		}, []string{"result"}),
+		lastRefresh: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "jdw_exporter_last_refresh_timestamp_seconds",
+			Help: "Unix time of the last successful refresh.",
+		}),
+		refreshDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "jdw_exporter_refresh_duration_seconds",
+			Help: "Duration of the last successful refresh.",
+		}),
+		crawlFailures: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "jdw_exporter_crawl_failures",
+			Help: "Number of detail, menu and item requests that failed during the last refresh.",
+		}),
+	}
+
+	e.registry.MustRegister(
+		e.estate,
+		e.apiRequests, e.apiErrors, e.apiDuration,
+		e.refreshes, e.lastRefresh, e.refreshDuration, e.crawlFailures,
+	)
+	client.SetRequestObserver(e.observe)
+	return e
+}
+
+// observe records scrape-health metrics for every API call.
+func (e *exporter) observe(info jdw.RequestInfo) {
+	code := strconv.Itoa(info.StatusCode)
+	switch {
+	case info.Cached:
+		code = "cached"
+	case info.StatusCode == 0:
+		code = "error"
+	}
+	e.apiRequests.WithLabelValues(info.Endpoint, code).Inc()
+	if !info.Cached {
+		e.apiDuration.WithLabelValues(info.Endpoint).Observe(info.Duration.Seconds())
+	}
+	if info.Err != nil {
+		e.apiErrors.WithLabelValues(info.Endpoint, classifyError(info.Err)).Inc()
+	}
+}
+
+func (e *exporter) refreshEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := e.refresh(); err != nil {
+			slog.Error("Refreshing metrics failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh fetches the venue list, and menus and items for open venues when
+// enabled, and updates the estate metrics.
+func (e *exporter) refresh() error {
+	start := time.Now()
+	venues, err := e.client.GetVenues()
+	if err != nil {
+		e.refreshes.WithLabelValues("failure").Inc()
+		return err
+	}
+
+	snap := estateSnapshot{venues: make(map[[3]string]int)}
+	for _, v := range venues {
+		snap.venues[[3]string{v.Status, v.Type, v.Franchise}]++
+		if v.IsClosed {
+			snap.closed++
+		}
+	}
+
+	failures := 0
+	if e.opts.IncludeItems {
+		var open []jdw.Venue
+		for _, v := range venues {
+			if !v.IsClosed {
+				open = append(open, v)
+			}
+		}
+		details, failed := expandVenues(e.client, open, e.opts)
+		var items []menuItem
+		for _, d := range details {
+			items = append(items, extractItems(d)...)
+		}
+		snap.setItems(items)
+		failures = len(failed)
+	}
+
+	// Scrapes see the previous snapshot until this one is complete.
+	e.estate.set(snap)
+	if e.opts.IncludeItems {
+		e.crawlFailures.Set(float64(failures))
+	}
+	e.refreshes.WithLabelValues("success").Inc()
+	e.lastRefresh.Set(float64(time.Now().Unix()))
+	e.refreshDuration.Set(time.Since(start).Seconds())
+	return nil
+}
+
+// estateSnapshot holds the estate metrics of one complete refresh.
+type estateSnapshot struct {
+	venues     map[[3]string]int // by status, type and franchise
+	closed     int
+	items      int
+	outOfStock int
+	prices     map[[2]string]float64 // median by item and portion
+	hasItems   bool                  // whether items were fetched
+}
+
+func (s *estateSnapshot) setItems(items []menuItem) {
+	prices := make(map[[2]string][]float64)
+	for _, it := range items {
+		if it.OutOfStock {
+			s.outOfStock++
+		}
+		for _, p := range it.Portions {
+			key := [2]string{it.Name, p.Label}
+			prices[key] = append(prices[key], p.Price)
+		}
+	}
+	s.items = len(items)
+	s.hasItems = true
+	s.prices = make(map[[2]string]float64, len(prices))
+	for key, values := range prices {
+		s.prices[key] = median(values)
+	}
+}
+
+// estateCollector is a prometheus.Collector serving the estate metrics of
+// the last complete refresh, so a scrape during a refresh never sees a
+// partly updated estate.
+type estateCollector struct {
+	venues          *prometheus.Desc
+	venuesClosed    *prometheus.Desc
+	items           *prometheus.Desc
+	itemsOutOfStock *prometheus.Desc
+	itemPrice       *prometheus.Desc
+
+	mu   sync.RWMutex
+	snap estateSnapshot
+	ok   bool // whether a refresh has completed
+}
+
+func newEstateCollector() *estateCollector {
+	return &estateCollector{
+		venues: prometheus.NewDesc("jdw_venues",
+			"Number of venues by status, type and franchise.",
+			[]string{"status", "type", "franchise"}, nil),
+		venuesClosed: prometheus.NewDesc("jdw_venues_closed",
+			"Number of venues marked as closed.", nil, nil),
+		items: prometheus.NewDesc("jdw_menu_items",
+			"Number of menu items across all fetched venues.", nil, nil),
+		itemsOutOfStock: prometheus.NewDesc("jdw_menu_items_out_of_stock",
+			"Number of menu items marked as out of stock across all fetched venues.", nil, nil),
+		itemPrice: prometheus.NewDesc("jdw_item_price_median_pounds",
+			"Median price of an item portion across all fetched venues.",
+			[]string{"item", "portion"}, nil),
+	}
+}
+
+func (c *estateCollector) set(snap estateSnapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snap = snap
+	c.ok = true
+}
+
+func (c *estateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.venues
+	ch <- c.venuesClosed
+	ch <- c.items
+	ch <- c.itemsOutOfStock
+	ch <- c.itemPrice
+}
+
+func (c *estateCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	// Until the first refresh completes, the estate is unknown rather than
+	// empty.
+	if !c.ok {
+		return
+	}
+	for key, n := range c.snap.venues {
+		ch <- prometheus.MustNewConstMetric(c.venues, prometheus.GaugeValue, float64(n), key[0], key[1], key[2])
+	}
+	ch <- prometheus.MustNewConstMetric(c.venuesClosed, prometheus.GaugeValue, float64(c.snap.closed))
+	if !c.snap.hasItems {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.items, prometheus.GaugeValue, float64(c.snap.items))
+	ch <- prometheus.MustNewConstMetric(c.itemsOutOfStock, prometheus.GaugeValue, float64(c.snap.outOfStock))
+	for key, price := range c.snap.prices {
+		ch <- prometheus.MustNewConstMetric(c.itemPrice, prometheus.GaugeValue, price, key[0], key[1])
+	}
+}
+
+func (e *exporter) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+// median returns the median of values, which must not be empty. values is
+// sorted in place.
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+)
+
+func scrapeMetrics(t *testing.T, e *exporter) string {
+	t.Helper()
+	srv := httptest.NewServer(e.handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Reading metrics failed: %v", err)
+	}
+	return string(body)
+}
+
+func TestExporterMetrics(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 12})
+	defer fake.Close()
+
+	e := newExporter(fake.NewClient(), expandOptions{Concurrency: 4, IncludeMenus: true, IncludeItems: true})
+	if err := e.refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+
+	open, closed, outOfStock, items := 0, 0, 0, 0
+	for _, v := range fake.Dataset.Venues {
+		if v.IsClosed {
+			closed++
+			continue
+		}
+		open++
+		for _, m := range fake.Dataset.Menus[v.VenueRef] {
+			for _, c := range m.Categories {
+				for _, g := range c.ItemGroups {
+					for _, it := range g.Items {
+						items++
+						if it.IsOutOfStock {
+							outOfStock++
+						}
+					}
+				}
+			}
+		}
+	}
+
+	metrics := scrapeMetrics(t, e)
+	for _, want := range []string{
+		fmt.Sprintf(`jdw_venues{franchise="",status="open",type="pub"} %d`, open),
+		fmt.Sprintf("jdw_venues_closed %d", closed),
+		fmt.Sprintf("jdw_menu_items %d", items),
+		fmt.Sprintf("jdw_menu_items_out_of_stock %d", outOfStock),
+		`jdw_item_price_median_pounds{item="Stella Artois",portion="Pint"}`,
+		`jdw_api_requests_total{code="200",endpoint="venues"} 1`,
+		fmt.Sprintf(`jdw_api_requests_total{code="200",endpoint="menus"} %d`, open),
+		`jdw_api_request_duration_seconds_count{endpoint="menu_items"}`,
+		`jdw_exporter_refreshes_total{result="success"} 1`,
+		"jdw_exporter_crawl_failures 0",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+}
+
+func TestExporterErrors(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 4})
+	defer fake.Close()
+
+	e := newExporter(fake.NewClient(), expandOptions{Concurrency: 1, IncludeMenus: true, IncludeItems: true})
+	fake.FailEndpoint(jdw.EndpointMenus, http.StatusNotFound)
+	if err := e.refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+
+	fake.RejectAuth(true)
+	if err := e.refresh(); err == nil {
+		t.Error("Expected refresh to fail when the venue list is unauthorized")
+	}
+
+	metrics := scrapeMetrics(t, e)
+	for _, want := range []string{
+		`jdw_api_errors_total{class="not_found",endpoint="menus"}`,
+		`jdw_api_errors_total{class="auth",endpoint="venues"} 1`,
+		`jdw_api_requests_total{code="401",endpoint="venues"} 1`,
+		`jdw_exporter_refreshes_total{result="failure"} 1`,
+		`jdw_exporter_refreshes_total{result="success"} 1`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+	if strings.Contains(metrics, "jdw_exporter_crawl_failures 0") {
+		t.Error("Expected crawl failures to be reported")
+	}
+}
+
+func TestExporterOmitsUnknownEstate(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 4})
+	defer fake.Close()
+
+	e := newExporter(fake.NewClient(), expandOptions{})
+	metrics := scrapeMetrics(t, e)
+	for _, name := range []string{"jdw_venues", "jdw_venues_closed", "jdw_menu_items"} {
+		if strings.Contains(metrics, "\n"+name+" ") || strings.Contains(metrics, "\n"+name+"{") {
+			t.Errorf("Expected no %s before the first refresh", name)
+		}
+	}
+
+	// Without items, only the venue metrics are reported.
+	if err := e.refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	metrics = scrapeMetrics(t, e)
+	if !strings.Contains(metrics, "\njdw_venues_closed ") {
+		t.Error("Expected jdw_venues_closed after a refresh")
+	}
+	if strings.Contains(metrics, "\njdw_menu_items") {
+		t.Error("Expected no item metrics with items disabled")
+	}
+}
+
+func TestExporterServesCompleteRefresh(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 4})
+	defer fake.Close()
+
+	client := fake.NewClient()
+	e := newExporter(client, expandOptions{Concurrency: 1, IncludeMenus: true, IncludeItems: true})
+	if err := e.refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	before := scrapeMetrics(t, e)
+
+	// Scrape while the next refresh is fetching menus. The observer runs on a
+	// crawl goroutine, so the scrape is checked afterwards.
+	srv := httptest.NewServer(e.handler())
+	defer srv.Close()
+	var during string
+	client.SetRequestObserver(func(info jdw.RequestInfo) {
+		e.observe(info)
+		if info.Endpoint != jdw.EndpointMenus || during != "" {
+			return
+		}
+		if resp, err := http.Get(srv.URL + "/metrics"); err == nil {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			during = string(body)
+		}
+	})
+	if err := e.refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if during == "" {
+		t.Fatal("Expected a scrape during the refresh")
+	}
+	for _, line := range strings.Split(before, "\n") {
+		if strings.HasPrefix(line, "jdw_venues") || strings.HasPrefix(line, "jdw_menu_items") || strings.HasPrefix(line, "jdw_item_price_median_pounds") {
+			if !strings.Contains(during, line+"\n") {
+				t.Errorf("Expected %q to be served until the refresh completes", line)
+			}
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	if m := median([]float64{3, 1, 2}); m != 2 {
+		t.Errorf("Expected median 2, got %v", m)
+	}
+	if m := median([]float64{4, 1, 3, 2}); m != 2.5 {
+		t.Errorf("Expected median 2.5, got %v", m)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// Exit codes returned by the CLI.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitPartial = 2
+)
+
+// Endpoint names used in failure reports.
+const (
+	endpointVenueDetails = "GetVenueDetails"
+	endpointMenus        = "GetMenus"
+	endpointMenuItems    = "GetMenuItems"
+)
+
+// Error classes used in failure reports.
+const (
+	classAuth         = "auth"
+	classNotFound     = "not_found"
+	classRateLimited  = "rate_limited"
+	classServerError  = "server_error"
+	classHTTPError    = "http_error"
+	classAPIFailure   = "api_failure"
+	classDecodeError  = "decode_error"
+	classNetworkError = "network_error"
+	classCacheMiss    = "cache_miss"
+	classNoFixture    = "fixture_missing"
+	classUnknown      = "unknown"
+)
+
+// retryBackoff is the base delay between retries. It is multiplied by the
+// attempt number, giving a simple linear backoff.
+var retryBackoff = 500 * time.Millisecond
+
+// crawlFailure records a single failed request made while expanding a venue.
+type crawlFailure struct {
+	VenueID     int    `json:"venueId"`
+	VenueRef    int    `json:"venueRef"`
+	VenueName   string `json:"venueName,omitempty"`
+	Endpoint    string `json:"endpoint"`
+	SalesAreaID int    `json:"salesAreaId,omitempty"`
+	MenuID      int    `json:"menuId,omitempty"`
+	Class       string `json:"class"`
+	Attempts    int    `json:"attempts"`
+	Error       string `json:"error"`
+}
+
+// failureReport is the machine-readable summary written by -failure-report.
+type failureReport struct {
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Venues      int            `json:"venues"`
+	Expanded    int            `json:"expanded"`
+	Failures    []crawlFailure `json:"failures"`
+}
+
+// partialError is returned by Run when output was written but some requests
+// failed without exceeding -max-failures.
+type partialError struct {
+	failures int
+	venues   int
+}
+
+func (e *partialError) Error() string {
+	return fmt.Sprintf("partial crawl: %d request(s) failed across %d venues", e.failures, e.venues)
+}
+
+// exitCode reports err to stderr and maps it to the process exit code.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var pe *partialError
+	if errors.As(err, &pe) {
+		slog.Warn(err.Error())
+		return exitPartial
+	}
+	slog.Error(err.Error())
+	return exitFailure
+}
+
+// classifyError maps an error returned by the jdw client to a coarse class
+// suitable for alerting.
+func classifyError(err error) string {
+	var (
+		apiErr    *jdw.APIError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		netErr    net.Error
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+			return classAuth
+		case apiErr.StatusCode == http.StatusNotFound:
+			return classNotFound
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return classRateLimited
+		case apiErr.StatusCode >= 500:
+			return classServerError
+		default:
+			return classHTTPError
+		}
+	case errors.Is(err, jdw.ErrAPIFailure):
+		return classAPIFailure
+	case errors.Is(err, jdw.ErrCacheMiss):
+		return classCacheMiss
+	case errors.Is(err, jdw.ErrFixtureNotFound):
+		return classNoFixture
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return classDecodeError
+	case errors.As(err, &netErr):
+		return classNetworkError
+	default:
+		return classUnknown
+	}
+}
+
+// isRetryable reports whether a failure of the given class may succeed on retry.
+func isRetryable(class string) bool {
+	switch class {
+	case classRateLimited, classServerError, classNetworkError:
+		return true
+	}
+	return false
+}
+
+// withRetry calls fn until it succeeds, returns a non-retryable error, or has
+// been retried the given number of times. It returns the number of attempts made.
+func withRetry(retries int, fn func() error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > retries || !isRetryable(classifyError(err)) {
+			return attempt, err
+		}
+		time.Sleep(retryBackoff * time.Duration(attempt))
+	}
+}
+
+// writeFailureReport writes report to path atomically, so a report that
+// couldn't be written in full is never left behind.
+func writeFailureReport(path string, report failureReport) error {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, report); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Auth", &jdw.APIError{StatusCode: 401, Status: "401 Unauthorized"}, classAuth},
+		{"Forbidden", &jdw.APIError{StatusCode: 403, Status: "403 Forbidden"}, classAuth},
+		{"NotFound", &jdw.APIError{StatusCode: 404, Status: "404 Not Found"}, classNotFound},
+		{"RateLimited", &jdw.APIError{StatusCode: 429, Status: "429 Too Many Requests"}, classRateLimited},
+		{"ServerError", &jdw.APIError{StatusCode: 502, Status: "502 Bad Gateway"}, classServerError},
+		{"HTTPError", &jdw.APIError{StatusCode: 418, Status: "418 I'm a teapot"}, classHTTPError},
+		{"APIFailure", jdw.ErrAPIFailure, classAPIFailure},
+		{"CacheMiss", fmt.Errorf("%w: /api/v0.1/venues", jdw.ErrCacheMiss), classCacheMiss},
+		{"NoFixture", &url.Error{Op: "Get", URL: "/", Err: jdw.ErrFixtureNotFound}, classNoFixture},
+		{"Decode", &json.SyntaxError{}, classDecodeError},
+		{"Wrapped", fmt.Errorf("wrapped: %w", jdw.ErrAPIFailure), classAPIFailure},
+		{"Unknown", errors.New("boom"), classUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	oldBackoff := retryBackoff
+	retryBackoff = 0
+	defer func() { retryBackoff = oldBackoff }()
+
+	t.Run("RetriesServerErrors", func(t *testing.T) {
+		calls := 0
+		attempts, err := withRetry(2, func() error {
+			calls++
+			if calls < 3 {
+				return &jdw.APIError{StatusCode: 500, Status: "500 Internal Server Error"}
+			}
+			return nil
+		})
+		if err != nil || attempts != 3 {
+			t.Errorf("Expected success after 3 attempts, got %d attempts, err %v", attempts, err)
+		}
+	})
+
+	t.Run("DoesNotRetryAuth", func(t *testing.T) {
+		attempts, err := withRetry(5, func() error {
+			return &jdw.APIError{StatusCode: 401, Status: "401 Unauthorized"}
+		})
+		if err == nil || attempts != 1 {
+			t.Errorf("Expected failure after 1 attempt, got %d attempts, err %v", attempts, err)
+		}
+	})
+
+	t.Run("GivesUp", func(t *testing.T) {
+		attempts, err := withRetry(1, func() error {
+			return &jdw.APIError{StatusCode: 503, Status: "503 Service Unavailable"}
+		})
+		if err == nil || attempts != 2 {
+			t.Errorf("Expected failure after 2 attempts, got %d attempts, err %v", attempts, err)
+		}
+	})
+}
+
+func TestExitCode(t *testing.T) {
+	if got := exitCode(nil); got != exitOK {
+		t.Errorf("Expected %d for nil, got %d", exitOK, got)
+	}
+	if got := exitCode(&partialError{failures: 1, venues: 2}); got != exitPartial {
+		t.Errorf("Expected %d for partial error, got %d", exitPartial, got)
+	}
+	if got := exitCode(errors.New("boom")); got != exitFailure {
+		t.Errorf("Expected %d for error, got %d", exitFailure, got)
+	}
+}
+
+func TestRunFailureReport(t *testing.T) {
+	oldBackoff := retryBackoff
+	retryBackoff = 0
+	defer func() { retryBackoff = oldBackoff }()
+
+	var detailCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/venues"):
+			fmt.Fprint(w, `{"success": true, "data": [{"id": 1, "venueRef": 10, "name": "Good"}, {"id": 2, "venueRef": 20, "name": "Bad"}]}`)
+		case strings.HasSuffix(r.URL.Path, "/venues/20"):
+			detailCalls.Add(1)
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			fmt.Fprint(w, `{"success": true, "data": {"id": 1, "venueRef": 10}}`)
+		}
+	}))
+	defer server.Close()
+
+	os.Setenv("JDW_API_URL", server.URL)
+	defer os.Unsetenv("JDW_API_URL")
+	os.Setenv("JDW_TOKEN", "test-token")
+	defer os.Unsetenv("JDW_TOKEN")
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "venues.json")
+
+	t.Run("Partial", func(t *testing.T) {
+		err := Run([]string{"-expand", "-retries", "2", "-output", output})
+		var pe *partialError
+		if !errors.As(err, &pe) {
+			t.Fatalf("Expected partial error, got %v", err)
+		}
+		if got := detailCalls.Load(); got != 3 {
+			t.Errorf("Expected 3 attempts for failing venue, got %d", got)
+		}
+
+		data, err := os.ReadFile(output + ".failures.json")
+		if err != nil {
+			t.Fatalf("Expected failure report alongside output: %v", err)
+		}
+		var report failureReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatalf("Invalid failure report: %v", err)
+		}
+		if report.Venues != 2 || report.Expanded != 1 || len(report.Failures) != 1 {
+			t.Fatalf("Unexpected report: %+v", report)
+		}
+		f := report.Failures[0]
+		if f.VenueRef != 20 || f.Endpoint != endpointVenueDetails || f.Class != classServerError || f.Attempts != 3 {
+			t.Errorf("Unexpected failure entry: %+v", f)
+		}
+	})
+
+	t.Run("MaxFailuresExceeded", func(t *testing.T) {
+		reportPath := filepath.Join(dir, "report.json")
+		err := Run([]string{"-expand", "-max-failures", "0", "-output", output, "-failure-report", reportPath})
+		if err == nil || exitCode(err) != exitFailure {
+			t.Fatalf("Expected failure exit code, got %v", err)
+		}
+		if _, err := os.Stat(reportPath); err != nil {
+			t.Errorf("Expected failure report at %s: %v", reportPath, err)
+		}
+	})
+
+	t.Run("AllFailed", func(t *testing.T) {
+		reportPath := filepath.Join(dir, "all-failed.json")
+		err := Run([]string{"-expand", "-search", "bad", "-no-fuzzy", "-output", output, "-failure-report", reportPath})
+		if exitCode(err) != exitFailure || !strings.Contains(err.Error(), "no venues could be expanded") {
+			t.Fatalf("Expected failure exit code when every venue fails, got %v", err)
+		}
+		data, err := os.ReadFile(reportPath)
+		if err != nil {
+			t.Fatalf("Expected failure report at %s: %v", reportPath, err)
+		}
+		var report failureReport
+		if err := json.Unmarshal(data, &report); err != nil || report.Venues != 1 || report.Expanded != 0 {
+			t.Errorf("Unexpected report: %+v (%v)", report, err)
+		}
+	})
+
+	t.Run("WithinMaxFailures", func(t *testing.T) {
+		err := Run([]string{"-expand", "-max-failures", "1", "-output", output})
+		if exitCode(err) != exitPartial {
+			t.Errorf("Expected partial exit code, got %v", err)
+		}
+	})
+}
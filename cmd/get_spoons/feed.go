@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// runFeed implements the "feed" subcommand: it compares the current fetch with
+// the snapshot in the state file and writes Atom and RSS 2.0 feeds of the
+// changes. Entries are kept in the state file so that their IDs stay stable
+// across runs.
+func runFeed(args []string) error {
+	fs := flag.NewFlagSet("get_spoons feed", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	statePath := fs.String("state", "", "State file holding the previous snapshot and feed entries (created if missing)")
+	atomPath := fs.String("atom", "", "Write an Atom feed to this path")
+	rssPath := fs.String("rss", "", "Write an RSS 2.0 feed to this path")
+	title := fs.String("title", "Wetherspoons estate changes", "Feed title")
+	link := fs.String("link", "https://spoons.ink/", "Site URL the feed belongs to")
+	maxEntries := fs.Int("max-entries", 100, "Maximum number of entries kept in the feed")
+	near := fs.String("near", "", "Only include venues near this location, as 'lat,lng'")
+	radius := fs.Float64("radius", 5, "Radius in km for -near")
+	venueIDs := fs.String("venues", "", "Only include these venue IDs (comma-separated)")
+	trackItems := fs.String("track-items", "", "Track prices of items matching these queries (comma-separated, e.g. 'stella pint,guinness')")
+	concurrency := fs.Int("concurrency", 4, "Number of concurrent requests when tracking items")
+	retries := fs.Int("retries", 1, "Number of times to retry failed detail, menu and item requests")
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+
+	if *statePath == "" {
+		return errors.New("-state is required")
+	}
+	if *atomPath == "" && *rssPath == "" {
+		return errors.New("at least one of -atom or -rss is required")
+	}
+	site, err := url.Parse(*link)
+	if err != nil || site.Host == "" {
+		return fmt.Errorf("invalid -link %q", *link)
+	}
+	filter, err := parseWatchFilter(*near, *radius, *venueIDs)
+	if err != nil {
+		return err
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+
+	state, err := loadFeedState(*statePath)
+	if err != nil {
+		return err
+	}
+
+	w := &watcher{
+		client:  client,
+		filter:  filter,
+		items:   splitList(*trackItems),
+		opts:    expandOptions{Concurrency: *concurrency, IncludeMenus: true, IncludeItems: true, Retries: *retries},
+		message: template.Must(template.New("message").Parse(defaultMessageTemplate)),
+		state:   state.Snapshot,
+	}
+	current, err := w.fetch()
+	if err != nil {
+		return err
+	}
+
+	if state.Snapshot == nil {
+		slog.Info("Recorded baseline", "venues", len(current.Venues))
+	} else {
+		events := diffWatchState(state.Snapshot, current)
+		var added []feedEntry
+		for _, e := range events {
+			if err := w.render(&e); err != nil {
+				return err
+			}
+			added = append(added, feedEntry{ID: feedEntryID(site.Host, e), Event: e})
+		}
+		slog.Info("Found changes", "changes", len(added))
+		state.Entries = append(added, state.Entries...)
+		if *maxEntries >= 0 && len(state.Entries) > *maxEntries {
+			state.Entries = state.Entries[:*maxEntries]
+		}
+	}
+	state.Snapshot = current
+
+	meta := feedMeta{Title: *title, Link: *link, Updated: state.updated()}
+	if *atomPath != "" {
+		if err := writeXMLFile(*atomPath, atomFeed(meta, state.Entries)); err != nil {
+			return err
+		}
+	}
+	if *rssPath != "" {
+		if err := writeXMLFile(*rssPath, rssFeed(meta, state.Entries)); err != nil {
+			return err
+		}
+	}
+	return saveFeedState(*statePath, state)
+}
+
+// feedState is persisted between runs of "feed".
+type feedState struct {
+	Snapshot *watchState `json:"snapshot"`
+	// Entries are newest first.
+	Entries []feedEntry `json:"entries"`
+}
+
+// feedEntry is a change event with its permanent feed ID.
+type feedEntry struct {
+	ID    string      `json:"id"`
+	Event changeEvent `json:"event"`
+}
+
+// updated is the time of the newest entry, so that feeds are byte-identical
+// between runs that find no changes.
+func (s *feedState) updated() time.Time {
+	if len(s.Entries) > 0 {
+		return s.Entries[0].Event.Time
+	}
+	if s.Snapshot != nil {
+		return s.Snapshot.PolledAt
+	}
+	return time.Now().UTC()
+}
+
+func loadFeedState(path string) (*feedState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &feedState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state feedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("reading state %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+func saveFeedState(path string, state *feedState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// feedEntryID returns a tag URI (RFC 4151) identifying e, e.g.
+// "tag:spoons.ink,2025-01-02:venue_closed/1001/1735819200".
+func feedEntryID(host string, e changeEvent) string {
+	id := fmt.Sprintf("tag:%s,%s:%s/%d", host, e.Time.Format("2006-01-02"), e.Type, e.Venue.ID)
+	if e.Item != "" {
+		id += "/" + url.PathEscape(e.Item) + "/" + url.PathEscape(e.Portion)
+	}
+	return fmt.Sprintf("%s/%d", id, e.Time.Unix())
+}
+
+// feedMeta describes the feed as a whole.
+type feedMeta struct {
+	Title   string
+	Link    string
+	Updated time.Time
+}
+
+type atomDoc struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title    string       `xml:"title"`
+	ID       string       `xml:"id"`
+	Updated  string       `xml:"updated"`
+	Category atomCategory `xml:"category"`
+	Summary  string       `xml:"summary"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+func atomFeed(meta feedMeta, entries []feedEntry) atomDoc {
+	doc := atomDoc{
+		Title:   meta.Title,
+		ID:      meta.Link,
+		Updated: meta.Updated.UTC().Format(time.RFC3339),
+		Links:   []atomLink{{Href: meta.Link, Rel: "alternate"}},
+		Author:  atomAuthor{Name: "get_spoons"},
+	}
+	for _, en := range entries {
+		doc.Entries = append(doc.Entries, atomEntry{
+			Title:    en.Event.Message,
+			ID:       en.ID,
+			Updated:  en.Event.Time.UTC().Format(time.RFC3339),
+			Category: atomCategory{Term: en.Event.Type},
+			Summary:  feedSummary(en.Event),
+		})
+	}
+	return doc
+}
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Category    string  `xml:"category"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+func rssFeed(meta feedMeta, entries []feedEntry) rssDoc {
+	doc := rssDoc{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         meta.Title,
+			Link:          meta.Link,
+			Description:   "Venue openings, closures and price changes",
+			LastBuildDate: meta.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, en := range entries {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       en.Event.Message,
+			GUID:        rssGUID{Value: en.ID},
+			PubDate:     en.Event.Time.UTC().Format(time.RFC1123Z),
+			Category:    en.Event.Type,
+			Description: feedSummary(en.Event),
+		})
+	}
+	return doc
+}
+
+// feedSummary describes the venue an event refers to.
+func feedSummary(e changeEvent) string {
+	parts := []string{e.Venue.Name}
+	if e.Venue.Town != "" {
+		parts = append(parts, e.Venue.Town)
+	}
+	if e.Venue.Postcode != "" {
+		parts = append(parts, e.Venue.Postcode)
+	}
+	return fmt.Sprintf("%s (venue %d)", strings.Join(parts, ", "), e.Venue.ID)
+}
+
+// writeXMLFile writes v as an indented XML document to path.
+func writeXMLFile(path string, v interface{}) error {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data := append([]byte(xml.Header), out...)
+	return writeFileAtomic(path, append(data, '\n'))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+)
+
+func TestFeed(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "feed-state.json")
+	atomPath := filepath.Join(dir, "feed.xml")
+	rssPath := filepath.Join(dir, "rss.xml")
+	args := []string{"feed", "-state", statePath, "-atom", atomPath, "-rss", rssPath, "-track-items", "stella pint"}
+
+	runAgainst := func(d *jdwtest.Dataset) {
+		t.Helper()
+		fake := jdwtest.NewServer(jdwtest.Options{Dataset: d})
+		defer fake.Close()
+		os.Setenv("JDW_API_URL", fake.URL)
+		defer os.Unsetenv("JDW_API_URL")
+		os.Setenv("JDW_TOKEN", fake.Token())
+		defer os.Unsetenv("JDW_TOKEN")
+		if err := Run(args); err != nil {
+			t.Fatalf("Run feed failed: %v", err)
+		}
+	}
+	readAtom := func() atomDoc {
+		t.Helper()
+		data, err := os.ReadFile(atomPath)
+		if err != nil {
+			t.Fatalf("Reading Atom feed failed: %v", err)
+		}
+		var doc atomDoc
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("Parsing Atom feed failed: %v", err)
+		}
+		return doc
+	}
+
+	runAgainst(jdwtest.NewDataset(1, 6))
+	if doc := readAtom(); len(doc.Entries) != 0 {
+		t.Fatalf("Expected an empty feed after the baseline run, got %d entries", len(doc.Entries))
+	}
+
+	changed := jdwtest.NewDataset(1, 6)
+	open := openVenues(changed)
+	closing, repriced := &changed.Venues[open[0]], changed.Venues[open[1]]
+	closing.IsClosed, closing.Status = true, "closed"
+	changed.Menus[repriced.VenueRef][0].Categories[0].ItemGroups[0].Items[0].Portions[1].Price += 0.10
+
+	runAgainst(changed)
+	doc := readAtom()
+	if len(doc.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %+v", doc.Entries)
+	}
+	if !strings.Contains(doc.Entries[0].Title, closing.Name+", "+closing.Address.Town+" is now closed") {
+		t.Errorf("Unexpected first entry: %+v", doc.Entries[0])
+	}
+	if doc.Entries[1].Category.Term != eventPriceChanged || !strings.HasPrefix(doc.Entries[1].ID, "tag:spoons.ink,") {
+		t.Errorf("Unexpected second entry: %+v", doc.Entries[1])
+	}
+
+	atomBefore, _ := os.ReadFile(atomPath)
+	rssBefore, _ := os.ReadFile(rssPath)
+
+	// A run without changes keeps the same entries and IDs, so the feeds are
+	// unchanged and readers are not re-notified.
+	runAgainst(changed)
+	atomAfter, _ := os.ReadFile(atomPath)
+	rssAfter, _ := os.ReadFile(rssPath)
+	if string(atomBefore) != string(atomAfter) || string(rssBefore) != string(rssAfter) {
+		t.Error("Expected feeds to be unchanged when nothing changed")
+	}
+
+	var rss rssDoc
+	if err := xml.Unmarshal(rssAfter, &rss); err != nil {
+		t.Fatalf("Parsing RSS feed failed: %v", err)
+	}
+	if rss.Version != "2.0" || len(rss.Channel.Items) != 2 || rss.Channel.Items[0].GUID.Value != doc.Entries[0].ID {
+		t.Errorf("Unexpected RSS feed: %+v", rss)
+	}
+}
+
+func TestFeedRequiresOutput(t *testing.T) {
+	if err := Run([]string{"feed", "-state", filepath.Join(t.TempDir(), "state.json")}); err == nil {
+		t.Error("Expected an error without -atom or -rss")
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// configureFixtures installs a recording or replaying transport on client.
+func configureFixtures(client *jdw.Client, recordDir, replayDir string) error {
+	switch {
+	case recordDir != "" && replayDir != "":
+		return fmt.Errorf("-record and -replay cannot be used together")
+	case recordDir != "":
+		rt, err := jdw.NewRecordingTransport(recordDir, nil)
+		if err != nil {
+			return err
+		}
+		slog.Info("Recording fixtures", "dir", recordDir)
+		client.SetTransport(rt)
+	case replayDir != "":
+		rt, err := jdw.NewReplayTransport(replayDir)
+		if err != nil {
+			return fmt.Errorf("loading fixtures: %w", err)
+		}
+		slog.Info("Replaying fixtures", "fixtures", rt.Len(), "dir", replayDir)
+		client.SetTransport(rt)
+	}
+	return nil
+}
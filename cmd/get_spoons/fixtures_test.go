@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testFixturesDir = "../../jdw/testdata/fixtures"
+
+func TestRunReplay(t *testing.T) {
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := Run([]string{"-replay", testFixturesDir, "-search", "moon under water", "-item-search", "stella"})
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	if err != nil {
+		t.Fatalf("Run -replay failed: %v", err)
+	}
+	out, _ := io.ReadAll(r)
+	if !strings.Contains(string(out), "Stella Artois") {
+		t.Errorf("Expected Stella Artois in replayed output, got %s", string(out))
+	}
+	if strings.Contains(string(out), "Peroni") {
+		t.Errorf("Expected item search to prune other items, got %s", string(out))
+	}
+
+	if err := Run([]string{"-replay", testFixturesDir, "-record", t.TempDir()}); err == nil {
+		t.Error("Expected error when combining -record and -replay")
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//go:embed graphql_schema.graphql
+var graphqlSchema string
+
+// graphqlHandler serves the GraphQL API over the venue and menu graph. Venues
+// come from the snapshot; everything below them is resolved lazily through
+// the jdw.Client, with identical upstream calls deduplicated per request.
+type graphqlHandler struct {
+	api         *apiServer
+	schema      *graphql.Schema
+	concurrency int
+}
+
+func newGraphQLHandler(api *apiServer, concurrency int) *graphqlHandler {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	h := &graphqlHandler{api: api, concurrency: concurrency}
+	h.schema = graphql.MustParseSchema(graphqlSchema, &queryResolver{api: api}, graphql.UseStringDescriptions())
+	return h
+}
+
+func (h *graphqlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	switch r.Method {
+	case http.MethodGet:
+		params.Query = r.URL.Query().Get("query")
+		params.OperationName = r.URL.Query().Get("operationName")
+		if v := r.URL.Query().Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &params.Variables); err != nil {
+				writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid variables: %w", err))
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), loaderKey{}, newRequestLoader(h.api.client, h.concurrency))
+	writeAPIJSON(w, http.StatusOK, h.schema.Exec(ctx, params.Query, params.OperationName, params.Variables))
+}
+
+// loaderKey is the context key for the per-request loader.
+type loaderKey struct{}
+
+// requestLoader memoizes upstream calls for the lifetime of one GraphQL
+// request, so that a query touching the same menu from several fields only
+// calls GetMenuItems once, and limits how many calls run at the same time.
+type requestLoader struct {
+	client *jdw.Client
+	sem    chan struct{}
+
+	mu    sync.Mutex
+	calls map[string]*loaderCall
+}
+
+type loaderCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func newRequestLoader(client *jdw.Client, concurrency int) *requestLoader {
+	return &requestLoader{
+		client: client,
+		sem:    make(chan struct{}, concurrency),
+		calls:  make(map[string]*loaderCall),
+	}
+}
+
+func loaderFrom(ctx context.Context) *requestLoader {
+	return ctx.Value(loaderKey{}).(*requestLoader)
+}
+
+// load returns the result of fetch for key, calling fetch at most once per
+// request with the context of the first caller. Concurrent callers for the
+// same key wait for the first call.
+func (l *requestLoader) load(ctx context.Context, key string, fetch func(context.Context) (interface{}, error)) (interface{}, error) {
+	l.mu.Lock()
+	if c, ok := l.calls[key]; ok {
+		l.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.value, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c := &loaderCall{done: make(chan struct{})}
+	l.calls[key] = c
+	l.mu.Unlock()
+
+	select {
+	case l.sem <- struct{}{}:
+		c.value, c.err = fetch(ctx)
+		<-l.sem
+	case <-ctx.Done():
+		c.err = ctx.Err()
+	}
+	close(c.done)
+	return c.value, c.err
+}
+
+func (l *requestLoader) venueDetails(ctx context.Context, venueRef int) (map[string]interface{}, error) {
+	v, err := l.load(ctx, fmt.Sprintf("details/%d", venueRef), func(ctx context.Context) (interface{}, error) {
+		return l.client.GetVenueDetailsContext(ctx, venueRef)
+	})
+	details, _ := v.(map[string]interface{})
+	return details, err
+}
+
+func (l *requestLoader) menus(ctx context.Context, venueRef, salesAreaID int) ([]interface{}, error) {
+	v, err := l.load(ctx, fmt.Sprintf("menus/%d/%d", venueRef, salesAreaID), func(ctx context.Context) (interface{}, error) {
+		return l.client.GetMenusContext(ctx, venueRef, salesAreaID)
+	})
+	menus, _ := v.([]interface{})
+	return menus, err
+}
+
+func (l *requestLoader) menuItems(ctx context.Context, venueRef, salesAreaID, menuID int) (map[string]interface{}, error) {
+	v, err := l.load(ctx, fmt.Sprintf("items/%d/%d/%d", venueRef, salesAreaID, menuID), func(ctx context.Context) (interface{}, error) {
+		return l.client.GetMenuItemsContext(ctx, venueRef, salesAreaID, menuID)
+	})
+	items, _ := v.(map[string]interface{})
+	return items, err
+}
+
+type queryResolver struct {
+	api *apiServer
+}
+
+func (q *queryResolver) Venues(args struct {
+	Search *string
+	Town   *string
+	Limit  *int32
+	Offset *int32
+}) []*venueResolver {
+	venues, _ := q.api.snapshot()
+	if args.Search != nil && *args.Search != "" {
+		venues = searchVenues(venues, *args.Search, false)
+	}
+
+	var out []*venueResolver
+	for _, v := range venues {
+		if args.Town != nil && !strings.EqualFold(v.Address.Town, *args.Town) {
+			continue
+		}
+		out = append(out, &venueResolver{v: v})
+	}
+
+	if args.Offset != nil && *args.Offset > 0 {
+		if int(*args.Offset) >= len(out) {
+			return []*venueResolver{}
+		}
+		out = out[*args.Offset:]
+	}
+	if args.Limit != nil && *args.Limit >= 0 && int(*args.Limit) < len(out) {
+		out = out[:*args.Limit]
+	}
+	if out == nil {
+		out = []*venueResolver{}
+	}
+	return out
+}
+
+func (q *queryResolver) Venue(args struct{ ID int32 }) *venueResolver {
+	v, ok := q.api.venue(int(args.ID))
+	if !ok {
+		return nil
+	}
+	return &venueResolver{v: v}
+}
+
+type venueResolver struct {
+	v jdw.Venue
+}
+
+func (r *venueResolver) ID() int32         { return int32(r.v.ID) }
+func (r *venueResolver) VenueRef() int32   { return int32(r.v.VenueRef) }
+func (r *venueResolver) Name() string      { return r.v.Name }
+func (r *venueResolver) Status() string    { return r.v.Status }
+func (r *venueResolver) Type() string      { return r.v.Type }
+func (r *venueResolver) IsClosed() bool    { return r.v.IsClosed }
+func (r *venueResolver) Franchise() string { return r.v.Franchise }
+
+func (r *venueResolver) Address() *addressResolver {
+	return &addressResolver{a: r.v.Address}
+}
+
+func (r *venueResolver) SalesAreas(ctx context.Context) ([]*salesAreaResolver, error) {
+	details, err := loaderFrom(ctx).venueDetails(ctx, r.v.VenueRef)
+	if err != nil {
+		return nil, fmt.Errorf("fetching details for venue %d: %w", r.v.ID, err)
+	}
+	out := []*salesAreaResolver{}
+	for _, sa := range mapsIn(details["salesAreas"]) {
+		out = append(out, &salesAreaResolver{venueRef: r.v.VenueRef, raw: sa})
+	}
+	return out, nil
+}
+
+type addressResolver struct {
+	a jdw.Address
+}
+
+func (r *addressResolver) Line1() string      { return r.a.Line1 }
+func (r *addressResolver) Line2() *string     { return r.a.Line2 }
+func (r *addressResolver) Line3() *string     { return r.a.Line3 }
+func (r *addressResolver) Town() string       { return r.a.Town }
+func (r *addressResolver) County() string     { return r.a.County }
+func (r *addressResolver) Postcode() string   { return r.a.Postcode }
+func (r *addressResolver) Latitude() float64  { return r.a.Location.Latitude }
+func (r *addressResolver) Longitude() float64 { return r.a.Location.Longitude }
+
+type salesAreaResolver struct {
+	venueRef int
+	raw      map[string]interface{}
+}
+
+func (r *salesAreaResolver) ID() int32     { return int32(intField(r.raw, "id")) }
+func (r *salesAreaResolver) Name() *string { return optionalString(r.raw, "name") }
+
+func (r *salesAreaResolver) Menus(ctx context.Context) ([]*menuResolver, error) {
+	salesAreaID := intField(r.raw, "id")
+	menus, err := loaderFrom(ctx).menus(ctx, r.venueRef, salesAreaID)
+	if err != nil {
+		return nil, fmt.Errorf("fetching menus for venue %d: %w", r.venueRef, err)
+	}
+	out := []*menuResolver{}
+	for _, m := range mapsIn(menus) {
+		out = append(out, &menuResolver{venueRef: r.venueRef, salesAreaID: salesAreaID, raw: m})
+	}
+	return out, nil
+}
+
+type menuResolver struct {
+	venueRef    int
+	salesAreaID int
+	raw         map[string]interface{}
+}
+
+func (r *menuResolver) ID() int32            { return int32(intField(r.raw, "id")) }
+func (r *menuResolver) Name() *string        { return optionalString(r.raw, "name") }
+func (r *menuResolver) Description() *string { return optionalString(r.raw, "description") }
+
+func (r *menuResolver) CanOrder() bool {
+	b, _ := r.raw["canOrder"].(bool)
+	return b
+}
+
+func (r *menuResolver) Categories(ctx context.Context) ([]*categoryResolver, error) {
+	menuID := intField(r.raw, "id")
+	details, err := loaderFrom(ctx).menuItems(ctx, r.venueRef, r.salesAreaID, menuID)
+	if err != nil {
+		return nil, fmt.Errorf("fetching items for menu %d (venue %d): %w", menuID, r.venueRef, err)
+	}
+	out := []*categoryResolver{}
+	for _, c := range mapsIn(details["categories"]) {
+		out = append(out, &categoryResolver{raw: c})
+	}
+	return out, nil
+}
+
+type categoryResolver struct {
+	raw map[string]interface{}
+}
+
+func (r *categoryResolver) ID() int32     { return int32(intField(r.raw, "id")) }
+func (r *categoryResolver) Name() *string { return optionalString(r.raw, "name") }
+
+func (r *categoryResolver) Hidden() bool {
+	b, _ := r.raw["hidden"].(bool)
+	return b
+}
+
+func (r *categoryResolver) Items(args struct{ Search *string }) ([]*itemResolver, error) {
+	var items []menuItem
+	for _, group := range mapsIn(r.raw["itemGroups"]) {
+		for _, it := range mapsIn(group["items"]) {
+			items = append(items, newMenuItem(menuItem{Category: stringField(r.raw, "name")}, it))
+		}
+	}
+	if args.Search != nil {
+		query, err := parseItemQuery(*args.Search, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid search: %w", err)
+		}
+		items = filterItems(items, query)
+	}
+
+	out := []*itemResolver{}
+	for _, it := range items {
+		out = append(out, &itemResolver{it: it})
+	}
+	return out, nil
+}
+
+type itemResolver struct {
+	it menuItem
+}
+
+func (r *itemResolver) ID() int32        { return int32(r.it.ID) }
+func (r *itemResolver) Name() string     { return r.it.Name }
+func (r *itemResolver) OutOfStock() bool { return r.it.OutOfStock }
+func (r *itemResolver) Vegan() bool      { return r.it.Vegan }
+func (r *itemResolver) Vegetarian() bool { return r.it.Vegetarian }
+func (r *itemResolver) GlutenFree() bool { return r.it.GlutenFree }
+
+func (r *itemResolver) Allergens() []string {
+	if r.it.Allergens == nil {
+		return []string{}
+	}
+	return r.it.Allergens
+}
+
+func (r *itemResolver) MayContain() []string {
+	if r.it.MayContain == nil {
+		return []string{}
+	}
+	return r.it.MayContain
+}
+
+func (r *itemResolver) Description() *string {
+	if r.it.Description == "" {
+		return nil
+	}
+	return &r.it.Description
+}
+
+func (r *itemResolver) Calories() *int32 {
+	if r.it.Calories == nil {
+		return nil
+	}
+	c := int32(*r.it.Calories)
+	return &c
+}
+
+func (r *itemResolver) Score() *float64 {
+	if r.it.Score == 0 {
+		return nil
+	}
+	return &r.it.Score
+}
+
+func (r *itemResolver) Portions() []*portionResolver {
+	out := []*portionResolver{}
+	for _, p := range r.it.Portions {
+		out = append(out, &portionResolver{p: p})
+	}
+	return out
+}
+
+type portionResolver struct {
+	p portionPrice
+}
+
+func (r *portionResolver) Label() string  { return r.p.Label }
+func (r *portionResolver) Price() float64 { return r.p.Price }
+
+func optionalString(m map[string]interface{}, key string) *string {
+	s, ok := m[key].(string)
+	if !ok {
+		return nil
+	}
+	return &s
+}
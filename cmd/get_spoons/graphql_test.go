@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+)
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func postGraphQL(t *testing.T, endpoint, query string, variables map[string]interface{}, data interface{}) graphqlResponse {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	resp, err := http.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	var out graphqlResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("Decoding response failed: %v", err)
+	}
+	if data != nil && len(out.Data) > 0 {
+		if err := json.Unmarshal(out.Data, data); err != nil {
+			t.Fatalf("Decoding data failed: %v", err)
+		}
+	}
+	return out
+}
+
+func TestGraphQLNestedQuery(t *testing.T) {
+	fake, srv := newTestAPIServer(t, false)
+	venue := fake.Dataset.Venues[0]
+
+	query := `query($id: Int!) {
+		venue(id: $id) {
+			id name address { town }
+			salesAreas { id menus { id name categories { name items(search: "stella") { name calories portions { label price } } } } }
+		}
+	}`
+	var data struct {
+		Venue struct {
+			ID         int
+			Name       string
+			Address    struct{ Town string }
+			SalesAreas []struct {
+				ID    int
+				Menus []struct {
+					ID         int
+					Name       string
+					Categories []struct {
+						Name  string
+						Items []menuItem
+					}
+				}
+			}
+		}
+	}
+	resp := postGraphQL(t, srv.URL+"/graphql", query, map[string]interface{}{"id": venue.ID}, &data)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %+v", resp.Errors)
+	}
+
+	v := data.Venue
+	if v.ID != venue.ID || v.Name != venue.Name || v.Address.Town != venue.Address.Town {
+		t.Errorf("Unexpected venue: %+v", v)
+	}
+	if len(v.SalesAreas) != 1 || v.SalesAreas[0].ID != fake.Dataset.SalesAreas[venue.VenueRef] {
+		t.Fatalf("Unexpected sales areas: %+v", v.SalesAreas)
+	}
+	menus := v.SalesAreas[0].Menus
+	if len(menus) != 2 || menus[0].Name != "Drinks" {
+		t.Fatalf("Unexpected menus: %+v", menus)
+	}
+	beer := menus[0].Categories[0]
+	if beer.Name != "Beer" || len(beer.Items) != 1 {
+		t.Fatalf("Expected Stella Artois in Beer, got %+v", beer)
+	}
+	stella := beer.Items[0]
+	if stella.Name != "Stella Artois" || stella.Calories == nil || *stella.Calories != 227 || len(stella.Portions) != 2 {
+		t.Errorf("Unexpected item: %+v", stella)
+	}
+}
+
+func TestGraphQLDedupsUpstreamCalls(t *testing.T) {
+	fake, srv := newTestAPIServer(t, false)
+	venue := fake.Dataset.Venues[1]
+
+	// The same venue twice under different aliases resolves to identical
+	// upstream calls, which should each be made only once.
+	query := `query($id: Int!) {
+		a: venue(id: $id) { salesAreas { menus { categories { items { name } } } } }
+		b: venue(id: $id) { salesAreas { menus { categories { items { name } } } } }
+	}`
+	resp := postGraphQL(t, srv.URL+"/graphql", query, map[string]interface{}{"id": venue.ID}, nil)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %+v", resp.Errors)
+	}
+
+	if n := fake.Requests(jdw.EndpointVenueDetails); n != 1 {
+		t.Errorf("Expected 1 venue details request, got %d", n)
+	}
+	if n := fake.Requests(jdw.EndpointMenus); n != 1 {
+		t.Errorf("Expected 1 menus request, got %d", n)
+	}
+	if n := fake.Requests(jdw.EndpointMenuItems); n != 2 {
+		t.Errorf("Expected 2 menu items requests (one per menu), got %d", n)
+	}
+
+	// Loaders are per request, so a second query fetches again.
+	postGraphQL(t, srv.URL+"/graphql", query, map[string]interface{}{"id": venue.ID}, nil)
+	if n := fake.Requests(jdw.EndpointMenuItems); n != 4 {
+		t.Errorf("Expected 4 menu items requests after a second query, got %d", n)
+	}
+}
+
+func TestGraphQLLoaderUsesRequestContext(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 1})
+	defer fake.Close()
+
+	// A cancelled request makes no upstream calls.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l := newRequestLoader(fake.NewClient(), 1)
+	if _, err := l.venueDetails(ctx, fake.Dataset.Venues[0].VenueRef); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the request to be cancelled, got %v", err)
+	}
+	if n := fake.Requests(jdw.EndpointVenueDetails); n != 0 {
+		t.Errorf("Expected no venue details requests, got %d", n)
+	}
+}
+
+func TestGraphQLVenuesAndErrors(t *testing.T) {
+	fake, srv := newTestAPIServer(t, false)
+
+	var data struct {
+		Venues []struct{ ID int }
+	}
+	resp := postGraphQL(t, srv.URL+"/graphql", `{ venues(limit: 3, offset: 2) { id } }`, nil, &data)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %+v", resp.Errors)
+	}
+	if len(data.Venues) != 3 || data.Venues[0].ID != fake.Dataset.Venues[2].ID {
+		t.Errorf("Unexpected venues: %+v", data.Venues)
+	}
+
+	// GET requests are accepted too.
+	httpResp, err := http.Get(srv.URL + "/graphql?query=" + url.QueryEscape(`{ venue(id: 1) { id } }`))
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	var missing struct {
+		Data struct{ Venue *struct{ ID int } }
+	}
+	if err := json.NewDecoder(httpResp.Body).Decode(&missing); err != nil {
+		t.Fatalf("Decoding response failed: %v", err)
+	}
+	httpResp.Body.Close()
+	if missing.Data.Venue != nil {
+		t.Errorf("Expected null for an unknown venue, got %+v", missing.Data.Venue)
+	}
+
+	// Upstream failures surface as field errors.
+	fake.FailEndpoint(jdw.EndpointVenueDetails, http.StatusInternalServerError)
+	query := `query($id: Int!) { venue(id: $id) { name salesAreas { id } } }`
+	resp = postGraphQL(t, srv.URL+"/graphql", query, map[string]interface{}{"id": fake.Dataset.Venues[0].ID}, nil)
+	if len(resp.Errors) == 0 {
+		t.Error("Expected an error when venue details fail")
+	}
+
+	resp = postGraphQL(t, srv.URL+"/graphql", `{ nope }`, nil, nil)
+	if len(resp.Errors) == 0 {
+		t.Error("Expected a validation error for an unknown field")
+	}
+}
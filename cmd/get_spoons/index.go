@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// indexFormatVersion is bumped whenever searchIndex changes shape, so that
+// an index built by an older get_spoons is rebuilt rather than misread.
+const indexFormatVersion = 2
+
+// searchIndex is a full-text index over venues and menu items, built once
+// from a crawl and saved to disk so that searches need neither the API nor
+// a scan of every menu.
+type searchIndex struct {
+	Version int
+	BuiltAt time.Time
+	Venues  []jdw.Venue
+	Items   []menuItem
+	// VenueTerms and ItemTerms index Venues and Items; document IDs are
+	// positions in those slices.
+	VenueTerms *invertedIndex
+	ItemTerms  *invertedIndex
+}
+
+// invertedIndex maps the stemmed terms of a set of documents to the
+// documents containing them.
+type invertedIndex struct {
+	Postings map[string][]posting
+	// Terms holds the keys of Postings in order, for prefix searches.
+	Terms []string
+	// Lengths is the weighted number of terms in each document, and
+	// AvgLength its mean.
+	Lengths   []float32
+	AvgLength float64
+}
+
+// posting records a term's weighted frequency in a document.
+type posting struct {
+	Doc    int32
+	Weight float32
+}
+
+// indexField is a field of a document being indexed. Terms in heavier
+// fields rank their documents higher.
+type indexField struct {
+	text   string
+	weight float32
+}
+
+func newInvertedIndex() *invertedIndex {
+	return &invertedIndex{Postings: make(map[string][]posting)}
+}
+
+// add indexes a document, which is given the next document ID.
+func (ix *invertedIndex) add(fields ...indexField) {
+	doc := int32(len(ix.Lengths))
+	weights := make(map[string]float32)
+	var length float32
+	for _, f := range fields {
+		for _, term := range indexTerms(f.text) {
+			weights[term] += f.weight
+			length += f.weight
+		}
+	}
+	for term, w := range weights {
+		ix.Postings[term] = append(ix.Postings[term], posting{Doc: doc, Weight: w})
+	}
+	ix.Lengths = append(ix.Lengths, length)
+}
+
+// finish prepares the index for searching once every document is added.
+func (ix *invertedIndex) finish() {
+	ix.Terms = make([]string, 0, len(ix.Postings))
+	for term := range ix.Postings {
+		ix.Terms = append(ix.Terms, term)
+	}
+	sort.Strings(ix.Terms)
+	var total float64
+	for _, l := range ix.Lengths {
+		total += float64(l)
+	}
+	if len(ix.Lengths) > 0 {
+		ix.AvgLength = total / float64(len(ix.Lengths))
+	}
+}
+
+// indexStopWords are left out of the index and of queries.
+var indexStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "with": true, "in": true, "on": true, "or": true,
+}
+
+// indexTerms splits text into lower-case words and stems them, dropping
+// stop words.
+func indexTerms(text string) []string {
+	var terms []string
+	for _, w := range splitWords(text) {
+		if !indexStopWords[w] {
+			terms = append(terms, stem(w))
+		}
+	}
+	return terms
+}
+
+// Field weights for venues and items.
+const (
+	weightIndexName     = 3
+	weightIndexTown     = 2
+	weightIndexCategory = 1.5
+	weightIndexOther    = 1
+	weightIndexVenue    = 0.5
+)
+
+// newSearchIndex builds an index of venues and items.
+func newSearchIndex(venues []jdw.Venue, items []menuItem) *searchIndex {
+	idx := &searchIndex{
+		Version:    indexFormatVersion,
+		BuiltAt:    time.Now().UTC(),
+		Venues:     venues,
+		Items:      items,
+		VenueTerms: newInvertedIndex(),
+		ItemTerms:  newInvertedIndex(),
+	}
+	for _, v := range venues {
+		idx.VenueTerms.add(
+			indexField{v.Name, weightIndexName},
+			indexField{v.Address.Town, weightIndexTown},
+			indexField{v.Address.Line1, weightIndexOther},
+			indexField{v.Address.County, weightIndexOther},
+			indexField{v.Address.Postcode, weightIndexOther},
+		)
+	}
+	for _, it := range items {
+		idx.ItemTerms.add(
+			indexField{it.Name, weightIndexName},
+			indexField{it.Category, weightIndexCategory},
+			indexField{it.Menu, weightIndexOther},
+			indexField{it.Description, weightIndexOther},
+			indexField{it.VenueName, weightIndexVenue},
+		)
+	}
+	idx.VenueTerms.finish()
+	idx.ItemTerms.finish()
+	return idx
+}
+
+// indexFromCrawl builds an index from the JSON output of a crawl: either
+// a list of venues, or of expanded venues as written by -menus or -items,
+// whose menu items are indexed too.
+func indexFromCrawl(r io.Reader) (*searchIndex, error) {
+	var docs []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&docs); err != nil {
+		return nil, fmt.Errorf("reading crawl: %w", err)
+	}
+	var (
+		venues []jdw.Venue
+		items  []menuItem
+	)
+	for i, doc := range docs {
+		var v jdw.Venue
+		if err := json.Unmarshal(doc, &v); err != nil {
+			slog.Warn("Skipping venue that could not be decoded", "index", i, "error", err)
+			continue
+		}
+		venues = append(venues, v)
+		if !bytes.Contains(doc, []byte(`"menus"`)) {
+			continue
+		}
+		var details map[string]interface{}
+		if err := json.Unmarshal(doc, &details); err != nil {
+			return nil, fmt.Errorf("reading crawl: venue %d: %w", v.ID, err)
+		}
+		items = append(items, extractItems(details)...)
+	}
+	return newSearchIndex(venues, items), nil
+}
+
+// save writes the index to path as gzipped gob.
+func (idx *searchIndex) save(path string) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(zw).Encode(idx); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// loadSearchIndex reads an index written by save.
+func loadSearchIndex(path string) (*searchIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a search index: %w", path, err)
+	}
+	var idx searchIndex
+	if err := gob.NewDecoder(zr).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("%s is not a search index: %w", path, err)
+	}
+	if idx.Version != indexFormatVersion {
+		return nil, fmt.Errorf("%s has index format %d, but this get_spoons reads format %d; rebuild it with get_spoons index build", path, idx.Version, indexFormatVersion)
+	}
+	return &idx, nil
+}
+
+// indexQueryTerm is a term of a parsed index query.
+type indexQueryTerm struct {
+	text   string
+	prefix bool
+}
+
+// parseIndexQuery splits a query into stemmed terms. A word ending in "*"
+// is a prefix, matched unstemmed against the start of indexed terms.
+func parseIndexQuery(query string) ([]indexQueryTerm, error) {
+	var terms []indexQueryTerm
+	for _, field := range strings.Fields(query) {
+		prefix := strings.HasSuffix(field, "*")
+		words := splitWords(field)
+		for i, w := range words {
+			switch {
+			case prefix && i == len(words)-1:
+				terms = append(terms, indexQueryTerm{text: w, prefix: true})
+			case !indexStopWords[w]:
+				terms = append(terms, indexQueryTerm{text: stem(w)})
+			}
+		}
+	}
+	if len(terms) == 0 {
+		return nil, errors.New("query has no searchable words")
+	}
+	return terms, nil
+}
+
+// indexHit is a document matching an index query.
+type indexHit struct {
+	Doc   int
+	Score float64
+}
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	// Fields are short, so length normalisation is kept gentle.
+	bm25B = 0.5
+)
+
+// prefixPenalty scales matches of a prefix query term that are longer than
+// the prefix, so that "ale*" ranks "ale" above "alex".
+const prefixPenalty = 0.9
+
+// search returns the documents containing every query term, best first,
+// ranked by BM25 over the field-weighted term frequencies. At most limit
+// hits are returned if limit is positive.
+func (ix *invertedIndex) search(terms []indexQueryTerm, limit int) []indexHit {
+	var scores map[int32]float64
+	for _, t := range terms {
+		termScores := ix.termScores(t)
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for doc, s := range scores {
+			if ts, ok := termScores[doc]; ok {
+				scores[doc] = s + ts
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	hits := make([]indexHit, 0, len(scores))
+	for doc, s := range scores {
+		hits = append(hits, indexHit{Doc: int(doc), Score: s})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Doc < hits[j].Doc
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// termScores returns the BM25 score of a query term for each document
+// containing it. A prefix term scores each document by its best match,
+// with the rarity of the prefix as a whole, so that a rare expansion does
+// not outrank a common one.
+func (ix *invertedIndex) termScores(t indexQueryTerm) map[int32]float64 {
+	scores := make(map[int32]float64)
+	if !t.prefix {
+		postings := ix.Postings[t.text]
+		ix.addScores(scores, postings, ix.idf(len(postings)), 1)
+		return scores
+	}
+
+	// Indexed terms are stemmed, so "curry*" must also look for "curri".
+	prefixes := []string{t.text}
+	if s := stem(t.text); !strings.HasPrefix(s, t.text) {
+		prefixes = append(prefixes, s)
+	}
+	type expansion struct {
+		term  string
+		scale float64
+	}
+	var expansions []expansion
+	docs := make(map[int32]bool)
+	for _, prefix := range prefixes {
+		for i := sort.SearchStrings(ix.Terms, prefix); i < len(ix.Terms) && strings.HasPrefix(ix.Terms[i], prefix); i++ {
+			scale := 1.0
+			if ix.Terms[i] != prefix {
+				scale = prefixPenalty
+			}
+			expansions = append(expansions, expansion{ix.Terms[i], scale})
+			for _, p := range ix.Postings[ix.Terms[i]] {
+				docs[p.Doc] = true
+			}
+		}
+	}
+	idf := ix.idf(len(docs))
+	for _, e := range expansions {
+		ix.addScores(scores, ix.Postings[e.term], idf, e.scale)
+	}
+	return scores
+}
+
+// idf is the BM25 inverse document frequency of a term in df documents.
+func (ix *invertedIndex) idf(df int) float64 {
+	n := float64(len(ix.Lengths))
+	return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+// addScores records the scaled score of a term in each of its postings,
+// keeping a document's existing score if that is higher.
+func (ix *invertedIndex) addScores(scores map[int32]float64, postings []posting, idf, scale float64) {
+	for _, p := range postings {
+		w := float64(p.Weight)
+		norm := 1 - bm25B + bm25B*float64(ix.Lengths[p.Doc])/ix.AvgLength
+		s := scale * idf * w * (bm25K1 + 1) / (w + bm25K1*norm)
+		if s > scores[p.Doc] {
+			scores[p.Doc] = s
+		}
+	}
+}
+
+// venueResult and itemResult are the results of index queries.
+type venueResult struct {
+	Score float64   `json:"score"`
+	Venue jdw.Venue `json:"venue"`
+}
+
+type itemResult struct {
+	Score float64  `json:"score"`
+	Item  menuItem `json:"item"`
+}
+
+// searchVenues returns the venues matching query, best first.
+func (idx *searchIndex) searchVenues(terms []indexQueryTerm, limit int) []venueResult {
+	results := []venueResult{}
+	for _, h := range idx.VenueTerms.search(terms, limit) {
+		results = append(results, venueResult{Score: roundScore(h.Score), Venue: idx.Venues[h.Doc]})
+	}
+	return results
+}
+
+// searchItems returns the menu items matching query, best first.
+func (idx *searchIndex) searchItems(terms []indexQueryTerm, limit int) []itemResult {
+	results := []itemResult{}
+	for _, h := range idx.ItemTerms.search(terms, limit) {
+		results = append(results, itemResult{Score: roundScore(h.Score), Item: idx.Items[h.Doc]})
+	}
+	return results
+}
+
+// runIndex implements the "index" subcommand.
+func runIndex(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "build":
+			return runIndexBuild(args[1:])
+		case "query":
+			return runIndexQuery(args[1:], os.Stdout)
+		}
+	}
+	return errors.New("usage: get_spoons index build -output path [-input crawl.json] | get_spoons index query -index path [-items] words...")
+}
+
+// runIndexBuild builds an index from a crawl file, or by crawling the API,
+// and saves it.
+func runIndexBuild(args []string) error {
+	fs := flag.NewFlagSet("get_spoons index build", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	input := fs.String("input", "", "Build from this crawl output (a JSON list of venues, expanded with -items to index menu items) instead of the API")
+	output := fs.String("output", "", "Write the index to this path")
+	concurrency := fs.Int("concurrency", 4, "Number of concurrent requests when crawling")
+	retries := fs.Int("retries", 1, "Number of times to retry failed detail, menu and item requests when crawling")
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+	if *output == "" {
+		return errors.New("-output is required")
+	}
+
+	var idx *searchIndex
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if idx, err = indexFromCrawl(f); err != nil {
+			return err
+		}
+	} else {
+		client, err := cf.newClient()
+		if err != nil {
+			return err
+		}
+		venues, err := client.GetVenues()
+		if err != nil {
+			return fmt.Errorf("fetching venues: %w", err)
+		}
+		expanded, failures := expandVenues(client, venues, expandOptions{
+			Concurrency:  *concurrency,
+			IncludeMenus: true,
+			IncludeItems: true,
+			Retries:      *retries,
+		})
+		if len(failures) > 0 {
+			slog.Warn("Some menus could not be indexed", "failures", len(failures))
+		}
+		var items []menuItem
+		for _, details := range expanded {
+			items = append(items, extractItems(details)...)
+		}
+		idx = newSearchIndex(venues, items)
+	}
+
+	if err := idx.save(*output); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+	slog.Info("Built search index", "venues", len(idx.Venues), "items", len(idx.Items),
+		"venue_terms", len(idx.VenueTerms.Terms), "item_terms", len(idx.ItemTerms.Terms), "path", *output)
+	return nil
+}
+
+// runIndexQuery searches a saved index for venues or, with -items, menu
+// items, and writes the results to w.
+func runIndexQuery(args []string, w io.Writer) error {
+	// Queries never touch the API, so the client flags aren't offered.
+	fs := flag.NewFlagSet("get_spoons index query", flag.ContinueOnError)
+	path := fs.String("index", "", "Index to search, as written by index build")
+	items := fs.Bool("items", false, "Search menu items instead of venues")
+	limit := fs.Int("limit", 10, "Maximum number of results (0 for all)")
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("-index is required")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("invalid -format %q: must be text or json", *format)
+	}
+	terms, err := parseIndexQuery(strings.Join(fs.Args(), " "))
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	idx, err := loadSearchIndex(*path)
+	if err != nil {
+		return err
+	}
+	loaded := time.Now()
+
+	var results interface{}
+	var n int
+	if *items {
+		r := idx.searchItems(terms, *limit)
+		results, n = r, len(r)
+	} else {
+		r := idx.searchVenues(terms, *limit)
+		results, n = r, len(r)
+	}
+	slog.Info("Searched index", "results", n, "load", loaded.Sub(start), "search", time.Since(loaded))
+
+	if *format == "json" {
+		return writeJSON(w, results)
+	}
+	return writeIndexResults(w, results)
+}
+
+// writeIndexResults writes query results as a table.
+func writeIndexResults(w io.Writer, results interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	switch r := results.(type) {
+	case []venueResult:
+		fmt.Fprintln(tw, "SCORE\tID\tVENUE\tTOWN\tPOSTCODE")
+		for _, res := range r {
+			v := res.Venue
+			fmt.Fprintf(tw, "%.2f\t%d\t%s\t%s\t%s\n", res.Score, v.ID, v.Name, v.Address.Town, v.Address.Postcode)
+		}
+	case []itemResult:
+		fmt.Fprintln(tw, "SCORE\tITEM\tCATEGORY\tVENUE\tPRICES")
+		for _, res := range r {
+			it := res.Item
+			var prices []string
+			for _, p := range it.Portions {
+				price := fmt.Sprintf("£%.2f", p.Price)
+				if p.Label != "" {
+					price = p.Label + " " + price
+				}
+				prices = append(prices, price)
+			}
+			fmt.Fprintf(tw, "%.2f\t%s\t%s\t%s\t%s\n", res.Score, it.Name, it.Category, it.VenueName, strings.Join(prices, ", "))
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+)
+
+func TestSearchIndex(t *testing.T) {
+	venues := []jdw.Venue{
+		{ID: 1, Name: "The Moon Under Water", Address: jdw.Address{Town: "Manchester", Postcode: "M1 1AA"}},
+		{ID: 2, Name: "The Moon on the Hill", Address: jdw.Address{Town: "Leeds"}},
+		{ID: 3, Name: "The Alexandra", Address: jdw.Address{Town: "Moonbridge"}},
+	}
+	items := []menuItem{
+		{Name: "Fish & Chips", Description: "Battered cod, chips and peas", Category: "Mains"},
+		{Name: "Vegan Burger", Description: "Plant-based patty", Category: "Burgers"},
+		{Name: "Superfood Salad", Description: "Vegan, gluten-free", Category: "Mains"},
+		{Name: "Ale Pie", Category: "Mains"},
+	}
+	idx := newSearchIndex(venues, items)
+
+	search := func(query string, items bool) []string {
+		t.Helper()
+		terms, err := parseIndexQuery(query)
+		if err != nil {
+			t.Fatalf("parseIndexQuery(%q) failed: %v", query, err)
+		}
+		var names []string
+		if items {
+			for _, r := range idx.searchItems(terms, 0) {
+				names = append(names, r.Item.Name)
+			}
+		} else {
+			for _, r := range idx.searchVenues(terms, 0) {
+				names = append(names, r.Venue.Name)
+			}
+		}
+		return names
+	}
+
+	tests := []struct {
+		query string
+		items bool
+		want  string
+	}{
+		// Shorter names rank higher for the same match.
+		{"moon", false, "The Moon on the Hill,The Moon Under Water"},
+		{"moon leeds", false, "The Moon on the Hill"},
+		{"moon*", false, "The Moon on the Hill,The Moon Under Water,The Alexandra"},
+		{"m1", false, "The Moon Under Water"},
+		{"alex*", false, "The Alexandra"},
+		// Stemming matches plurals and -ed endings both ways.
+		{"burgers", true, "Vegan Burger"},
+		{"chip batter", true, "Fish & Chips"},
+		// A match in the name outranks one in the description.
+		{"vegan", true, "Vegan Burger,Superfood Salad"},
+		{"ale*", true, "Ale Pie"},
+		{"pies", true, "Ale Pie"},
+		{"the chips", true, "Fish & Chips"},
+		{"curry", true, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(search(tt.query, tt.items), ","); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.query, tt.want, got)
+		}
+	}
+
+	if _, err := parseIndexQuery("the & of"); err == nil {
+		t.Error("Expected a query of stop words to fail")
+	}
+}
+
+func TestIndexBuildAndQuery(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 6})
+	defer fake.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "spoons.idx")
+
+	if err := runIndexBuild([]string{"-api-url", fake.URL, "-token", fake.Token(), "-output", path}); err != nil {
+		t.Fatalf("index build failed: %v", err)
+	}
+	requests := fake.Requests(jdw.EndpointMenuItems)
+
+	var out strings.Builder
+	if err := runIndexQuery([]string{"-index", path, "-items", "-format", "json", "-limit", "0", "guinness"}, &out); err != nil {
+		t.Fatalf("index query failed: %v", err)
+	}
+	var results []itemResult
+	if err := json.Unmarshal([]byte(out.String()), &results); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(results) != 6 || results[0].Item.Name != "Guinness Draught" || results[0].Score <= 0 {
+		t.Errorf("Expected Guinness at all 6 venues, got %+v", results)
+	}
+	if got := fake.Requests(jdw.EndpointMenuItems); got != requests {
+		t.Errorf("Expected queries not to use the API, got %d more requests", got-requests)
+	}
+
+	first := fake.Dataset.Venues[0]
+	out.Reset()
+	if err := runIndexQuery([]string{"-index", path, "-limit", "1", first.Name}, &out); err != nil {
+		t.Fatalf("index query failed: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "SCORE") || !strings.Contains(out.String(), first.Name) {
+		t.Errorf("Expected a table with %s:\n%s", first.Name, out.String())
+	}
+	if err := runIndexQuery([]string{"-index", path, "-token", fake.Token(), "stella"}, io.Discard); err == nil {
+		t.Error("Expected index query to reject client flags")
+	}
+
+	// An index can also be built from a crawl file.
+	os.Setenv("JDW_API_URL", fake.URL)
+	defer os.Unsetenv("JDW_API_URL")
+	os.Setenv("JDW_TOKEN", fake.Token())
+	defer os.Unsetenv("JDW_TOKEN")
+	crawl := filepath.Join(dir, "crawl.json")
+	if err := Run([]string{"-items", "-limit", "2", "-output", crawl}); err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+	if err := Run([]string{"index", "build", "-input", crawl, "-output", path}); err != nil {
+		t.Fatalf("index build -input failed: %v", err)
+	}
+	idx, err := loadSearchIndex(path)
+	if err != nil {
+		t.Fatalf("loading index failed: %v", err)
+	}
+	if len(idx.Venues) != 2 || len(idx.Items) == 0 {
+		t.Errorf("Expected 2 venues and their items, got %d and %d", len(idx.Venues), len(idx.Items))
+	}
+
+	idx.Version = indexFormatVersion + 1
+	if err := idx.save(path); err != nil {
+		t.Fatal(err)
+	}
+	err = runIndexQuery([]string{"-index", path, "stella"}, &out)
+	if err == nil || !strings.Contains(err.Error(), "rebuild it") {
+		t.Errorf("Expected an incompatible index to be rejected, got %v", err)
+	}
+	os.WriteFile(path, []byte("not an index"), 0o644)
+	if err := runIndexQuery([]string{"-index", path, "stella"}, &out); err == nil {
+		t.Error("Expected an invalid index to be rejected")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// Scores of the ways a query word can match a word of an item's text.
+const (
+	scoreExact     = 1.0
+	scorePrefix    = 0.9 // "pint" in "pints"
+	scoreSubstring = 0.8 // "berry" in "strawberry", or a phrase across punctuation
+	scoreSynonym   = 0.9 // multiplies the score of the synonym's match
+)
+
+// synonymGroups are words and phrases that fuzzy item searches treat as
+// interchangeable. Phrases are compared word by word, so "coca-cola" also
+// finds "Coca Cola".
+var synonymGroups = [][]string{
+	{"coke", "coca-cola"},
+	{"chips", "fries"},
+	{"ipa", "india pale ale"},
+	{"veggie", "vegetarian"},
+	{"bubbly", "prosecco"},
+	{"spag bol", "spaghetti bolognese"},
+	{"hot dog", "frankfurter"},
+	{"ice cream", "gelato"},
+	{"mac and cheese", "macaroni cheese"},
+}
+
+// synonyms maps each normalised word or phrase of synonymGroups to the
+// words of the others in its group.
+var synonyms = buildSynonyms(synonymGroups)
+
+func buildSynonyms(groups [][]string) map[string][][]string {
+	m := make(map[string][][]string)
+	for _, group := range groups {
+		for _, phrase := range group {
+			key := strings.Join(splitWords(phrase), " ")
+			for _, other := range group {
+				if other != phrase {
+					m[key] = append(m[key], splitWords(other))
+				}
+			}
+		}
+	}
+	return m
+}
+
+// splitWords lower-cases s and splits it into runs of letters and digits.
+func splitWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// textScore scores a text term against s. Strict terms score 1 if s
+// contains the term and 0 otherwise. Fuzzy terms are matched word by
+// word, so "peroni nastro" finds "Peroni Nastro Azzurro", with prefixes,
+// substrings and a typo or two tolerated at a lower score ("guiness"
+// finds "Guinness"), and synonyms tried in place of the whole term.
+func (t termSpec) textScore(s string) float64 {
+	lower := strings.ToLower(s)
+	if !t.fuzzy || len(t.words) == 0 {
+		return boolScore(strings.Contains(lower, t.text))
+	}
+	text := splitWords(lower)
+	best := phraseScore(t.words, text)
+	if best < scoreSubstring && strings.Contains(lower, t.text) {
+		best = scoreSubstring
+	}
+	for _, alt := range synonyms[strings.Join(t.words, " ")] {
+		if s := scoreSynonym * phraseScore(alt, text); s > best {
+			best = s
+		}
+	}
+	return best
+}
+
+// phraseScore returns the best score of query matching consecutive words
+// of text, where a run of words scores its worst-matching word.
+func phraseScore(query, text []string) float64 {
+	best := 0.0
+	for i := 0; i+len(query) <= len(text); i++ {
+		score := 1.0
+		for j, q := range query {
+			if score = min(score, wordScore(q, text[i+j])); score == 0 {
+				break
+			}
+		}
+		best = max(best, score)
+	}
+	return best
+}
+
+// wordScore scores a query word against a word of text.
+func wordScore(q, w string) float64 {
+	switch {
+	case q == w:
+		return scoreExact
+	case strings.HasPrefix(w, q):
+		return scorePrefix
+	case strings.Contains(w, q):
+		return scoreSubstring
+	}
+	if d := fuzzy.LevenshteinDistance(q, w); d <= allowedTypos(q) {
+		return 0.85 - 0.15*float64(d)
+	}
+	return 0
+}
+
+// allowedTypos is the edit distance tolerated for a query word: none for
+// short words, which would otherwise match too much ("beef" and "beer"),
+// one from five letters and two from eight.
+func allowedTypos(q string) int {
+	switch n := len([]rune(q)); {
+	case n >= 8:
+		return 2
+	case n >= 5:
+		return 1
+	}
+	return 0
+}
+
+// roundScore rounds a score for output.
+func roundScore(score float64) float64 {
+	return math.Round(score*100) / 100
+}
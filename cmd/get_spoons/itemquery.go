@@ -0,0 +1,662 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// itemQuery is a parsed -item-search query. Terms are ANDed unless joined
+// with OR, and can be negated with NOT or a leading "-":
+//
+//	stella pint                  every word appears in the item's text
+//	"pint of"                    the phrase appears
+//	name:stella category:beer    scoped to a field (name, description, category, menu)
+//	price<4.50 calories<=300     compared with any portion price, or the calories
+//	outofstock, instock          the item's stock status
+//	diet:vegan -allergen:peanuts the item's dietary labels and allergens
+//	(burger OR wrap) -chicken    grouping, alternatives and exclusions
+//
+// Fuzzy queries tolerate typos and synonyms in words they look for (see
+// textScore) and score how well each item matches; strict queries, and
+// the words a query excludes, are matched case-insensitively as
+// substrings.
+type itemQuery struct {
+	root  *queryNode
+	fuzzy bool
+}
+
+// Kinds of queryNode.
+const (
+	queryAnd  = "AND"
+	queryOr   = "OR"
+	queryNot  = "NOT"
+	queryTerm = "term"
+)
+
+// queryNode is a node in a parsed query's expression tree.
+type queryNode struct {
+	kind     string
+	children []*queryNode
+	term     termSpec
+}
+
+// termSpec is a single condition, such as "stella" or price<4.5.
+type termSpec struct {
+	// field is "" for a word matched against all text.
+	field string
+	// text is the lower-cased word or phrase for text fields, and words
+	// its words.
+	text  string
+	words []string
+	// fuzzy is set on text terms of fuzzy queries.
+	fuzzy bool
+	// op and num are the comparison for numeric fields.
+	op  string
+	num float64
+}
+
+// Fields of scoped terms.
+const (
+	fieldName        = "name"
+	fieldDescription = "description"
+	fieldCategory    = "category"
+	fieldMenu        = "menu"
+	fieldPrice       = "price"
+	fieldCalories    = "calories"
+	fieldOutOfStock  = "outofstock"
+	fieldDiet        = "diet"
+	fieldAllergen    = "allergen"
+)
+
+var (
+	textFields    = map[string]bool{fieldName: true, fieldDescription: true, fieldCategory: true, fieldMenu: true}
+	numericFields = map[string]bool{fieldPrice: true, fieldCalories: true}
+	labelFields   = map[string]bool{fieldDiet: true, fieldAllergen: true}
+)
+
+// scopedTerm matches terms of the form field:value or field<op>number.
+var scopedTerm = regexp.MustCompile(`^([A-Za-z]+)(<=|>=|<|>|=|:)(.*)$`)
+
+// parseItemQuery parses an -item-search query, which is fuzzy unless
+// fuzzy is false. An empty query matches everything and returns nil.
+func parseItemQuery(s string, fuzzy bool) (*itemQuery, error) {
+	tokens, err := lexQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	p := &queryParser{tokens: tokens, fuzzy: fuzzy}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(tokens) {
+		return nil, fmt.Errorf("unexpected %q", tokens[p.pos].text)
+	}
+	return &itemQuery{root: root, fuzzy: fuzzy}, nil
+}
+
+// queryToken is a lexed query token. Quoted tokens, which begin with a
+// quote, are always phrases.
+type queryToken struct {
+	text   string
+	quoted bool
+}
+
+// lexQuery splits a query into parentheses, "-" negations, quoted phrases
+// and bare words. A quoted value may follow a field, as in name:"pint of".
+func lexQuery(s string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, queryToken{text: string(r)})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, queryToken{text: "-"})
+			i++
+		default:
+			var b strings.Builder
+			quoted := r == '"'
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' {
+				if runes[i] != '"' {
+					b.WriteRune(runes[i])
+					i++
+					continue
+				}
+				end := i + 1
+				for end < len(runes) && runes[end] != '"' {
+					end++
+				}
+				if end == len(runes) {
+					return nil, errors.New("unterminated quote")
+				}
+				b.WriteString(string(runes[i+1 : end]))
+				i = end + 1
+			}
+			tokens = append(tokens, queryToken{text: b.String(), quoted: quoted})
+		}
+	}
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	fuzzy  bool
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// isOperator reports whether t is the unquoted keyword op.
+func (t queryToken) isOperator(op string) bool {
+	return !t.quoted && t.text == op
+}
+
+func (p *queryParser) parseOr() (*queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	node := &queryNode{kind: queryOr, children: []*queryNode{left}}
+	for {
+		t, ok := p.peek()
+		if !ok || !t.isOperator(queryOr) {
+			break
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, right)
+	}
+	if len(node.children) == 1 {
+		return left, nil
+	}
+	return node, nil
+}
+
+func (p *queryParser) parseAnd() (*queryNode, error) {
+	node := &queryNode{kind: queryAnd}
+	for {
+		t, ok := p.peek()
+		if !ok || t.isOperator(")") || t.isOperator(queryOr) {
+			break
+		}
+		if t.isOperator(queryAnd) {
+			p.pos++
+			continue
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, child)
+	}
+	switch len(node.children) {
+	case 0:
+		if t, ok := p.peek(); ok {
+			return nil, fmt.Errorf("expected a term before %q", t.text)
+		}
+		return nil, errors.New("expected a term at end of query")
+	case 1:
+		return node.children[0], nil
+	}
+	return node, nil
+}
+
+func (p *queryParser) parseUnary() (*queryNode, error) {
+	t, _ := p.peek()
+	p.pos++
+	switch {
+	case t.isOperator(queryNot) || t.isOperator("-"):
+		if next, ok := p.peek(); !ok || next.isOperator(")") {
+			return nil, fmt.Errorf("expected a term after %s", t.text)
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNode{kind: queryNot, children: []*queryNode{child}}, nil
+	case t.isOperator("("):
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next, ok := p.peek(); !ok || !next.isOperator(")") {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		p.pos++
+		return node, nil
+	}
+	term, err := parseTerm(t)
+	if err != nil {
+		return nil, err
+	}
+	if term.field == "" || textFields[term.field] {
+		term.words = splitWords(term.text)
+		term.fuzzy = p.fuzzy
+	}
+	return &queryNode{kind: queryTerm, term: term}, nil
+}
+
+// parseTerm parses a word, phrase or scoped term.
+func parseTerm(t queryToken) (termSpec, error) {
+	lower := strings.ToLower(t.text)
+	if t.quoted {
+		return termSpec{text: lower}, nil
+	}
+	if lower == fieldOutOfStock || lower == "instock" {
+		return termSpec{field: fieldOutOfStock, text: lower}, nil
+	}
+	m := scopedTerm.FindStringSubmatch(t.text)
+	if m == nil {
+		return termSpec{text: lower}, nil
+	}
+	field, op, value := strings.ToLower(m[1]), m[2], m[3]
+	switch {
+	case textFields[field]:
+		if op != ":" {
+			return termSpec{}, fmt.Errorf("%s only supports %s:value, not %s", field, field, op)
+		}
+		return termSpec{field: field, text: strings.ToLower(value)}, nil
+	case numericFields[field]:
+		if op == ":" {
+			op = "="
+		}
+		num, err := strconv.ParseFloat(strings.TrimPrefix(value, "£"), 64)
+		if err != nil {
+			return termSpec{}, fmt.Errorf("%s%s%s: %q is not a number", field, op, value, value)
+		}
+		return termSpec{field: field, op: op, num: num}, nil
+	case labelFields[field]:
+		if op != ":" {
+			return termSpec{}, fmt.Errorf("%s only supports %s:value, not %s", field, field, op)
+		}
+		if field == fieldAllergen {
+			allergen, ok := jdw.LookupAllergen(value)
+			if !ok {
+				return termSpec{}, fmt.Errorf("unknown allergen %q (valid allergens: %s)", value, strings.Join(jdw.Allergens, ", "))
+			}
+			return termSpec{field: field, text: allergen}, nil
+		}
+		diet, ok := jdw.CanonicalDiet(value)
+		if !ok {
+			return termSpec{}, fmt.Errorf("unknown diet %q (valid diets: %s)", value, strings.Join(jdw.Diets, ", "))
+		}
+		return termSpec{field: field, text: diet}, nil
+	}
+	return termSpec{}, fmt.Errorf("unknown field %q (valid fields: %s)", m[1], strings.Join(queryFieldNames(), ", "))
+}
+
+func queryFieldNames() []string {
+	var names []string
+	for f := range textFields {
+		names = append(names, f)
+	}
+	for f := range numericFields {
+		names = append(names, f)
+	}
+	for f := range labelFields {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// compare applies a numeric term to v.
+func (t termSpec) compare(v float64) bool {
+	switch t.op {
+	case "<":
+		return v < t.num
+	case "<=":
+		return v <= t.num
+	case ">":
+		return v > t.num
+	case ">=":
+		return v >= t.num
+	}
+	return v == t.num
+}
+
+// querySubject is something a query can be evaluated against.
+type querySubject interface {
+	// score reports how well the term holds for the subject itself, from 0
+	// if it does not hold to 1 for an exact match. Terms other than text
+	// score 0 or 1.
+	score(t termSpec) float64
+	// holdsAnywhere reports whether the term holds for the subject or
+	// anything it contains. Negated terms use it, so that a menu category
+	// only matches "-stella" as a whole when none of its items mention
+	// stella.
+	holdsAnywhere(t termSpec) bool
+}
+
+// matches evaluates the query against s. A nil query matches everything.
+func (q *itemQuery) matches(s querySubject) bool {
+	return q.score(s) > 0
+}
+
+// and returns a query matching what both q and other match, fuzzy if q is.
+// Either may be nil.
+func (q *itemQuery) and(other *itemQuery) *itemQuery {
+	switch {
+	case q == nil:
+		return other
+	case other == nil:
+		return q
+	}
+	return &itemQuery{root: &queryNode{kind: queryAnd, children: []*queryNode{q.root, other.root}}, fuzzy: q.fuzzy}
+}
+
+// score evaluates the query against s, returning 0 if it does not match.
+// A match scores the weakest of the terms it needed, so that an item
+// matching every word exactly in its name scores 1. A nil query scores 1
+// for everything.
+func (q *itemQuery) score(s querySubject) float64 {
+	if q == nil {
+		return 1
+	}
+	return q.root.eval(s, false)
+}
+
+// eval scores the node, with negated set when an odd number of NOTs
+// enclose it.
+func (n *queryNode) eval(s querySubject, negated bool) float64 {
+	switch n.kind {
+	case queryAnd:
+		score := 1.0
+		for _, c := range n.children {
+			score = min(score, c.eval(s, negated))
+			if score == 0 {
+				break
+			}
+		}
+		return score
+	case queryOr:
+		score := 0.0
+		for _, c := range n.children {
+			score = max(score, c.eval(s, negated))
+		}
+		return score
+	case queryNot:
+		if n.children[0].eval(s, !negated) > 0 {
+			return 0
+		}
+		return 1
+	}
+	if negated {
+		// Exclusions are strict, so that -beef does not exclude beer.
+		t := n.term
+		t.fuzzy = false
+		if s.holdsAnywhere(t) {
+			return 1
+		}
+		return 0
+	}
+	return s.score(n.term)
+}
+
+// Weights of text terms matched outside an item's name.
+const (
+	weightContext     = 0.8 // category, menu or an enclosing node
+	weightDescription = 0.7 // description or another field of the item
+)
+
+// bestScore returns the highest of scores, or 0.
+func bestScore(scores ...float64) float64 {
+	best := 0.0
+	for _, s := range scores {
+		best = max(best, s)
+	}
+	return best
+}
+
+// textScores scores a text term against each of texts.
+func (t termSpec) textScores(weight float64, texts ...string) float64 {
+	best := 0.0
+	for _, s := range texts {
+		best = max(best, weight*t.textScore(s))
+	}
+	return best
+}
+
+// boolScore converts a condition to a score.
+func boolScore(ok bool) float64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+// score implements querySubject for a flattened item.
+func (it menuItem) score(t termSpec) float64 {
+	switch t.field {
+	case "":
+		return bestScore(
+			t.textScore(it.Name),
+			t.textScores(weightContext, it.Category, it.Menu),
+			t.textScores(weightDescription, it.Description),
+		)
+	case fieldName:
+		return t.textScore(it.Name)
+	case fieldDescription:
+		return t.textScore(it.Description)
+	case fieldCategory:
+		return t.textScore(it.Category)
+	case fieldMenu:
+		return t.textScore(it.Menu)
+	case fieldPrice:
+		for _, p := range it.Portions {
+			if t.compare(p.Price) {
+				return 1
+			}
+		}
+		return 0
+	case fieldCalories:
+		return boolScore(it.Calories != nil && t.compare(float64(*it.Calories)))
+	case fieldOutOfStock:
+		return boolScore(it.OutOfStock == (t.text == fieldOutOfStock))
+	case fieldDiet:
+		return boolScore(it.Suits(t.text))
+	case fieldAllergen:
+		return boolScore(it.MayContainAllergen(t.text))
+	}
+	return 0
+}
+
+// holdsAnywhere implements querySubject; an item contains nothing else.
+func (it menuItem) holdsAnywhere(t termSpec) bool {
+	return it.score(t) > 0
+}
+
+// structuralKeys are the keys searchAndPruneItems descends into; other keys
+// are kept as metadata of the node.
+var structuralKeys = map[string]bool{
+	"items":      true,
+	"products":   true,
+	"sections":   true,
+	"categories": true,
+	"groups":     true,
+	"itemGroups": true,
+	"options":    true,
+	"portion":    true,
+	"choices":    true,
+	"addOns":     true,
+	"linked":     true,
+}
+
+// menuNode is a node of a raw menu tree, with the context inherited from
+// the nodes above it.
+type menuNode struct {
+	raw map[string]interface{}
+	// scope holds the string fields of the enclosing nodes.
+	scope    []string
+	menu     string
+	category string
+	// The calories and stock status of the enclosing item, which apply to
+	// its portions and options.
+	calories   *float64
+	outOfStock *bool
+	// dietary is the dietary information of the item the node is, or is
+	// part of.
+	dietary *jdw.DietaryInfo
+}
+
+// child returns the context for a node found under key.
+func (n menuNode) child(key string, raw map[string]interface{}) menuNode {
+	c := menuNode{raw: raw, menu: n.menu, category: n.category, calories: n.calories, outOfStock: n.outOfStock, dietary: n.dietary}
+	c.scope = append(append([]string(nil), n.scope...), stringValues(n.raw)...)
+	switch key {
+	case "categories", "sections":
+		c.category = stringField(raw, "name")
+	case "items", "products":
+		info := jdw.ParseDietaryInfo(stringField(raw, "name"), stringField(raw, "description"))
+		c.dietary = &info
+	}
+	if cal, ok := n.raw["calories"].(float64); ok {
+		c.calories = &cal
+	}
+	if out, ok := n.raw["isOutOfStock"].(bool); ok {
+		c.outOfStock = &out
+	}
+	return c
+}
+
+// children returns the map nodes directly under the node's structural keys.
+func (n menuNode) children() []menuNode {
+	var out []menuNode
+	for k, v := range n.raw {
+		if !structuralKeys[k] {
+			continue
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			out = append(out, n.child(k, v))
+		case []interface{}:
+			for _, e := range v {
+				if m, ok := e.(map[string]interface{}); ok {
+					out = append(out, n.child(k, m))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// name returns the node's name, or the label of a portion or option.
+func (n menuNode) name() string {
+	if name, ok := n.raw["name"].(string); ok {
+		return name
+	}
+	return stringField(n.raw, "label")
+}
+
+func (n menuNode) score(t termSpec) float64 {
+	switch t.field {
+	case "":
+		return bestScore(
+			t.textScore(n.name()),
+			t.textScores(weightDescription, stringValues(n.raw)...),
+			t.textScores(weightContext, n.scope...),
+		)
+	case fieldName:
+		return t.textScore(n.name())
+	case fieldDescription:
+		return t.textScore(stringField(n.raw, "description"))
+	case fieldCategory:
+		return t.textScore(n.category)
+	case fieldMenu:
+		return t.textScore(n.menu)
+	case fieldPrice:
+		for _, p := range nodePrices(n.raw) {
+			if t.compare(p) {
+				return 1
+			}
+		}
+		return 0
+	case fieldCalories:
+		if cal, ok := n.raw["calories"].(float64); ok {
+			return boolScore(t.compare(cal))
+		}
+		return boolScore(n.calories != nil && t.compare(*n.calories))
+	case fieldOutOfStock:
+		if out, ok := n.raw["isOutOfStock"].(bool); ok {
+			return boolScore(out == (t.text == fieldOutOfStock))
+		}
+		return boolScore(n.outOfStock != nil && *n.outOfStock == (t.text == fieldOutOfStock))
+	case fieldDiet:
+		return boolScore(n.dietary != nil && n.dietary.Suits(t.text))
+	case fieldAllergen:
+		return boolScore(n.dietary != nil && n.dietary.MayContainAllergen(t.text))
+	}
+	return 0
+}
+
+func (n menuNode) holdsAnywhere(t termSpec) bool {
+	if n.score(t) > 0 {
+		return true
+	}
+	for _, c := range n.children() {
+		if c.holdsAnywhere(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodePrices returns the prices of a raw item or portion option: the portion
+// prices under options.portion, a portion's own value.price.value, or a
+// plain "price" field.
+func nodePrices(raw map[string]interface{}) []float64 {
+	var prices []float64
+	add := func(m map[string]interface{}) {
+		value, _ := m["value"].(map[string]interface{})
+		price, _ := value["price"].(map[string]interface{})
+		if p, ok := price["value"].(float64); ok {
+			prices = append(prices, p)
+		}
+	}
+	add(raw)
+	if options, ok := raw["options"].(map[string]interface{}); ok {
+		if portion, ok := options["portion"].(map[string]interface{}); ok {
+			for _, opt := range mapsIn(portion["options"]) {
+				add(opt)
+			}
+		}
+	}
+	if p, ok := raw["price"].(float64); ok {
+		prices = append(prices, p)
+	}
+	return prices
+}
+
+// stringValues returns the string fields of a node.
+func stringValues(raw map[string]interface{}) []string {
+	var out []string
+	for _, v := range raw {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
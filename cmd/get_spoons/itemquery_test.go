@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func mustParseItemQuery(t *testing.T, s string) *itemQuery {
+	t.Helper()
+	q, err := parseItemQuery(s, true)
+	if err != nil {
+		t.Fatalf("parseItemQuery(%q) failed: %v", s, err)
+	}
+	return q
+}
+
+func TestParseItemQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`name:"stella`, "unterminated quote"},
+		{`(burger OR wrap`, "missing closing parenthesis"},
+		{`burger)`, `unexpected ")"`},
+		{`burger OR`, "expected a term at end of query"},
+		{`OR burger`, `expected a term before "OR"`},
+		{`NOT`, "expected a term after NOT"},
+		{`price<cheap`, `"cheap" is not a number`},
+		{`name>4`, "name only supports name:value"},
+		{`colour:red`, `unknown field "colour"`},
+		{`diet:keto`, `unknown diet "keto"`},
+		{`allergen>1`, "allergen only supports allergen:value"},
+		{`-allergen:peanutz`, `unknown allergen "peanutz"`},
+	}
+	for _, tt := range tests {
+		_, err := parseItemQuery(tt.query, true)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseItemQuery(%q): expected error containing %q, got %v", tt.query, tt.want, err)
+		}
+	}
+
+	if q, err := parseItemQuery("   ", true); q != nil || err != nil {
+		t.Errorf("Expected a blank query to be nil, got %v, %v", q, err)
+	}
+}
+
+func TestItemQueryMatchesItems(t *testing.T) {
+	cal := func(n int) *int { return &n }
+	items := []menuItem{
+		{Name: "Stella Artois", Category: "Beer", Menu: "Drinks", Calories: cal(240), Portions: []portionPrice{{Label: "Pint", Price: 4.2}, {Label: "Half", Price: 2.4}}},
+		{Name: "Peroni", Category: "Beer", Menu: "Drinks", Calories: cal(250), Portions: []portionPrice{{Label: "Pint", Price: 5.1}}},
+		{Name: "Guinness", Description: "Irish stout", Category: "Stout", Menu: "Drinks", OutOfStock: true, Portions: []portionPrice{{Label: "Pint", Price: 4.6}}},
+		{Name: "Beef Burger", Description: "With cheese", Category: "Burgers", Menu: "Food", Calories: cal(900), Portions: []portionPrice{{Price: 9.5}}},
+		{Name: "Chicken Wrap", Category: "Wraps", Menu: "Food", Portions: []portionPrice{{Price: 6}}},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`stella`, []string{"Stella Artois"}},
+		{`pint`, nil},
+		{`beer`, []string{"Peroni", "Stella Artois"}},
+		{`name:"stella artois"`, []string{"Stella Artois"}},
+		{`"artois stella"`, nil},
+		{`price<4.50`, []string{"Stella Artois"}},
+		{`price<=£5.10 category:beer`, []string{"Peroni", "Stella Artois"}},
+		{`price:6`, []string{"Chicken Wrap"}},
+		{`calories<300`, []string{"Peroni", "Stella Artois"}},
+		{`calories>=0 -category:beer`, []string{"Beef Burger"}},
+		{`menu:drinks -outofstock`, []string{"Peroni", "Stella Artois"}},
+		{`outofstock`, []string{"Guinness"}},
+		{`menu:drinks instock price>5`, []string{"Peroni"}},
+		{`burger OR wrap`, []string{"Beef Burger", "Chicken Wrap"}},
+		{`menu:food AND NOT (cheese OR chicken)`, nil},
+		{`(stella OR guinness) description:irish`, []string{"Guinness"}},
+		{`NOT NOT stella`, []string{"Stella Artois"}},
+		{`"OR"`, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, it := range filterItems(items, mustParseItemQuery(t, tt.query)) {
+			got = append(got, it.Name)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestItemQueryDiet(t *testing.T) {
+	items := []menuItem{
+		newMenuItem(menuItem{}, map[string]interface{}{"name": "Vegan Burger", "description": "Plant-based patty. Contains: gluten, soya."}),
+		newMenuItem(menuItem{}, map[string]interface{}{"name": "Superfood Salad", "description": "Vegan, gluten-free. May contain: nuts."}),
+		newMenuItem(menuItem{}, map[string]interface{}{"name": "Cheese Toastie (V)", "description": "Contains: gluten, milk."}),
+		newMenuItem(menuItem{}, map[string]interface{}{"name": "Fish & Chips", "description": "Contains: fish, gluten."}),
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`diet:vegan`, []string{"Superfood Salad", "Vegan Burger"}},
+		{`diet:V`, []string{"Cheese Toastie (V)", "Superfood Salad", "Vegan Burger"}},
+		{`diet:"gluten free"`, []string{"Superfood Salad"}},
+		{`diet:vegan -allergen:soy`, []string{"Superfood Salad"}},
+		{`-allergen:"tree nuts" -allergen:dairy`, []string{"Fish & Chips", "Vegan Burger"}},
+		{`allergen:fish`, []string{"Fish & Chips"}},
+	}
+	for _, tt := range tests {
+		q := mustParseItemQuery(t, tt.query)
+		var got []string
+		for _, it := range items {
+			if q.matches(it) {
+				got = append(got, it.Name)
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestItemSearchPrunesMenuTree(t *testing.T) {
+	menu := func() map[string]interface{} {
+		var venue map[string]interface{}
+		json.Unmarshal([]byte(`{"menus": [{"name": "Drinks", "details": {"categories": [
+			{"name": "Beer", "itemGroups": [{"items": [
+				{"name": "Stella Artois", "calories": 240, "isOutOfStock": false, "options": {"portion": {"options": [
+					{"label": "Pint", "value": {"price": {"value": 4.2}}},
+					{"label": "Half", "value": {"price": {"value": 2.4}}}
+				]}}},
+				{"name": "Peroni", "calories": 250, "isOutOfStock": true, "options": {"portion": {"options": [
+					{"label": "Pint", "value": {"price": {"value": 5.1}}}
+				]}}}
+			]}]},
+			{"name": "Wine", "itemGroups": [{"items": [
+				{"name": "Merlot", "isOutOfStock": false, "options": {"portion": {"options": [
+					{"label": "175ml", "value": {"price": {"value": 5.5}}}
+				]}}}
+			]}]}
+		]}}]}`), &venue)
+		return venue
+	}
+	names := func(venue map[string]interface{}) []string {
+		var out []string
+		for _, it := range extractItems(venue) {
+			out = append(out, it.Name)
+		}
+		return out
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// A matching category is kept whole, as before.
+		{`beer`, []string{"Stella Artois", "Peroni"}},
+		// Words may be split between an item and its category.
+		{`beer stella`, []string{"Stella Artois"}},
+		{`category:beer price<4.50`, []string{"Stella Artois"}},
+		{`-outofstock`, []string{"Stella Artois", "Merlot"}},
+		{`-calories>245`, []string{"Stella Artois", "Merlot"}},
+		{`beer -peroni`, []string{"Stella Artois"}},
+		{`-beer`, []string{"Merlot"}},
+		{`menu:drinks calories>245`, []string{"Peroni"}},
+		{`name:half`, []string{"Stella Artois"}},
+	}
+	for _, tt := range tests {
+		venue := menu()
+		if !filterVenueForItems(venue, mustParseItemQuery(t, tt.query)) {
+			t.Errorf("%s: expected a match", tt.query)
+			continue
+		}
+		if got := names(venue); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+
+	// Matching a portion keeps its item but prunes the other portions.
+	venue := menu()
+	filterVenueForItems(venue, mustParseItemQuery(t, "name:half"))
+	items := extractItems(venue)
+	if len(items) != 1 || len(items[0].Portions) != 1 || items[0].Portions[0].Label != "Half" {
+		t.Errorf("Expected only the Half portion to be kept, got %+v", items)
+	}
+
+	if filterVenueForItems(menu(), mustParseItemQuery(t, "price>10")) {
+		t.Error("Expected no match for price>10")
+	}
+}
+
+func TestItemQueryFuzzy(t *testing.T) {
+	items := []menuItem{
+		{Name: "Peroni Nastro Azzurro", Category: "Beer"},
+		{Name: "Guinness", Category: "Stout"},
+		{Name: "Coca-Cola Zero", Category: "Soft drinks"},
+		{Name: "Beef Burger", Category: "Burgers"},
+		{Name: "Chicken Wrap", Description: "With burger sauce", Category: "Wraps"},
+		{Name: "Chunky chips", Category: "Sides"},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`peroni nastro`, []string{"Peroni Nastro Azzurro"}},
+		{`guiness`, []string{"Guinness"}},
+		{`coke`, []string{"Coca-Cola Zero"}},
+		{`fries`, []string{"Chunky chips"}},
+		{`burger`, []string{"Beef Burger", "Chicken Wrap"}},
+		{`burg`, []string{"Beef Burger", "Chicken Wrap"}},
+		// Exclusions stay strict, and short words need no typos.
+		{`category:beer -beef`, []string{"Peroni Nastro Azzurro"}},
+		{`beer`, []string{"Peroni Nastro Azzurro"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, it := range filterItems(items, mustParseItemQuery(t, tt.query)) {
+			got = append(got, it.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+
+	got := filterItems(items, mustParseItemQuery(t, "burger"))
+	if got[0].Score != 1 || got[1].Score != 0.7 {
+		t.Errorf("Expected a name match to outrank a description match, got %v and %v", got[0].Score, got[1].Score)
+	}
+
+	strict, err := parseItemQuery("guiness", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := filterItems(items, strict); len(got) != 0 {
+		t.Errorf("Expected a strict query to need an exact substring, got %v", got)
+	}
+	strict, _ = parseItemQuery("burger", false)
+	if got := filterItems(items, strict); len(got) != 2 || got[0].Score != 0 {
+		t.Errorf("Expected unscored strict matches, got %+v", got)
+	}
+}
+
+func TestWordScore(t *testing.T) {
+	tests := []struct {
+		q, w string
+		want float64
+	}{
+		{"stella", "stella", scoreExact},
+		{"pint", "pints", scorePrefix},
+		{"berry", "strawberry", scoreSubstring},
+		{"guiness", "guinness", 0.7},
+		{"carlsbreg", "carlsberg", 0.55},
+		{"beef", "beer", 0},
+		{"peroni", "merlot", 0},
+	}
+	for _, tt := range tests {
+		if got := wordScore(tt.q, tt.w); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("wordScore(%q, %q): expected %v, got %v", tt.q, tt.w, tt.want, got)
+		}
+	}
+}
+
+func TestItemSearchRanksMenuTree(t *testing.T) {
+	var venue map[string]interface{}
+	json.Unmarshal([]byte(`{"menus": [{"name": "Food", "details": {"categories": [
+		{"name": "Wraps", "itemGroups": [{"items": [
+			{"name": "Chicken Wrap", "description": "With burger sauce"},
+			{"name": "Falafel Wrap"}
+		]}]},
+		{"name": "Burgers", "itemGroups": [{"items": [
+			{"name": "Beef Burger"}
+		]}]}
+	]}}]}`), &venue)
+
+	if !filterVenueForItems(venue, mustParseItemQuery(t, "burgr")) {
+		t.Fatal("Expected a match for burgr")
+	}
+	items := extractItems(venue)
+	if len(items) != 2 || items[0].Name != "Beef Burger" {
+		t.Errorf("Expected Beef Burger to be ranked first, got %+v", items)
+	}
+	menus := venue["menus"].([]interface{})
+	categories := menus[0].(map[string]interface{})["details"].(map[string]interface{})["categories"].([]interface{})
+	group := categories[0].(map[string]interface{})["itemGroups"].([]interface{})[0]
+	burger := group.(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})
+	if burger["searchScore"] != 0.7 {
+		t.Errorf("Expected Beef Burger to match with score 0.7, got %v", burger["searchScore"])
+	}
+}
+
+func TestItemSearchInvalidQuery(t *testing.T) {
+	err := Run([]string{"-item-search", "price<cheap"})
+	if err == nil || !strings.Contains(err.Error(), "invalid -item-search") {
+		t.Errorf("Expected an invalid query error, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// menuItem is a flattened view of an item on an expanded venue's menu.
+type menuItem struct {
+	VenueID     int            `json:"venueId"`
+	VenueRef    int            `json:"venueRef"`
+	VenueName   string         `json:"venueName,omitempty"`
+	MenuID      int            `json:"menuId"`
+	Menu        string         `json:"menu,omitempty"`
+	Category    string         `json:"category,omitempty"`
+	ID          int            `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Calories    *int           `json:"calories,omitempty"`
+	OutOfStock  bool           `json:"outOfStock"`
+	Portions    []portionPrice `json:"portions"`
+	jdw.DietaryInfo
+	// Score is the relevance of the item to a fuzzy item search.
+	Score float64 `json:"score,omitempty"`
+}
+
+// portionPrice is a single priced portion of a menu item.
+type portionPrice struct {
+	Label string  `json:"label"`
+	Price float64 `json:"price"`
+}
+
+// extractItems flattens the menus of an expanded venue (as produced by
+// expandVenue with items) into a list of items. Menus follow the
+// categories -> itemGroups -> items layout from openapi.yaml; menus with a
+// top-level "items" list are also accepted.
+func extractItems(venue map[string]interface{}) []menuItem {
+	base := menuItem{
+		VenueID:   intField(venue, "id"),
+		VenueRef:  intField(venue, "venueRef"),
+		VenueName: stringField(venue, "name"),
+	}
+
+	var items []menuItem
+	menus, _ := venue["menus"].([]interface{})
+	for _, m := range menus {
+		menu, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		details, ok := menu["details"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		menuBase := base
+		menuBase.MenuID = intField(menu, "id")
+		menuBase.Menu = stringField(menu, "name")
+
+		for _, it := range mapsIn(details["items"]) {
+			items = append(items, newMenuItem(menuBase, it))
+		}
+		for _, category := range mapsIn(details["categories"]) {
+			catBase := menuBase
+			catBase.Category = stringField(category, "name")
+			for _, group := range mapsIn(category["itemGroups"]) {
+				for _, it := range mapsIn(group["items"]) {
+					items = append(items, newMenuItem(catBase, it))
+				}
+			}
+		}
+	}
+	return items
+}
+
+func newMenuItem(base menuItem, raw map[string]interface{}) menuItem {
+	item := base
+	item.ID = intField(raw, "id")
+	item.Name = stringField(raw, "name")
+	item.Description = stringField(raw, "description")
+	item.OutOfStock, _ = raw["isOutOfStock"].(bool)
+	item.DietaryInfo = jdw.ParseDietaryInfo(item.Name, item.Description)
+	if cal, ok := raw["calories"].(float64); ok {
+		c := int(cal)
+		item.Calories = &c
+	}
+
+	if options, ok := raw["options"].(map[string]interface{}); ok {
+		if portion, ok := options["portion"].(map[string]interface{}); ok {
+			for _, opt := range mapsIn(portion["options"]) {
+				value, _ := opt["value"].(map[string]interface{})
+				price, _ := value["price"].(map[string]interface{})
+				if p, ok := price["value"].(float64); ok {
+					item.Portions = append(item.Portions, portionPrice{Label: stringField(opt, "label"), Price: p})
+				}
+			}
+		}
+	}
+	if len(item.Portions) == 0 {
+		if p, ok := raw["price"].(float64); ok {
+			item.Portions = []portionPrice{{Price: p}}
+		}
+	}
+	return item
+}
+
+// fetchVenueItems fetches the venues matching filter, up to limit (0 for
+// all), and the items on their menus by venue ID. opts sets the
+// concurrency and retries; menus and items are always fetched.
+//
+// Venues with a failed request are left out rather than reported with
+// missing items. partial is then a *partialError, to be returned once the
+// results are written; err is set if no venue could be fetched in full.
+func fetchVenueItems(client *jdw.Client, filter watchFilter, limit int, opts expandOptions) (venues []jdw.Venue, items map[int][]menuItem, partial, err error) {
+	all, err := client.GetVenues()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("fetching venues: %w", err)
+	}
+	var selected []jdw.Venue
+	for _, v := range all {
+		if filter.matches(v) {
+			selected = append(selected, v)
+		}
+	}
+	if limit > 0 && limit < len(selected) {
+		selected = selected[:limit]
+	}
+
+	opts.IncludeMenus, opts.IncludeItems = true, true
+	expanded, failures := expandVenues(client, selected, opts)
+	failed := make(map[int]bool)
+	for _, f := range failures {
+		failed[f.VenueID] = true
+	}
+	items = make(map[int][]menuItem)
+	for _, details := range expanded {
+		if id := intField(details, "id"); !failed[id] {
+			items[id] = append(items[id], extractItems(details)...)
+		}
+	}
+	for _, v := range selected {
+		if !failed[v.ID] {
+			venues = append(venues, v)
+		}
+	}
+
+	if len(failures) == 0 {
+		return venues, items, nil, nil
+	}
+	if len(venues) == 0 {
+		return nil, nil, nil, fmt.Errorf("no venues could be fetched: %d request(s) failed across %d venues", len(failures), len(selected))
+	}
+	slog.Warn("Leaving out venues whose menus could not be fetched", "venues", len(selected)-len(venues))
+	return venues, items, &partialError{failures: len(failures), venues: len(selected)}, nil
+}
+
+// mapsIn returns the map elements of v if it is a JSON array.
+func mapsIn(v interface{}) []map[string]interface{} {
+	list, _ := v.([]interface{})
+	var out []map[string]interface{}
+	for _, e := range list {
+		if m, ok := e.(map[string]interface{}); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func intField(m map[string]interface{}, key string) int {
+	f, _ := m[key].(float64)
+	return int(f)
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
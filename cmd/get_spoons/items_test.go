@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+func TestExtractItems(t *testing.T) {
+	replay, err := jdw.NewReplayTransport(testFixturesDir)
+	if err != nil {
+		t.Fatalf("NewReplayTransport failed: %v", err)
+	}
+	client := jdw.NewClient("v", "t", "u")
+	client.SetTransport(replay)
+
+	details, failures := expandVenue(context.Background(), client, jdw.Venue{ID: 1001, VenueRef: 7001}, expandOptions{IncludeItems: true})
+	if len(failures) > 0 {
+		t.Fatalf("Unexpected failures: %v", failures)
+	}
+
+	items := extractItems(details)
+	if len(items) != 11 {
+		t.Fatalf("Expected 11 items, got %d", len(items))
+	}
+
+	stella := items[0]
+	if stella.Name != "Stella Artois" || stella.Category != "Beer" || stella.Menu != "Drinks" || stella.VenueRef != 7001 {
+		t.Errorf("Unexpected first item: %+v", stella)
+	}
+	if stella.Calories == nil || *stella.Calories != 227 {
+		t.Errorf("Expected 227 calories, got %v", stella.Calories)
+	}
+	if len(stella.Portions) != 2 || stella.Portions[1] != (portionPrice{Label: "Pint", Price: 4.49}) {
+		t.Errorf("Unexpected portions: %v", stella.Portions)
+	}
+
+	var carling menuItem
+	for _, it := range items {
+		if it.Name == "Carling" {
+			carling = it
+		}
+	}
+	if !carling.OutOfStock {
+		t.Error("Expected Carling to be out of stock")
+	}
+
+	t.Run("FlatItems", func(t *testing.T) {
+		venue := map[string]interface{}{
+			"menus": []interface{}{
+				map[string]interface{}{"details": map[string]interface{}{
+					"items": []interface{}{map[string]interface{}{"name": "Burger", "price": 10.0}},
+				}},
+			},
+		}
+		items := extractItems(venue)
+		if len(items) != 1 || items[0].Portions[0].Price != 10 {
+			t.Errorf("Unexpected items: %+v", items)
+		}
+	})
+}
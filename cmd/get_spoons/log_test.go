@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "json", "warn")
+	if err != nil {
+		t.Fatalf("newLogger failed: %v", err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "venues", 3)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected a single JSON line, got %q: %v", buf.String(), err)
+	}
+	if line["msg"] != "shown" || line["level"] != "WARN" || line["venues"] != float64(3) {
+		t.Errorf("Unexpected log line: %v", line)
+	}
+
+	buf.Reset()
+	logger, err = newLogger(&buf, "text", "debug")
+	if err != nil {
+		t.Fatalf("newLogger failed: %v", err)
+	}
+	logger.Debug("details", "status", 200)
+	if !strings.Contains(buf.String(), `level=DEBUG msg=details status=200`) {
+		t.Errorf("Unexpected text output: %q", buf.String())
+	}
+
+	if _, err := newLogger(&buf, "xml", "info"); err == nil {
+		t.Error("Expected an error for an invalid format")
+	}
+	if _, err := newLogger(&buf, "text", "loud"); err == nil {
+		t.Error("Expected an error for an invalid level")
+	}
+}
@@ -1,55 +1,116 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/KRoperUK/get_spoons/jdw"
 	"github.com/lithammer/fuzzysearch/fuzzy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v3"
 )
 
 var Version = "v0.0.0"
 
 func main() {
-	if err := Run(os.Args[1:]); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
+	os.Exit(exitCode(Run(os.Args[1:])))
 }
 
-// Run executes the CLI logic and returns any errors.
+// Run executes the CLI logic and returns any errors. A *partialError is
+// returned when output was written but some venue requests failed.
 func Run(args []string) error {
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Warn("Flushing traces failed", "error", err)
+		}
+	}()
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "serve":
+			return runServe(args[1:])
+		case "exporter":
+			return runExporter(args[1:])
+		case "watch":
+			return runWatch(args[1:])
+		case "feed":
+			return runFeed(args[1:])
+		case "config":
+			return runConfig(args[1:])
+		case "doctor":
+			return runDoctor(args[1:])
+		case "schema-check":
+			return runSchemaCheck(args[1:])
+		case "discover":
+			return runDiscover(args[1:])
+		case "index":
+			return runIndex(args[1:])
+		case "diet-report":
+			return runDietReport(args[1:], os.Stdout)
+		case "nutrition":
+			return runNutrition(args[1:], os.Stdout)
+		case "drink-value":
+			return runDrinkValue(args[1:], os.Stdout)
+		}
+	}
+
 	fs := flag.NewFlagSet("get_spoons", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
 	version := fs.Bool("version", false, "Print version and exit")
 	outputFile := fs.String("output", "", "Output file path (default: stdout)")
 	csvOutput := fs.Bool("csv", false, "Output as CSV")
 	yamlOutput := fs.Bool("yaml", false, "Output as YAML")
 	expand := fs.Bool("expand", false, "Expand venue details (only valid with -json)")
-	appVersion := fs.String("app-version", getEnv("JDW_APP_VERSION", "6.7.1"), "JDW App Version")
-	token := fs.String("token", getEnv("JDW_TOKEN", "1|SFS9MMnn5deflq0BMcUTSijwSMBB4mc7NSG2rOhqb2765466"), "JDW Bearer Token")
-	userAgent := fs.String("user-agent", getEnv("JDW_USER_AGENT", "Mozilla/5.0 (iPhone; CPU iPhone OS 18_7 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148"), "User Agent")
-	debugEnabled := fs.Bool("debug", false, "Enable debug logging")
 	limit := fs.Int("limit", 0, "Limit number of venues (0 for all)")
 	menus := fs.Bool("menus", false, "Fetch menus for each venue (implies -expand)")
 	items := fs.Bool("items", false, "Fetch menu items (implies -menus)")
 	concurrency := fs.Int("concurrency", 1, "Number of concurrent requests")
 	venueID := fs.Int("venue", 0, "Specific venue ID to fetch")
 	searchQuery := fs.String("search", "", "Search for a venue by name")
-	itemSearch := fs.String("item-search", "", "Search for a menu item (e.g. 'stella pint'). Only valid for a single venue.")
-	noFuzzy := fs.Bool("no-fuzzy", false, "Disable fuzzy searching (use case-insensitive substring match)")
-	if err := fs.Parse(args); err != nil {
+	itemSearch := fs.String("item-search", "", "Search for menu items, e.g. 'stella pint' or 'category:beer price<4.50 -outofstock'. Only valid for a single venue.")
+	diet := fs.String("diet", "", "Only include menu items suitable for these diets (comma-separated: vegan, vegetarian, gluten-free; implies -items)")
+	excludeAllergens := fs.String("exclude-allergen", "", "Exclude menu items that contain or may contain these allergens (comma-separated, e.g. peanuts,milk; implies -items). Items without allergen information are not excluded")
+	noFuzzy := fs.Bool("no-fuzzy", false, "Disable fuzzy venue and item searching (use case-insensitive substring match)")
+	retries := fs.Int("retries", 0, "Number of times to retry failed detail, menu and item requests")
+	maxFailures := fs.Int("max-failures", -1, "Maximum number of failed requests tolerated before exiting with a failure status (-1 for no limit)")
+	failureReportPath := fs.String("failure-report", "", "Write a JSON report of failed requests to this path (default: <output>.failures.json when -output is set)")
+	sortKey := fs.String("sort", "", "Sort venues by key: name, id, postcode (default: API/search order)")
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := validateSortKey(*sortKey); err != nil {
+		return err
+	}
+	itemQuery, err := parseItemQuery(*itemSearch, !*noFuzzy)
+	if err != nil {
+		return fmt.Errorf("invalid -item-search: %w", err)
+	}
+	dietSearch, err := dietFilterQuery(*diet, *excludeAllergens)
+	if err != nil {
+		return err
+	}
+	dietQuery, err := parseItemQuery(dietSearch, false)
+	if err != nil {
 		return err
 	}
+	itemQuery = itemQuery.and(dietQuery)
+	querySummary := strings.TrimSpace(*itemSearch + " " + dietSearch)
 
 	if *version {
 		v := Version
@@ -62,24 +123,22 @@ func Run(args []string) error {
 		return nil
 	}
 
-	client := jdw.NewClient(*appVersion, *token, *userAgent)
-	if apiURL := os.Getenv("JDW_API_URL"); apiURL != "" {
-		client.SetBaseURL(apiURL)
+	client, err := cf.newClient()
+	if err != nil {
+		return err
 	}
-	client.SetDebug(*debugEnabled)
 
 	var venues []jdw.Venue
-	var err error
 
 	if *venueID != 0 {
-		fmt.Fprintf(os.Stderr, "Fetching venue %d...\n", *venueID)
+		slog.Info("Fetching venue", "venue_id", *venueID)
 		v, err := client.GetVenue(*venueID)
 		if err != nil {
 			return fmt.Errorf("fetching venue %d: %w", *venueID, err)
 		}
 		venues = []jdw.Venue{*v}
 	} else {
-		fmt.Fprintln(os.Stderr, "Fetching venues from JDW API...")
+		slog.Info("Fetching venues from JDW API")
 		venues, err = client.GetVenues()
 		if err != nil {
 			return fmt.Errorf("fetching venues: %w", err)
@@ -87,8 +146,13 @@ func Run(args []string) error {
 	}
 
 	if *searchQuery != "" {
+		mode := "fuzzy"
+		if *noFuzzy {
+			mode = "substring"
+		}
+		slog.Info("Searching for venues", "query", *searchQuery, "mode", mode)
 		venues = searchVenues(venues, *searchQuery, *noFuzzy)
-		fmt.Fprintf(os.Stderr, "Found %d matches.\n", len(venues))
+		slog.Info("Found matches", "matches", len(venues))
 	}
 
 	if *itemSearch != "" {
@@ -99,42 +163,62 @@ func Run(args []string) error {
 			if *venueID != 0 {
 				// This shouldn't happen as venues would have length 1
 			} else {
-				fmt.Fprintf(os.Stderr, "Item search is only allowed on an individual venue. Using the first match: %s (ID: %d)\n", venues[0].Name, venues[0].ID)
+				slog.Warn("Item search is only allowed on an individual venue; using the first match", "venue", venues[0].Name, "venue_id", venues[0].ID)
 				venues = venues[:1]
 			}
 		}
 		*items = true // Ensure we fetch items
 	}
+	if dietQuery != nil {
+		*items = true
+	}
+
+	if *sortKey != "" {
+		sortVenues(venues, *sortKey)
+	}
 
 	if *limit > 0 && *limit < len(venues) {
-		fmt.Fprintf(os.Stderr, "Limiting output to %d venues.\n", *limit)
+		slog.Info("Limiting output", "venues", *limit)
 		venues = venues[:*limit]
 	}
 
 	if *items && len(venues) > 10 {
-		fmt.Fprintf(os.Stderr, "WARNING: Fetching menu items for %d venues. Fully expanded venue data is large (approx 10MB per venue); total output could exceed %d MB.\n", len(venues), len(venues)*20)
+		slog.Warn("Fetching menu items for many venues; fully expanded venue data is large (approx 10MB per venue)", "venues", len(venues), "max_output_mb", len(venues)*20)
 	}
 	var finalData interface{}
 	finalData = venues // Default to standard venues
 
-	if *expand || *menus || *items {
-		finalData = expandVenues(client, venues, *concurrency, *menus, *items)
+	expanded := *expand || *menus || *items
+	var (
+		failures      []crawlFailure
+		expandedCount int
+	)
+	if expanded {
+		var detailedVenues []map[string]interface{}
+		detailedVenues, failures = expandVenues(client, venues, expandOptions{
+			Concurrency:  *concurrency,
+			IncludeMenus: *menus,
+			IncludeItems: *items,
+			Retries:      *retries,
+		})
+		finalData = detailedVenues
+		expandedCount = len(detailedVenues)
 	}
 
-	if *itemSearch != "" {
+	if itemQuery != nil {
 		detailedVenues, ok := finalData.([]map[string]interface{})
 		if ok {
 			var filtered []map[string]interface{}
 			for _, dv := range detailedVenues {
-				if filterVenueForItems(dv, *itemSearch) {
+				if filterVenueForItems(dv, itemQuery) {
 					filtered = append(filtered, dv)
 				}
 			}
 			finalData = filtered
 			if len(filtered) == 0 {
-				fmt.Fprintf(os.Stderr, "No items matching \"%s\" found.\n", *itemSearch)
+				slog.Info("No matching items found", "query", querySummary)
 			} else {
-				fmt.Fprintf(os.Stderr, "Filtered results for items matching \"%s\".\n", *itemSearch)
+				slog.Info("Filtered results for matching items", "query", querySummary)
 			}
 		}
 	}
@@ -142,7 +226,7 @@ func Run(args []string) error {
 	// Output Section
 	var out io.Writer = os.Stdout
 	if *outputFile != "" {
-		fmt.Fprintf(os.Stderr, "Successfully fetched %d venues. Writing to %s...\n", len(venues), *outputFile)
+		slog.Info("Writing output", "venues", len(venues), "path", *outputFile)
 		f, err := os.Create(*outputFile)
 		if err != nil {
 			return fmt.Errorf("creating output file: %w", err)
@@ -157,20 +241,77 @@ func Run(args []string) error {
 	}
 
 	if *outputFile != "" {
-		fmt.Fprintln(os.Stderr, "Done.")
+		slog.Info("Done")
+	}
+
+	if !expanded {
+		return nil
+	}
+
+	reportPath := *failureReportPath
+	if reportPath == "" && *outputFile != "" {
+		reportPath = *outputFile + ".failures.json"
+	}
+	if reportPath != "" {
+		report := failureReport{
+			GeneratedAt: time.Now().UTC(),
+			Venues:      len(venues),
+			Expanded:    expandedCount,
+			Failures:    failures,
+		}
+		if report.Failures == nil {
+			report.Failures = []crawlFailure{}
+		}
+		if err := writeFailureReport(reportPath, report); err != nil {
+			return fmt.Errorf("writing failure report: %w", err)
+		}
+	}
+
+	if len(failures) > 0 {
+		// A crawl that expanded nothing produced no data, so it failed
+		// rather than partly succeeded.
+		if expandedCount == 0 {
+			return fmt.Errorf("no venues could be expanded: %d request(s) failed across %d venues", len(failures), len(venues))
+		}
+		if *maxFailures >= 0 && len(failures) > *maxFailures {
+			return fmt.Errorf("%d failed requests exceeded -max-failures %d", len(failures), *maxFailures)
+		}
+		return &partialError{failures: len(failures), venues: len(venues)}
 	}
 	return nil
 }
 
-func expandVenues(client *jdw.Client, venues []jdw.Venue, concurrency int, includeMenus, includeItems bool) []map[string]interface{} {
-	fmt.Fprintf(os.Stderr, "Fetching details for %d venues...\n", len(venues))
+// expandOptions controls how much data expandVenues fetches per venue.
+type expandOptions struct {
+	Concurrency  int
+	IncludeMenus bool
+	IncludeItems bool
+	Retries      int
+}
 
+// expandVenues fetches details (and optionally menus and items) for each venue.
+// Results are returned in the same order as the input venues regardless of
+// concurrency; venues whose details could not be fetched are omitted. Every
+// failed request is returned as a crawlFailure.
+func expandVenues(client *jdw.Client, venues []jdw.Venue, opts expandOptions) ([]map[string]interface{}, []crawlFailure) {
+	slog.Info("Fetching venue details", "venues", len(venues))
+
+	concurrency := opts.Concurrency
 	if concurrency < 1 {
 		concurrency = 1
 	}
 
+	ctx, span := tracer.Start(context.Background(), "expandVenues", trace.WithAttributes(
+		attribute.Int("jdw.venues", len(venues)),
+		attribute.Int("jdw.concurrency", concurrency),
+		attribute.Bool("jdw.include_menus", opts.IncludeMenus),
+		attribute.Bool("jdw.include_items", opts.IncludeItems),
+	))
+	defer span.End()
+
 	var (
-		detailedVenues []map[string]interface{}
+		results        = make([]map[string]interface{}, len(venues))
+		failures       []crawlFailure
 		wg             sync.WaitGroup
 		mu             sync.Mutex
 		processedCount int
@@ -179,67 +320,150 @@ func expandVenues(client *jdw.Client, venues []jdw.Venue, concurrency int, inclu
 
 	reportProgress := func() {
 		processedCount++
-		fmt.Fprintf(os.Stderr, "\rProcessing venue %d/%d", processedCount, len(venues))
+		slog.Debug("Processed venue", "done", processedCount, "total", len(venues))
 	}
 
-	for _, v := range venues {
+	for i, v := range venues {
 		wg.Add(1)
 		sem <- struct{}{}
 
-		go func(v jdw.Venue) {
+		go func(i int, v jdw.Venue) {
 			defer wg.Done()
 			defer func() { <-sem }()
 
-			details, err := client.GetVenueDetails(v.VenueRef)
-			if err != nil {
-				mu.Lock()
-				fmt.Fprintf(os.Stderr, "\nError fetching details for venue ID %d (Ref %d): %v\n", v.ID, v.VenueRef, err)
-				reportProgress()
-				mu.Unlock()
-				return
-			}
+			details, venueFailures := expandVenue(ctx, client, v, opts)
 
-			if includeMenus || includeItems {
-				if salesAreas, ok := details["salesAreas"].([]interface{}); ok && len(salesAreas) > 0 {
-					if firstArea, ok := salesAreas[0].(map[string]interface{}); ok {
-						if salesAreaIDFloat, ok := firstArea["id"].(float64); ok {
-							salesAreaID := int(salesAreaIDFloat)
-							menuData, err := client.GetMenus(v.VenueRef, salesAreaID)
-							if err != nil {
-								fmt.Fprintf(os.Stderr, "\nError fetching menus for venue %d: %v\n", v.VenueRef, err)
-							} else {
-								if includeItems {
-									for mIdx, mVal := range menuData {
-										if menuMap, ok := mVal.(map[string]interface{}); ok {
-											if menuIDFloat, ok := menuMap["id"].(float64); ok {
-												menuID := int(menuIDFloat)
-												menuDetails, err := client.GetMenuItems(v.VenueRef, salesAreaID, menuID)
-												if err != nil {
-													fmt.Fprintf(os.Stderr, "\nError fetching items for menu %d (Venue %d): %v\n", menuID, v.VenueRef, err)
-												} else {
-													menuMap["details"] = menuDetails
-													menuData[mIdx] = menuMap
-												}
-											}
-										}
-									}
-								}
-								details["menus"] = menuData
-							}
-						}
-					}
+			mu.Lock()
+			defer mu.Unlock()
+			failures = append(failures, venueFailures...)
+			if details == nil {
+				slog.Error("Fetching venue details failed", "venue_id", v.ID, "venue_ref", v.VenueRef, "error", venueFailures[0].Error)
+			} else {
+				for _, f := range venueFailures {
+					slog.Error("Request failed", "endpoint", f.Endpoint, "venue_ref", v.VenueRef, "error", f.Error)
 				}
 			}
-
-			mu.Lock()
-			detailedVenues = append(detailedVenues, details)
+			results[i] = details
 			reportProgress()
-			mu.Unlock()
-		}(v)
+		}(i, v)
 	}
 	wg.Wait()
-	fmt.Fprintln(os.Stderr, "\nDone fetching details.")
-	return detailedVenues
+	slog.Info("Done fetching details", "failures", len(failures))
+	span.SetAttributes(attribute.Int("jdw.failures", len(failures)))
+
+	// Failures are appended in completion order; sort them so reports are stable.
+	sort.SliceStable(failures, func(i, j int) bool {
+		a, b := failures[i], failures[j]
+		if a.VenueRef != b.VenueRef {
+			return a.VenueRef < b.VenueRef
+		}
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		return a.MenuID < b.MenuID
+	})
+
+	var detailedVenues []map[string]interface{}
+	for _, details := range results {
+		if details != nil {
+			detailedVenues = append(detailedVenues, details)
+		}
+	}
+	return detailedVenues, failures
+}
+
+// expandVenue fetches details for a single venue, plus its menus and items if
+// requested. It returns nil details if the venue details could not be fetched;
+// failures fetching menus or items leave the venue partially expanded. Its
+// requests are traced as children of an "expandVenue" span under ctx.
+func expandVenue(ctx context.Context, client *jdw.Client, v jdw.Venue, opts expandOptions) (details map[string]interface{}, failures []crawlFailure) {
+	ctx, span := tracer.Start(ctx, "expandVenue", venueAttrs(v))
+	defer func() {
+		span.SetAttributes(attribute.Int("jdw.failures", len(failures)))
+		if details == nil {
+			span.SetStatus(codes.Error, "venue details could not be fetched")
+		}
+		span.End()
+	}()
+
+	recordFailure := func(endpoint string, salesAreaID, menuID, attempts int, err error) {
+		failures = append(failures, crawlFailure{
+			VenueID:     v.ID,
+			VenueRef:    v.VenueRef,
+			VenueName:   v.Name,
+			Endpoint:    endpoint,
+			SalesAreaID: salesAreaID,
+			MenuID:      menuID,
+			Class:       classifyError(err),
+			Attempts:    attempts,
+			Error:       err.Error(),
+		})
+	}
+
+	attempts, err := withRetry(opts.Retries, func() (err error) {
+		details, err = client.GetVenueDetailsContext(ctx, v.VenueRef)
+		return err
+	})
+	if err != nil {
+		recordFailure(endpointVenueDetails, 0, 0, attempts, err)
+		return nil, failures
+	}
+
+	if !opts.IncludeMenus && !opts.IncludeItems {
+		return details, nil
+	}
+
+	salesAreas, ok := details["salesAreas"].([]interface{})
+	if !ok || len(salesAreas) == 0 {
+		return details, nil
+	}
+	firstArea, ok := salesAreas[0].(map[string]interface{})
+	if !ok {
+		return details, nil
+	}
+	salesAreaIDFloat, ok := firstArea["id"].(float64)
+	if !ok {
+		return details, nil
+	}
+	salesAreaID := int(salesAreaIDFloat)
+	span.SetAttributes(jdw.AttrSalesAreaID.Int(salesAreaID))
+
+	var menuData []interface{}
+	attempts, err = withRetry(opts.Retries, func() (err error) {
+		menuData, err = client.GetMenusContext(ctx, v.VenueRef, salesAreaID)
+		return err
+	})
+	if err != nil {
+		recordFailure(endpointMenus, salesAreaID, 0, attempts, err)
+		return details, failures
+	}
+
+	if opts.IncludeItems {
+		for mIdx, mVal := range menuData {
+			menuMap, ok := mVal.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			menuIDFloat, ok := menuMap["id"].(float64)
+			if !ok {
+				continue
+			}
+			menuID := int(menuIDFloat)
+			var menuDetails map[string]interface{}
+			attempts, err := withRetry(opts.Retries, func() (err error) {
+				menuDetails, err = client.GetMenuItemsContext(ctx, v.VenueRef, salesAreaID, menuID)
+				return err
+			})
+			if err != nil {
+				recordFailure(endpointMenuItems, salesAreaID, menuID, attempts, err)
+				continue
+			}
+			menuMap["details"] = menuDetails
+			menuData[mIdx] = menuMap
+		}
+	}
+	details["menus"] = menuData
+	return details, failures
 }
 
 func writeFormattedOutput(w io.Writer, venues []jdw.Venue, finalData interface{}, asCSV, asYAML bool) error {
@@ -307,9 +531,42 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// validSortKeys lists the keys accepted by the -sort flag.
+var validSortKeys = []string{"name", "id", "postcode"}
+
+func validateSortKey(key string) error {
+	if key == "" {
+		return nil
+	}
+	for _, k := range validSortKeys {
+		if key == k {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid sort key %q (valid: %s)", key, strings.Join(validSortKeys, ", "))
+}
+
+// sortVenues sorts venues in place by the given key. Ties are broken by ID so
+// that the resulting order is fully deterministic.
+func sortVenues(venues []jdw.Venue, key string) {
+	sort.SliceStable(venues, func(i, j int) bool {
+		a, b := venues[i], venues[j]
+		switch key {
+		case "name":
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+		case "postcode":
+			if a.Address.Postcode != b.Address.Postcode {
+				return a.Address.Postcode < b.Address.Postcode
+			}
+		}
+		return a.ID < b.ID
+	})
+}
+
 func searchVenues(venues []jdw.Venue, searchQuery string, noFuzzy bool) []jdw.Venue {
 	if noFuzzy {
-		fmt.Fprintf(os.Stderr, "Searching for venues matching \"%s\" (substring)...\n", searchQuery)
 		query := strings.ToLower(searchQuery)
 		var filtered []jdw.Venue
 		for _, v := range venues {
@@ -327,8 +584,6 @@ func searchVenues(venues []jdw.Venue, searchQuery string, noFuzzy bool) []jdw.Ve
 		return filtered
 	}
 
-	fmt.Fprintf(os.Stderr, "Searching for venues matching \"%s\" (fuzzy)...\n", searchQuery)
-
 	type searchResult struct {
 		venue jdw.Venue
 		rank  int
@@ -368,10 +623,12 @@ func searchVenues(venues []jdw.Venue, searchQuery string, noFuzzy bool) []jdw.Ve
 	return filtered
 }
 
-func filterVenueForItems(venue map[string]interface{}, query string) bool {
-	query = strings.ToLower(query)
-	queryWords := strings.Fields(query)
-	if len(queryWords) == 0 {
+// filterVenueForItems prunes an expanded venue's menus to the items matching
+// query, reporting whether any matched. For a fuzzy query, each matching
+// node is given a searchScore and the menus and the lists within them are
+// ordered by their best score. A nil query matches everything.
+func filterVenueForItems(venue map[string]interface{}, query *itemQuery) bool {
+	if query == nil {
 		return true
 	}
 
@@ -380,7 +637,10 @@ func filterVenueForItems(venue map[string]interface{}, query string) bool {
 		return false
 	}
 
-	var filteredMenus []interface{}
+	var (
+		filteredMenus []interface{}
+		scores        []float64
+	)
 	for _, m := range menus {
 		menuMap, ok := m.(map[string]interface{})
 		if !ok {
@@ -391,96 +651,92 @@ func filterVenueForItems(venue map[string]interface{}, query string) bool {
 			continue
 		}
 
-		if match, pruned := searchAndPruneItems(details, queryWords); match {
+		root := menuNode{raw: details, menu: stringField(menuMap, "name"), scope: stringValues(menuMap)}
+		if score, pruned := searchAndPruneItems(root, query); score > 0 {
 			menuMap["details"] = pruned
 			filteredMenus = append(filteredMenus, menuMap)
+			scores = append(scores, score)
 		}
 	}
 
 	if len(filteredMenus) > 0 {
+		if query.fuzzy {
+			sortByScore(filteredMenus, scores)
+		}
 		venue["menus"] = filteredMenus
 		return true
 	}
 	return false
 }
 
-func searchAndPruneItems(node interface{}, queryWords []string) (bool, interface{}) {
-	switch v := node.(type) {
-	case map[string]interface{}:
-		// Check if this node matches the query itself
-		combinedText := ""
-		for _, val := range v {
-			if s, ok := val.(string); ok {
-				combinedText += " " + strings.ToLower(s)
-			}
+// searchAndPruneItems scores a menu node against query, returning 0 if
+// neither it nor anything under its structural keys matches. A node that
+// matches is returned whole; otherwise it is returned with only its
+// matching children and scores as its best child.
+func searchAndPruneItems(n menuNode, query *itemQuery) (float64, map[string]interface{}) {
+	if score := query.score(n); score > 0 {
+		if query.fuzzy {
+			n.raw["searchScore"] = roundScore(score)
 		}
+		return score, n.raw
+	}
 
-		allWordsMatch := true
-		for _, word := range queryWords {
-			if !strings.Contains(combinedText, word) {
-				allWordsMatch = false
-				break
-			}
+	newMap := make(map[string]interface{})
+	best := 0.0
+	for k, val := range n.raw {
+		if !structuralKeys[k] {
+			// Keep metadata
+			newMap[k] = val
+			continue
 		}
-
-		if allWordsMatch {
-			// If the node itself matches, return it fully (including children)
-			return true, v
-		}
-
-		// If node doesn't match directly, check structural children
-		newMap := make(map[string]interface{})
-		anyChildMatch := false
-
-		// Define keys that we want to traverse into
-		structuralKeys := map[string]bool{
-			"items":      true,
-			"products":   true,
-			"sections":   true,
-			"categories": true,
-			"groups":     true,
-			"itemGroups": true,
-			"options":    true,
-			"portion":    true,
-			"choices":    true,
-			"addOns":     true,
-			"linked":     true,
-		}
-
-		for k, val := range v {
-			if structuralKeys[k] {
-				match, pruned := searchAndPruneItems(val, queryWords)
-				if match {
-					anyChildMatch = true
-					newMap[k] = pruned
+		switch v := val.(type) {
+		case map[string]interface{}:
+			if score, pruned := searchAndPruneItems(n.child(k, v), query); score > 0 {
+				best = max(best, score)
+				newMap[k] = pruned
+			}
+		case []interface{}:
+			var (
+				newSlice []interface{}
+				scores   []float64
+			)
+			for _, e := range v {
+				child, ok := e.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if score, pruned := searchAndPruneItems(n.child(k, child), query); score > 0 {
+					newSlice = append(newSlice, pruned)
+					scores = append(scores, score)
+					best = max(best, score)
 				}
-			} else {
-				// Keep metadata
-				newMap[k] = val
 			}
-		}
-
-		if anyChildMatch {
-			return true, newMap
-		}
-		return false, nil
-
-	case []interface{}:
-		var newSlice []interface{}
-		anyMatch := false
-		for _, item := range v {
-			match, pruned := searchAndPruneItems(item, queryWords)
-			if match {
-				anyMatch = true
-				newSlice = append(newSlice, pruned)
+			if len(newSlice) > 0 {
+				if query.fuzzy {
+					sortByScore(newSlice, scores)
+				}
+				newMap[k] = newSlice
 			}
 		}
-		if anyMatch {
-			return true, newSlice
-		}
-		return false, nil
+	}
 
-	default:
-		return false, nil
+	if best > 0 {
+		return best, newMap
+	}
+	return 0, nil
+}
+
+// sortByScore orders list by the corresponding scores, highest first,
+// keeping the menu's order for equal scores.
+func sortByScore(list []interface{}, scores []float64) {
+	order := make([]int, len(list))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+	sorted := make([]interface{}, len(list))
+	for i, k := range order {
+		sorted[i] = list[k]
 	}
+	copy(list, sorted)
 }
@@ -3,15 +3,30 @@ package main
 import (
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/KRoperUK/get_spoons/jdw"
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
 )
 
+func TestMain(m *testing.M) {
+	// Keep the developer's own config file out of the tests.
+	dir, err := os.MkdirTemp("", "get_spoons-config")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestGetEnv(t *testing.T) {
 	os.Setenv("TEST_ENV_VAR", "test-value")
 	defer os.Unsetenv("TEST_ENV_VAR")
@@ -116,7 +131,7 @@ func TestExpandVenues(t *testing.T) {
 	client.SetBaseURL(server.URL)
 
 	venues := []jdw.Venue{{VenueRef: 123}}
-	res := expandVenues(client, venues, 1, false, false)
+	res, _ := expandVenues(client, venues, expandOptions{Concurrency: 1})
 
 	if len(res) != 1 {
 		t.Errorf("Expected 1 result, got %d", len(res))
@@ -143,7 +158,7 @@ func TestExpandVenues(t *testing.T) {
 
 		client.SetBaseURL(server.URL)
 		venues := []jdw.Venue{{VenueRef: 123}}
-		res := expandVenues(client, venues, 1, true, true)
+		res, _ := expandVenues(client, venues, expandOptions{Concurrency: 1, IncludeMenus: true, IncludeItems: true})
 
 		if len(res) != 1 {
 			t.Fatalf("Expected 1 result, got %d", len(res))
@@ -156,6 +171,100 @@ func TestExpandVenues(t *testing.T) {
 	})
 }
 
+func TestExpandVenuesPreservesOrder(t *testing.T) {
+	// Respond with a random delay so goroutines finish out of order.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		ref := strings.TrimPrefix(r.URL.Path, "/api/v0.1/jdw/venues/")
+		fmt.Fprintf(w, `{"success": true, "data": {"venueRef": %s}}`, ref)
+	}))
+	defer server.Close()
+
+	client := jdw.NewClient("v", "t", "u")
+	client.SetBaseURL(server.URL)
+
+	var venues []jdw.Venue
+	for i := 100; i > 0; i-- {
+		venues = append(venues, jdw.Venue{ID: i, VenueRef: i})
+	}
+
+	for run := 0; run < 3; run++ {
+		res, _ := expandVenues(client, venues, expandOptions{Concurrency: 32})
+		if len(res) != len(venues) {
+			t.Fatalf("Expected %d results, got %d", len(venues), len(res))
+		}
+		for i, details := range res {
+			if ref, ok := details["venueRef"].(float64); !ok || int(ref) != venues[i].VenueRef {
+				t.Fatalf("Run %d: position %d expected venueRef %d, got %v", run, i, venues[i].VenueRef, details["venueRef"])
+			}
+		}
+	}
+
+	t.Run("SkipsFailures", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/2") {
+				http.Error(w, "boom", http.StatusInternalServerError)
+				return
+			}
+			ref := strings.TrimPrefix(r.URL.Path, "/api/v0.1/jdw/venues/")
+			fmt.Fprintf(w, `{"success": true, "data": {"venueRef": %s}}`, ref)
+		}))
+		defer server.Close()
+
+		client.SetBaseURL(server.URL)
+		res, failures := expandVenues(client, []jdw.Venue{{VenueRef: 1}, {VenueRef: 2}, {VenueRef: 3}}, expandOptions{Concurrency: 8})
+		if len(res) != 2 {
+			t.Fatalf("Expected 2 results, got %d", len(res))
+		}
+		if res[0]["venueRef"].(float64) != 1 || res[1]["venueRef"].(float64) != 3 {
+			t.Errorf("Expected venueRefs 1 and 3 in order, got %v", res)
+		}
+		if len(failures) != 1 || failures[0].VenueRef != 2 {
+			t.Errorf("Expected a single failure for venueRef 2, got %v", failures)
+		}
+	})
+}
+
+func TestSortVenues(t *testing.T) {
+	newVenues := func() []jdw.Venue {
+		return []jdw.Venue{
+			{ID: 3, Name: "The Moon", Address: jdw.Address{Postcode: "E1 6AN"}},
+			{ID: 1, Name: "The Sun", Address: jdw.Address{Postcode: "M1 1AE"}},
+			{ID: 2, Name: "The Moon", Address: jdw.Address{Postcode: "B1 1AA"}},
+		}
+	}
+
+	tests := []struct {
+		key  string
+		want []int
+	}{
+		{"id", []int{1, 2, 3}},
+		{"name", []int{2, 3, 1}},
+		{"postcode", []int{2, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			venues := newVenues()
+			sortVenues(venues, tt.key)
+			for i, id := range tt.want {
+				if venues[i].ID != id {
+					t.Errorf("Position %d: expected ID %d, got %d", i, id, venues[i].ID)
+				}
+			}
+		})
+	}
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		if err := validateSortKey("rating"); err == nil {
+			t.Error("Expected error for invalid sort key")
+		}
+		if err := validateSortKey(""); err != nil {
+			t.Errorf("Expected empty sort key to be valid, got %v", err)
+		}
+	})
+}
+
 func TestWriteFormattedOutput(t *testing.T) {
 	venues := []jdw.Venue{{Name: "Test"}}
 
@@ -248,6 +357,39 @@ func TestRun(t *testing.T) {
 		}
 	})
 
+	t.Run("SortFlag", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"success": true, "data": [{"id": 2, "name": "B"}, {"id": 1, "name": "C"}, {"id": 3, "name": "A"}]}`)
+		}))
+		defer server.Close()
+
+		os.Setenv("JDW_API_URL", server.URL)
+		defer os.Unsetenv("JDW_API_URL")
+
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		err := Run([]string{"-sort", "name"})
+
+		w.Close()
+		os.Stdout = oldStdout
+
+		if err != nil {
+			t.Fatalf("Run -sort failed: %v", err)
+		}
+
+		out, _ := io.ReadAll(r)
+		a, b, c := strings.Index(string(out), `"A"`), strings.Index(string(out), `"B"`), strings.Index(string(out), `"C"`)
+		if a < 0 || a > b || b > c {
+			t.Errorf("Expected venues sorted by name, got %s", string(out))
+		}
+
+		if err := Run([]string{"-sort", "rating"}); err == nil {
+			t.Error("Expected error for invalid sort key")
+		}
+	})
+
 	t.Run("VenueFlag", func(t *testing.T) {
 		os.Setenv("JDW_API_URL", server.URL)
 		defer os.Unsetenv("JDW_API_URL")
@@ -341,7 +483,7 @@ func TestRun(t *testing.T) {
 		}
 
 		// Check stderr for filtering message
-		if !strings.Contains(errStr, "Filtered results for items matching \"burger\"") {
+		if !strings.Contains(errStr, `msg="Filtered results for matching items" query=burger`) {
 			t.Errorf("Expected stderr to confirm filtering, got: %s", errStr)
 		}
 	})
@@ -400,7 +542,7 @@ func TestRun(t *testing.T) {
 		errStr := string(errBytes)
 
 		// Check for the warning message
-		if !strings.Contains(errStr, "Item search is only allowed on an individual venue. Using the first match") {
+		if !strings.Contains(errStr, "Item search is only allowed on an individual venue; using the first match") || !strings.Contains(errStr, `venue="Pub One"`) {
 			t.Errorf("Expected multiple venue warning, got: %s", errStr)
 		}
 	})
@@ -450,12 +592,43 @@ func TestRun(t *testing.T) {
 		errBytes, _ := io.ReadAll(rErr)
 		errStr := string(errBytes)
 
-		if !strings.Contains(errStr, "No items matching \"steak\" found") {
+		if !strings.Contains(errStr, `msg="No matching items found" query=steak`) {
 			t.Errorf("Expected no items found message, got: %s", errStr)
 		}
 	})
 }
 
+func TestRunAgainstFakeServer(t *testing.T) {
+	srv := jdwtest.NewServer(jdwtest.Options{Venues: 12})
+	defer srv.Close()
+
+	os.Setenv("JDW_API_URL", srv.URL)
+	defer os.Unsetenv("JDW_API_URL")
+	os.Setenv("JDW_TOKEN", srv.Token())
+	defer os.Unsetenv("JDW_TOKEN")
+
+	output := t.TempDir() + "/venues.json"
+	if err := Run([]string{"-items", "-concurrency", "8", "-output", output}); err != nil {
+		t.Fatalf("Run -items failed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Reading output failed: %v", err)
+	}
+	if !strings.Contains(string(data), "Stella Artois") {
+		t.Error("Expected menu items in expanded output")
+	}
+	if got := srv.Requests(jdw.EndpointVenueDetails); got != 12 {
+		t.Errorf("Expected 12 venue detail requests, got %d", got)
+	}
+
+	srv.FailEndpoint(jdw.EndpointMenus, http.StatusInternalServerError)
+	err = Run([]string{"-menus", "-limit", "3", "-output", output})
+	if exitCode(err) != exitPartial {
+		t.Errorf("Expected partial exit code with failing menus endpoint, got %v", err)
+	}
+}
+
 func TestFilterVenueForItems(t *testing.T) {
 	getVenue := func() map[string]interface{} {
 		return map[string]interface{}{
@@ -481,7 +654,7 @@ func TestFilterVenueForItems(t *testing.T) {
 
 	t.Run("MatchItem", func(t *testing.T) {
 		v := getVenue()
-		matched := filterVenueForItems(v, "stella pint")
+		matched := filterVenueForItems(v, mustParseItemQuery(t, "stella pint"))
 		if !matched {
 			t.Fatalf("Expected match for stella pint")
 		}
@@ -505,7 +678,7 @@ func TestFilterVenueForItems(t *testing.T) {
 
 	t.Run("NoMatch", func(t *testing.T) {
 		v := getVenue()
-		matched := filterVenueForItems(v, "guinness")
+		matched := filterVenueForItems(v, mustParseItemQuery(t, "guinness"))
 		if matched {
 			t.Errorf("Expected no match for guinness")
 		}
@@ -513,7 +686,7 @@ func TestFilterVenueForItems(t *testing.T) {
 
 	t.Run("EmptyQuery", func(t *testing.T) {
 		v := getVenue()
-		matched := filterVenueForItems(v, "")
+		matched := filterVenueForItems(v, mustParseItemQuery(t, ""))
 		if !matched {
 			t.Errorf("Expected match (no-op) for empty query")
 		}
@@ -539,7 +712,7 @@ func TestFilterVenueForItems(t *testing.T) {
 			},
 		}
 
-		matched := filterVenueForItems(v, "burger")
+		matched := filterVenueForItems(v, mustParseItemQuery(t, "burger"))
 		if !matched {
 			t.Fatalf("Expected match for burger")
 		}
@@ -574,7 +747,7 @@ func TestFilterVenueForItems(t *testing.T) {
 			},
 		}
 
-		matched := filterVenueForItems(v, "cheese")
+		matched := filterVenueForItems(v, mustParseItemQuery(t, "cheese"))
 		if !matched {
 			t.Fatalf("Expected match for cheese")
 		}
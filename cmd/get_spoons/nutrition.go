@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Reports of the nutrition command.
+const (
+	nutritionValue  = "value"
+	nutritionLowest = "lowest"
+	nutritionStats  = "stats"
+	nutritionAll    = "all"
+)
+
+// nutritionItem is an item with known calories, combined across the venues
+// and menus it appears on.
+type nutritionItem struct {
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+	Calories int    `json:"calories"`
+	// Price is the item's lowest portion price, the "from" price the app
+	// shows, as calories are given per item rather than per portion. Across
+	// venues it is the median of their prices.
+	Price            float64 `json:"price"`
+	CaloriesPerPound float64 `json:"caloriesPerPound"`
+	// Venues is the number of venues the item was found at.
+	Venues int `json:"venues"`
+}
+
+// calorieStats summarises the calories of the items in a category.
+type calorieStats struct {
+	Category string  `json:"category"`
+	Items    int     `json:"items"`
+	Min      int     `json:"min"`
+	P25      float64 `json:"p25"`
+	Median   float64 `json:"median"`
+	Mean     float64 `json:"mean"`
+	P75      float64 `json:"p75"`
+	Max      int     `json:"max"`
+	// MedianPerPound is the median calories per pound of the items with a
+	// price.
+	MedianPerPound float64 `json:"medianPerPound"`
+}
+
+// nutritionReport holds the reports selected with -report.
+type nutritionReport struct {
+	Value  []nutritionItem `json:"value,omitempty"`
+	Lowest []nutritionItem `json:"lowest,omitempty"`
+	Stats  []calorieStats  `json:"stats,omitempty"`
+}
+
+// nutritionItems combines items with calories by category and name, the
+// same item at different venues or on different menus becoming one. Items
+// are sorted by category and name.
+func nutritionItems(items []menuItem) []nutritionItem {
+	type key struct{ category, name string }
+	type entry struct {
+		item   nutritionItem
+		prices []float64
+		venues map[int]bool
+	}
+	entries := make(map[key]*entry)
+	var keys []key
+	for _, it := range items {
+		if it.Calories == nil {
+			continue
+		}
+		k := key{it.Category, strings.ToLower(it.Name)}
+		e, ok := entries[k]
+		if !ok {
+			e = &entry{item: nutritionItem{Name: it.Name, Category: it.Category, Calories: *it.Calories}, venues: make(map[int]bool)}
+			entries[k] = e
+			keys = append(keys, k)
+		}
+		if e.venues[it.VenueID] {
+			continue
+		}
+		e.venues[it.VenueID] = true
+		if price, ok := lowestPrice(it.Portions); ok {
+			e.prices = append(e.prices, price)
+		}
+	}
+
+	out := make([]nutritionItem, 0, len(keys))
+	for _, k := range keys {
+		e := entries[k]
+		e.item.Venues = len(e.venues)
+		if len(e.prices) > 0 {
+			e.item.Price = median(e.prices)
+			e.item.CaloriesPerPound = math.Round(float64(e.item.Calories)/e.item.Price*10) / 10
+		}
+		out = append(out, e.item)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Category != out[j].Category {
+			return out[i].Category < out[j].Category
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// lowestPrice returns the lowest non-zero portion price.
+func lowestPrice(portions []portionPrice) (float64, bool) {
+	lowest := 0.0
+	for _, p := range portions {
+		if p.Price > 0 && (lowest == 0 || p.Price < lowest) {
+			lowest = p.Price
+		}
+	}
+	return lowest, lowest > 0
+}
+
+// caloriesPerPound returns the priced items ordered by calories per pound,
+// most first, up to top (0 for all).
+func caloriesPerPound(items []nutritionItem, top int) []nutritionItem {
+	var out []nutritionItem
+	for _, it := range items {
+		if it.Price > 0 {
+			out = append(out, it)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CaloriesPerPound > out[j].CaloriesPerPound })
+	if top > 0 && top < len(out) {
+		out = out[:top]
+	}
+	return out
+}
+
+// lowestCalories returns the top (0 for all) lowest-calorie items of each
+// category, by category.
+func lowestCalories(items []nutritionItem, top int) []nutritionItem {
+	var out []nutritionItem
+	for _, group := range byCategory(items) {
+		sort.SliceStable(group, func(i, j int) bool { return group[i].Calories < group[j].Calories })
+		if top > 0 && top < len(group) {
+			group = group[:top]
+		}
+		out = append(out, group...)
+	}
+	return out
+}
+
+// calorieDistribution returns the calorie statistics of each category,
+// followed by those of all items together.
+func calorieDistribution(items []nutritionItem) []calorieStats {
+	var out []calorieStats
+	for _, group := range byCategory(items) {
+		out = append(out, newCalorieStats(group[0].Category, group))
+	}
+	if len(items) > 0 {
+		out = append(out, newCalorieStats("All", items))
+	}
+	return out
+}
+
+// byCategory splits items, sorted by category, into a copy of each
+// category's items.
+func byCategory(items []nutritionItem) [][]nutritionItem {
+	var groups [][]nutritionItem
+	for i, it := range items {
+		if i == 0 || it.Category != items[i-1].Category {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], it)
+	}
+	return groups
+}
+
+func newCalorieStats(category string, items []nutritionItem) calorieStats {
+	calories := make([]float64, len(items))
+	var perPound []float64
+	sum := 0.0
+	for i, it := range items {
+		calories[i] = float64(it.Calories)
+		sum += calories[i]
+		if it.Price > 0 {
+			perPound = append(perPound, it.CaloriesPerPound)
+		}
+	}
+	sort.Float64s(calories)
+	s := calorieStats{
+		Category: category,
+		Items:    len(items),
+		Min:      int(calories[0]),
+		P25:      percentile(calories, 25),
+		Median:   percentile(calories, 50),
+		Mean:     math.Round(sum/float64(len(items))*10) / 10,
+		P75:      percentile(calories, 75),
+		Max:      int(calories[len(calories)-1]),
+	}
+	if len(perPound) > 0 {
+		s.MedianPerPound = median(perPound)
+	}
+	return s
+}
+
+// percentile returns the p-th percentile of sorted, which must not be
+// empty, interpolating between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(rank)
+	if lo+1 >= len(sorted) {
+		return sorted[lo]
+	}
+	return sorted[lo] + (rank-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+// runNutrition implements the "nutrition" subcommand: it fetches the menus
+// of the selected venues and reports calories per pound, the lowest-calorie
+// options in each category and the distribution of calories.
+func runNutrition(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("get_spoons nutrition", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	near := fs.String("near", "", "Only include venues near this location, as 'lat,lng'")
+	radius := fs.Float64("radius", 5, "Radius in km for -near")
+	venueIDs := fs.String("venues", "", "Only include these venue IDs (comma-separated)")
+	limit := fs.Int("limit", 0, "Limit number of venues (0 for all)")
+	report := fs.String("report", nutritionAll, "Report to produce: value (calories per £), lowest (lowest-calorie options per category), stats (calorie distribution per category) or all")
+	top := fs.Int("top", 5, "Number of items in the value report, and per category in the lowest report (0 for all)")
+	format := fs.String("format", "text", "Output format: text, json or csv (csv needs a single -report)")
+	output := fs.String("output", "", "Output file path (default: stdout)")
+	concurrency := fs.Int("concurrency", 4, "Number of concurrent requests")
+	retries := fs.Int("retries", 1, "Number of times to retry failed detail, menu and item requests")
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+	switch *report {
+	case nutritionValue, nutritionLowest, nutritionStats, nutritionAll:
+	default:
+		return fmt.Errorf("invalid -report %q: must be value, lowest, stats or all", *report)
+	}
+	switch *format {
+	case "text", "json":
+	case "csv":
+		if *report == nutritionAll {
+			return errors.New("-format csv needs -report value, lowest or stats")
+		}
+	default:
+		return fmt.Errorf("invalid -format %q: must be text, json or csv", *format)
+	}
+	filter, err := parseWatchFilter(*near, *radius, *venueIDs)
+	if err != nil {
+		return err
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+	venues, byVenue, partial, err := fetchVenueItems(client, filter, *limit, expandOptions{Concurrency: *concurrency, Retries: *retries})
+	if err != nil {
+		return err
+	}
+	var all []menuItem
+	for _, v := range venues {
+		all = append(all, byVenue[v.ID]...)
+	}
+	items := nutritionItems(all)
+	slog.Info("Analysed nutrition", "venues", len(venues), "items", len(items))
+
+	var r nutritionReport
+	if *report == nutritionValue || *report == nutritionAll {
+		r.Value = caloriesPerPound(items, *top)
+	}
+	if *report == nutritionLowest || *report == nutritionAll {
+		r.Lowest = lowestCalories(items, *top)
+	}
+	if *report == nutritionStats || *report == nutritionAll {
+		r.Stats = calorieDistribution(items)
+	}
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	case "csv":
+		err = writeNutritionCSV(w, r, *report)
+	default:
+		err = writeNutritionText(w, r, *report)
+	}
+	if err != nil {
+		return err
+	}
+	return partial
+}
+
+func writeNutritionText(w io.Writer, r nutritionReport, report string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	// With every report, each table gets a title and a blank line between.
+	sections := 0
+	section := func(name, title string) bool {
+		if report != name && report != nutritionAll {
+			return false
+		}
+		if report == nutritionAll {
+			if sections > 0 {
+				fmt.Fprintln(tw)
+			}
+			fmt.Fprintf(tw, "%s\n\n", title)
+		}
+		sections++
+		return true
+	}
+	if section(nutritionValue, "Calories per £") {
+		fmt.Fprintln(tw, "ITEM\tCATEGORY\tKCAL\tPRICE\tKCAL/£")
+		for _, it := range r.Value {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%.1f\n", it.Name, it.Category, it.Calories, formatPrice(it.Price), it.CaloriesPerPound)
+		}
+	}
+	if section(nutritionLowest, "Lowest-calorie options by category") {
+		fmt.Fprintln(tw, "CATEGORY\tITEM\tKCAL\tPRICE")
+		for _, it := range r.Lowest {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", it.Category, it.Name, it.Calories, formatPrice(it.Price))
+		}
+	}
+	if section(nutritionStats, "Calorie distribution by category") {
+		fmt.Fprintln(tw, "CATEGORY\tITEMS\tMIN\tP25\tMEDIAN\tMEAN\tP75\tMAX\tKCAL/£")
+		for _, s := range r.Stats {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.0f\t%.0f\t%.0f\t%.0f\t%d\t%.1f\n", s.Category, s.Items, s.Min, s.P25, s.Median, s.Mean, s.P75, s.Max, s.MedianPerPound)
+		}
+	}
+	return tw.Flush()
+}
+
+// formatPrice formats a price for a table, or "-" if it is unknown.
+func formatPrice(p float64) string {
+	if p == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("£%.2f", p)
+}
+
+// writeNutritionCSV writes report, one of value, lowest or stats, from r.
+// A report without rows still gets its header.
+func writeNutritionCSV(w io.Writer, r nutritionReport, report string) error {
+	cw := csv.NewWriter(w)
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	switch report {
+	case nutritionStats:
+		cw.Write([]string{"category", "items", "min", "p25", "median", "mean", "p75", "max", "medianPerPound"})
+		for _, s := range r.Stats {
+			cw.Write([]string{s.Category, strconv.Itoa(s.Items), strconv.Itoa(s.Min), f(s.P25), f(s.Median), f(s.Mean), f(s.P75), strconv.Itoa(s.Max), f(s.MedianPerPound)})
+		}
+	default:
+		cw.Write([]string{"name", "category", "calories", "price", "caloriesPerPound", "venues"})
+		items := r.Value
+		if report == nutritionLowest {
+			items = r.Lowest
+		}
+		for _, it := range items {
+			cw.Write([]string{it.Name, it.Category, strconv.Itoa(it.Calories), f(it.Price), f(it.CaloriesPerPound), strconv.Itoa(it.Venues)})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
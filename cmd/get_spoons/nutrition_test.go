@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+)
+
+func TestNutritionItems(t *testing.T) {
+	cal := func(n int) *int { return &n }
+	items := nutritionItems([]menuItem{
+		{VenueID: 1, Name: "Stella", Category: "Beer", Calories: cal(227), Portions: []portionPrice{{"Half", 2.5}, {"Pint", 4.5}}},
+		{VenueID: 2, Name: "Stella", Category: "Beer", Calories: cal(227), Portions: []portionPrice{{"Half", 2}, {"Pint", 4}}},
+		{VenueID: 3, Name: "Stella", Category: "Beer", Calories: cal(227), Portions: []portionPrice{{"Half", 3}}},
+		// The same item on a second menu at a venue counts once.
+		{VenueID: 3, Name: "stella", Category: "Beer", Calories: cal(227), Portions: []portionPrice{{"Half", 1}}},
+		{VenueID: 1, Name: "Carling", Category: "Beer", Calories: cal(189), Portions: []portionPrice{{"Half", 1.89}}},
+		{VenueID: 1, Name: "Diet Coke", Category: "Soft Drinks", Calories: cal(1), Portions: []portionPrice{{"Regular", 2}}},
+		{VenueID: 1, Name: "Water", Category: "Soft Drinks", Calories: cal(0)},
+		{VenueID: 1, Name: "Mystery Pie", Category: "Mains", Portions: []portionPrice{{"", 9}}},
+	})
+
+	if len(items) != 4 {
+		t.Fatalf("Expected 4 items with calories, got %+v", items)
+	}
+	stella := items[1]
+	if stella.Name != "Stella" || stella.Venues != 3 || stella.Price != 2.5 || stella.CaloriesPerPound != 90.8 {
+		t.Errorf("Expected Stella at 3 venues for a median £2.50, got %+v", stella)
+	}
+
+	value := caloriesPerPound(items, 1)
+	if len(value) != 1 || value[0].Name != "Carling" {
+		t.Errorf("Expected Carling to give the most calories per pound, got %+v", value)
+	}
+	var lowest []string
+	for _, it := range lowestCalories(items, 1) {
+		lowest = append(lowest, it.Name)
+	}
+	if got := strings.Join(lowest, ","); got != "Carling,Water" {
+		t.Errorf("Expected Carling,Water, got %s", got)
+	}
+
+	stats := calorieDistribution(items)
+	if len(stats) != 3 || stats[2].Category != "All" {
+		t.Fatalf("Expected stats for 2 categories and all items, got %+v", stats)
+	}
+	all := stats[2]
+	if all.Items != 4 || all.Min != 0 || all.Max != 227 || all.Median != 95 || all.P25 != 0.75 || all.Mean != 104.3 {
+		t.Errorf("Unexpected distribution %+v", all)
+	}
+	// Water has no price, so only Diet Coke counts towards calories per pound.
+	if soft := stats[1]; soft.MedianPerPound != 0.5 {
+		t.Errorf("Expected a median of 0.5 kcal/£ for soft drinks, got %+v", soft)
+	}
+}
+
+func TestNutrition(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 3})
+	defer fake.Close()
+	first := fake.Dataset.Venues[0]
+	client := []string{"-api-url", fake.URL, "-token", fake.Token()}
+
+	var out strings.Builder
+	if err := runNutrition(append(client, "-venues", strconv.Itoa(first.ID), "-format", "json"), &out); err != nil {
+		t.Fatalf("nutrition failed: %v", err)
+	}
+	var r nutritionReport
+	if err := json.Unmarshal([]byte(out.String()), &r); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(r.Value) != 5 || r.Value[0].Name != "Traditional Breakfast" {
+		t.Errorf("Expected the top 5 by calories per pound, led by Traditional Breakfast, got %+v", r.Value)
+	}
+	if len(r.Lowest) == 0 || r.Lowest[0].Category != "Beer" || r.Lowest[0].Name != "Ruddles Best" {
+		t.Errorf("Expected Ruddles Best to be the lowest-calorie beer, got %+v", r.Lowest)
+	}
+	if len(r.Stats) != 4 || r.Stats[3].Items != 13 {
+		t.Errorf("Expected stats for 3 categories and all 13 items, got %+v", r.Stats)
+	}
+
+	out.Reset()
+	if err := runNutrition(append(client, "-report", "stats", "-format", "csv"), &out); err != nil {
+		t.Fatalf("nutrition -format csv failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 5 || !strings.HasPrefix(lines[0], "category,items,min") {
+		t.Errorf("Expected a CSV header and 4 rows, got:\n%s", out.String())
+	}
+	// Without any calorie data, the stats report still has its own header.
+	out.Reset()
+	if err := writeNutritionCSV(&out, nutritionReport{}, nutritionStats); err != nil || out.String() != "category,items,min,p25,median,mean,p75,max,medianPerPound\n" {
+		t.Errorf("Expected only the stats header, got %v:\n%s", err, out.String())
+	}
+
+	out.Reset()
+	if err := runNutrition(append(client, "-limit", "1", "-top", "1"), &out); err != nil {
+		t.Fatalf("nutrition failed: %v", err)
+	}
+	for _, want := range []string{"Calories per £", "Lowest-calorie options by category", "Calorie distribution by category", "KCAL/£"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, out.String())
+		}
+	}
+
+	if err := runNutrition(append(client, "-format", "csv"), &out); err == nil {
+		t.Error("Expected -format csv without a single -report to fail")
+	}
+	if err := runNutrition(append(client, "-report", "fibre"), &out); err == nil {
+		t.Error("Expected an unknown -report to fail")
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// schemaReport is the result of a schema check.
+type schemaReport struct {
+	// Responses is the number of response bodies validated per endpoint
+	// name (see the jdw.Endpoint* constants).
+	Responses map[string]int    `json:"responses"`
+	Issues    []jdw.SchemaIssue `json:"issues"`
+}
+
+// schemaCollector merges the schema issues of every request made by a client.
+type schemaCollector struct {
+	mu     sync.Mutex
+	report schemaReport
+	index  map[jdw.SchemaIssue]int
+}
+
+func newSchemaCollector() *schemaCollector {
+	return &schemaCollector{
+		report: schemaReport{Responses: make(map[string]int), Issues: []jdw.SchemaIssue{}},
+		index:  make(map[jdw.SchemaIssue]int),
+	}
+}
+
+// observe is a jdw.RequestObserver. Responses that failed to decode are
+// still counted, since drift is a common cause.
+func (c *schemaCollector) observe(info jdw.RequestInfo) {
+	if info.StatusCode != http.StatusOK {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.report.Responses[info.Endpoint]++
+	for _, issue := range info.SchemaIssues {
+		key := issue
+		key.Count = 0
+		if i, ok := c.index[key]; ok {
+			c.report.Issues[i].Count += issue.Count
+			continue
+		}
+		c.index[key] = len(c.report.Issues)
+		c.report.Issues = append(c.report.Issues, issue)
+	}
+}
+
+// runSchemaCheck implements the "schema-check" subcommand.
+func runSchemaCheck(args []string) error {
+	return runSchemaCheckTo(args, os.Stdout)
+}
+
+// runSchemaCheckTo samples every typed endpoint, validates the responses
+// against the bundled OpenAPI spec and writes a drift report to w.
+func runSchemaCheckTo(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("get_spoons schema-check", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	sample := fs.Int("venues", 3, "Number of venues to sample, spread evenly across the estate")
+	items := fs.Bool("items", false, "Also fetch every menu of the sampled venues")
+	format := fs.String("format", "text", "Report format: text or json")
+	failOnDrift := fs.Bool("fail-on-drift", false, "Exit with a failure status when any drift is found")
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("invalid -format %q: must be text or json", *format)
+	}
+	if cf.offline {
+		return errors.New("schema-check validates live responses and cannot be used with -offline")
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+	// Responses must come from the network to be validated.
+	client.SetCache(nil, jdw.CacheOptions{})
+	client.SetSchemaValidator(jdw.DefaultSchemaValidator())
+	collector := newSchemaCollector()
+	client.SetRequestObserver(collector.observe)
+
+	if _, err := client.GetSettings(); err != nil {
+		slog.Warn("Fetching settings failed", "error", err)
+	}
+	if _, err := client.GetBanners(); err != nil {
+		slog.Warn("Fetching banners failed", "error", err)
+	}
+	// A venues response that no longer decodes is still reported, since
+	// drift is the likely cause.
+	venues, venuesErr := client.GetVenues()
+	for _, v := range sampleVenues(venues, *sample) {
+		checkVenueSchema(client, v, *items)
+	}
+
+	report := collector.report
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if err := writeSchemaReport(w, report); err != nil {
+		return err
+	}
+
+	if venuesErr != nil {
+		return fmt.Errorf("fetching venues: %w", venuesErr)
+	}
+	if *failOnDrift && len(report.Issues) > 0 {
+		return fmt.Errorf("schema drift detected: %d issue(s)", len(report.Issues))
+	}
+	return nil
+}
+
+// sampleVenues picks n venues spread evenly across venues, or all of them
+// when n is not positive or exceeds len(venues).
+func sampleVenues(venues []jdw.Venue, n int) []jdw.Venue {
+	if n <= 0 || n >= len(venues) {
+		return venues
+	}
+	sample := make([]jdw.Venue, n)
+	for i := range sample {
+		sample[i] = venues[i*len(venues)/n]
+	}
+	return sample
+}
+
+// checkVenueSchema fetches a venue's details and menus so their responses are
+// validated. Errors are logged rather than returned so that one bad venue
+// doesn't end the check, and "success": false responses, which closed venues
+// return for their menus, are skipped.
+func checkVenueSchema(client *jdw.Client, v jdw.Venue, items bool) {
+	details, err := client.GetVenue(v.VenueRef)
+	if err != nil {
+		slog.Warn("Fetching venue details failed", "venue_ref", v.VenueRef, "error", err)
+		return
+	}
+	for _, area := range details.SalesAreas {
+		menus, err := client.GetSalesAreaMenus(v.VenueRef, area.ID)
+		if errors.Is(err, jdw.ErrAPIFailure) {
+			continue
+		}
+		if err != nil {
+			slog.Warn("Fetching menus failed", "venue_ref", v.VenueRef, "sales_area_id", area.ID, "error", err)
+			continue
+		}
+		if !items {
+			continue
+		}
+		for _, m := range menus {
+			if _, err := client.GetMenu(v.VenueRef, area.ID, m.ID); err != nil && !errors.Is(err, jdw.ErrAPIFailure) {
+				slog.Warn("Fetching menu items failed", "venue_ref", v.VenueRef, "menu_id", m.ID, "error", err)
+			}
+		}
+	}
+}
+
+// writeSchemaReport writes report as a table of issues.
+func writeSchemaReport(w io.Writer, report schemaReport) error {
+	endpoints := make([]string, 0, len(report.Responses))
+	total := 0
+	for name, n := range report.Responses {
+		endpoints = append(endpoints, fmt.Sprintf("%s %d", name, n))
+		total += n
+	}
+	sort.Strings(endpoints)
+	fmt.Fprintf(w, "Checked %d response(s): %s\n", total, strings.Join(endpoints, ", "))
+
+	if len(report.Issues) == 0 {
+		fmt.Fprintln(w, "No drift detected")
+		return nil
+	}
+	fmt.Fprintf(w, "%d issue(s):\n\n", len(report.Issues))
+
+	issues := append([]jdw.SchemaIssue(nil), report.Issues...)
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Endpoint != issues[j].Endpoint {
+			return issues[i].Endpoint < issues[j].Endpoint
+		}
+		return issues[i].Field < issues[j].Field
+	})
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tFIELD\tISSUE\tCOUNT")
+	for _, issue := range issues {
+		desc := strings.ReplaceAll(issue.Kind, "_", " ")
+		if issue.Kind == jdw.IssueTypeMismatch {
+			desc = fmt.Sprintf("expected %s, got %s", issue.Expected, issue.Got)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", issue.Endpoint, issue.Field, desc, issue.Count)
+	}
+	return tw.Flush()
+}
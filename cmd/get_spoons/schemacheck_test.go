@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+)
+
+func TestSchemaCheck(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 6})
+	defer fake.Close()
+
+	var out strings.Builder
+	if err := runSchemaCheckTo([]string{"-api-url", fake.URL, "-token", fake.Token(), "-items", "-fail-on-drift"}, &out); err != nil {
+		t.Fatalf("Expected no drift from the fake server, got %v:\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "No drift detected") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+	if got := fake.Requests(jdw.EndpointVenueDetails); got != 3 {
+		t.Errorf("Expected 3 sampled venues, got %d", got)
+	}
+	if fake.Requests(jdw.EndpointMenuItems) == 0 {
+		t.Error("Expected -items to fetch menu items")
+	}
+}
+
+// driftProxy serves the fake server's responses after passing the venues
+// list through edit.
+func driftProxy(t *testing.T, fake *jdwtest.Server, edit func(venue map[string]interface{})) *httptest.Server {
+	t.Helper()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequest(r.Method, fake.URL+r.URL.RequestURI(), nil)
+		req.Header = r.Header.Clone()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("proxy request failed: %v", err)
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if r.URL.Path == "/api/v0.1/venues" {
+			var doc map[string]interface{}
+			if err := json.Unmarshal(body, &doc); err != nil {
+				t.Errorf("invalid venues response: %v", err)
+			}
+			for _, v := range doc["data"].([]interface{}) {
+				edit(v.(map[string]interface{}))
+			}
+			body, _ = json.Marshal(doc)
+		}
+		w.WriteHeader(resp.StatusCode)
+		w.Write(body)
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+func TestSchemaCheckDrift(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 2})
+	defer fake.Close()
+	proxy := driftProxy(t, fake, func(venue map[string]interface{}) {
+		venue["wifi"] = true
+		delete(venue, "name")
+	})
+	args := []string{"-api-url", proxy.URL, "-token", fake.Token()}
+
+	var out strings.Builder
+	if err := runSchemaCheckTo(args, &out); err != nil {
+		t.Fatalf("Expected drift to be reported without -fail-on-drift, got %v", err)
+	}
+	for _, want := range []string{
+		"2 issue(s):",
+		"/api/v0.1/venues  data[].name  missing required  2",
+		"/api/v0.1/venues  data[].wifi  unknown field     2",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	err := runSchemaCheckTo(append(args, "-format", "json", "-fail-on-drift"), &out)
+	if err == nil || !strings.Contains(err.Error(), "schema drift detected: 2 issue(s)") {
+		t.Errorf("Expected -fail-on-drift to fail, got %v", err)
+	}
+	var report schemaReport
+	if err := json.Unmarshal([]byte(out.String()), &report); err != nil {
+		t.Fatalf("Invalid JSON report: %v\n%s", err, out.String())
+	}
+	if len(report.Issues) != 2 || report.Responses[jdw.EndpointVenues] != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestSchemaCheckTypeChange(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 2})
+	defer fake.Close()
+	proxy := driftProxy(t, fake, func(venue map[string]interface{}) {
+		venue["venueRef"] = fmt.Sprint(venue["venueRef"])
+	})
+
+	var out strings.Builder
+	err := runSchemaCheckTo([]string{"-api-url", proxy.URL, "-token", fake.Token()}, &out)
+	if err == nil || !strings.Contains(err.Error(), "fetching venues") {
+		t.Errorf("Expected the venues request to fail, got %v", err)
+	}
+	if !strings.Contains(out.String(), "data[].venueRef  expected integer, got string  2") {
+		t.Errorf("Expected the type change to be reported:\n%s", out.String())
+	}
+}
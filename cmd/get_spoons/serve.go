@@ -0,0 +1,619 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+//go:embed serve_openapi.yaml
+var serveOpenAPI []byte
+
+// runServe implements the "serve" subcommand: a local HTTP JSON API backed by
+// a periodically refreshed snapshot of the estate.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("get_spoons serve", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	refresh := fs.Duration("refresh", time.Hour, "How often to refresh the venue snapshot (0 to disable)")
+	preload := fs.Bool("preload", false, "Fetch menus and items for every venue on each refresh (enables /api/items)")
+	concurrency := fs.Int("concurrency", 4, "Number of concurrent requests when preloading")
+	retries := fs.Int("retries", 1, "Number of times to retry failed detail, menu and item requests")
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+
+	api := newAPIServer(client, expandOptions{
+		Concurrency:  *concurrency,
+		IncludeMenus: true,
+		IncludeItems: true,
+		Retries:      *retries,
+	}, *preload)
+	if err := api.refresh(); err != nil {
+		return fmt.Errorf("loading initial snapshot: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *refresh > 0 {
+		go api.refreshEvery(ctx, *refresh)
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: api.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("Serving venues", "venues", api.venueCount(), "url", "http://"+*addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// apiServer holds the in-memory snapshot served by "serve".
+type apiServer struct {
+	client  *jdw.Client
+	opts    expandOptions
+	preload bool
+
+	// refreshMu serializes refreshes, so an older crawl never replaces the
+	// snapshot of a newer one.
+	refreshMu sync.Mutex
+
+	mu        sync.RWMutex
+	venues    []jdw.Venue
+	fetchedAt time.Time
+	expanded  map[int]*expandedVenue // keyed by venue ID
+}
+
+// expandedVenue lazily holds the details, menus and items of one venue for the
+// lifetime of a snapshot.
+type expandedVenue struct {
+	// mu is held while fetching, so that concurrent requests share a fetch.
+	mu     sync.Mutex
+	result atomic.Pointer[expandResult]
+}
+
+type expandResult struct {
+	details map[string]interface{}
+	items   []menuItem
+	err     error
+}
+
+// load returns the stored result, or calls fetch and stores its result if
+// it succeeded. A failed fetch is not stored, so the next call retries it
+// rather than the venue failing until the next refresh.
+func (e *expandedVenue) load(fetch func() expandResult) *expandResult {
+	if r := e.result.Load(); r != nil {
+		return r
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if r := e.result.Load(); r != nil {
+		return r
+	}
+	r := fetch()
+	if r.err == nil {
+		e.result.Store(&r)
+	}
+	return &r
+}
+
+func newAPIServer(client *jdw.Client, opts expandOptions, preload bool) *apiServer {
+	return &apiServer{client: client, opts: opts, preload: preload}
+}
+
+// refresh replaces the snapshot with a fresh venue list. Expanded venues are
+// discarded and re-fetched on demand, or immediately when preloading. A
+// refresh waits for one already in progress to finish.
+func (s *apiServer) refresh() error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	venues, err := s.client.GetVenues()
+	if err != nil {
+		return err
+	}
+
+	expanded := make(map[int]*expandedVenue, len(venues))
+	for _, v := range venues {
+		expanded[v.ID] = &expandedVenue{}
+	}
+
+	if s.preload {
+		details, failures := expandVenues(s.client, venues, s.opts)
+		byRef := make(map[int]map[string]interface{}, len(details))
+		for _, d := range details {
+			byRef[intField(d, "venueRef")] = d
+		}
+		// Venues that failed are fetched again on demand.
+		for _, v := range venues {
+			if d, ok := byRef[v.VenueRef]; ok {
+				expanded[v.ID].load(func() expandResult {
+					return expandResult{details: d, items: extractItems(d)}
+				})
+			}
+		}
+		if len(failures) > 0 {
+			slog.Warn("Snapshot refreshed with failed requests", "failures", len(failures))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.venues = venues
+	s.fetchedAt = time.Now().UTC()
+	s.expanded = expanded
+	return nil
+}
+
+func (s *apiServer) refreshEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.refresh(); err != nil {
+				slog.Error("Refreshing snapshot failed", "error", err)
+			}
+		}
+	}
+}
+
+func (s *apiServer) snapshot() ([]jdw.Venue, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.venues, s.fetchedAt
+}
+
+func (s *apiServer) venueCount() int {
+	venues, _ := s.snapshot()
+	return len(venues)
+}
+
+// venue looks up a venue by ID, falling back to venueRef.
+func (s *apiServer) venue(id int) (jdw.Venue, bool) {
+	venues, _ := s.snapshot()
+	for _, v := range venues {
+		if v.ID == id {
+			return v, true
+		}
+	}
+	for _, v := range venues {
+		if v.VenueRef == id {
+			return v, true
+		}
+	}
+	return jdw.Venue{}, false
+}
+
+// expand returns the expanded details and items for v, fetching them on
+// first use within the current snapshot.
+func (s *apiServer) expand(v jdw.Venue) (map[string]interface{}, []menuItem, error) {
+	s.mu.RLock()
+	e, ok := s.expanded[v.ID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("venue %d is not in the snapshot", v.ID)
+	}
+
+	r := e.load(func() expandResult {
+		details, failures := expandVenue(context.Background(), s.client, v, s.opts)
+		if details == nil {
+			return expandResult{err: errors.New(failures[0].Error)}
+		}
+		return expandResult{details: details, items: extractItems(details)}
+	})
+	return r.details, r.items, r.err
+}
+
+// loadedItems returns the items of every venue expanded so far, which is
+// every venue that could be fetched when preloading.
+func (s *apiServer) loadedItems() []menuItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var items []menuItem
+	for _, v := range s.venues {
+		if e := s.expanded[v.ID]; e != nil {
+			if r := e.result.Load(); r != nil {
+				items = append(items, r.items...)
+			}
+		}
+	}
+	return items
+}
+
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status", s.handleStatus)
+	mux.HandleFunc("POST /api/refresh", s.handleRefresh)
+	mux.HandleFunc("GET /api/venues", s.handleVenues)
+	mux.HandleFunc("GET /api/venues/nearest", s.handleNearest)
+	mux.HandleFunc("GET /api/venues/{id}", s.handleVenue)
+	mux.HandleFunc("GET /api/venues/{id}/items", s.handleVenueItems)
+	mux.HandleFunc("GET /api/venues/{id}/prices", s.handleVenuePrices)
+	mux.HandleFunc("GET /api/items", s.handleItems)
+	mux.Handle("/graphql", newGraphQLHandler(s, s.opts.Concurrency))
+	mux.HandleFunc("GET /openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(serveOpenAPI)
+	})
+	return mux
+}
+
+func (s *apiServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	venues, fetchedAt := s.snapshot()
+	writeAPIJSON(w, http.StatusOK, map[string]interface{}{
+		"venues":    len(venues),
+		"fetchedAt": fetchedAt,
+		"preload":   s.preload,
+	})
+}
+
+func (s *apiServer) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if err := s.refresh(); err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	s.handleStatus(w, r)
+}
+
+func (s *apiServer) handleVenues(w http.ResponseWriter, r *http.Request) {
+	venues, _ := s.snapshot()
+	q := r.URL.Query()
+	fuzzy, ok := fuzzyParam(w, r)
+	if !ok {
+		return
+	}
+	var closed *bool
+	if c := q.Get("closed"); c != "" {
+		b, err := strconv.ParseBool(c)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid closed %q", c))
+			return
+		}
+		closed = &b
+	}
+
+	if search := q.Get("q"); search != "" {
+		venues = searchVenues(venues, search, !fuzzy)
+	} else {
+		venues = append([]jdw.Venue(nil), venues...)
+	}
+
+	var filtered []jdw.Venue
+	for _, v := range venues {
+		if matchesVenueFilters(v, q, closed) {
+			filtered = append(filtered, v)
+		}
+	}
+
+	if key := q.Get("sort"); key != "" {
+		if err := validateSortKey(key); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		sortVenues(filtered, key)
+	}
+
+	page, err := paginate(filtered, q)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, page)
+}
+
+// matchesVenueFilters applies the exact-match query filters of /api/venues,
+// with closed already parsed (nil when unset).
+func matchesVenueFilters(v jdw.Venue, q map[string][]string, closed *bool) bool {
+	get := func(key string) string {
+		if vals := q[key]; len(vals) > 0 {
+			return vals[0]
+		}
+		return ""
+	}
+	if town := get("town"); town != "" && !strings.EqualFold(v.Address.Town, town) {
+		return false
+	}
+	if county := get("county"); county != "" && !strings.EqualFold(v.Address.County, county) {
+		return false
+	}
+	if pc := get("postcode"); pc != "" && !strings.HasPrefix(strings.ToUpper(v.Address.Postcode), strings.ToUpper(pc)) {
+		return false
+	}
+	if status := get("status"); status != "" && !strings.EqualFold(v.Status, status) {
+		return false
+	}
+	if typ := get("type"); typ != "" && !strings.EqualFold(v.Type, typ) {
+		return false
+	}
+	if closed != nil && v.IsClosed != *closed {
+		return false
+	}
+	return true
+}
+
+func (s *apiServer) handleNearest(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	lat, errLat := strconv.ParseFloat(q.Get("lat"), 64)
+	lng, errLng := strconv.ParseFloat(q.Get("lng"), 64)
+	if errLat != nil || errLng != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("lat and lng are required"))
+		return
+	}
+	limit := 5
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", l))
+			return
+		}
+		limit = n
+	}
+
+	venues, _ := s.snapshot()
+	writeAPIJSON(w, http.StatusOK, nearestVenues(venues, lat, lng, limit))
+}
+
+// nearbyVenue is a venue annotated with its distance from a point.
+type nearbyVenue struct {
+	jdw.Venue
+	DistanceKm float64 `json:"distanceKm"`
+}
+
+func nearestVenues(venues []jdw.Venue, lat, lng float64, limit int) []nearbyVenue {
+	nearby := make([]nearbyVenue, 0, len(venues))
+	for _, v := range venues {
+		d := distanceKm(lat, lng, v.Address.Location.Latitude, v.Address.Location.Longitude)
+		nearby = append(nearby, nearbyVenue{Venue: v, DistanceKm: math.Round(d*1000) / 1000})
+	}
+	sort.SliceStable(nearby, func(i, j int) bool {
+		return nearby[i].DistanceKm < nearby[j].DistanceKm
+	})
+	if limit < len(nearby) {
+		nearby = nearby[:limit]
+	}
+	return nearby
+}
+
+// distanceKm returns the great-circle distance between two points.
+func distanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat, dLng := rad(lat2-lat1), rad(lng2-lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// pathVenue resolves the {id} path parameter, writing an error response if it
+// does not match a venue.
+func (s *apiServer) pathVenue(w http.ResponseWriter, r *http.Request) (jdw.Venue, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid venue id %q", r.PathValue("id")))
+		return jdw.Venue{}, false
+	}
+	v, ok := s.venue(id)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("venue %d not found", id))
+		return jdw.Venue{}, false
+	}
+	return v, true
+}
+
+func (s *apiServer) handleVenue(w http.ResponseWriter, r *http.Request) {
+	v, ok := s.pathVenue(w, r)
+	if !ok {
+		return
+	}
+	details, _, err := s.expand(v)
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, map[string]interface{}{"venue": v, "details": details})
+}
+
+func (s *apiServer) handleVenueItems(w http.ResponseWriter, r *http.Request) {
+	query, ok := itemQueryParam(w, r)
+	if !ok {
+		return
+	}
+	v, ok := s.pathVenue(w, r)
+	if !ok {
+		return
+	}
+	_, items, err := s.expand(v)
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, filterItems(items, query))
+}
+
+// priceEntry is a single row of /api/venues/{id}/prices.
+type priceEntry struct {
+	ItemID   int     `json:"itemId"`
+	Item     string  `json:"item"`
+	Category string  `json:"category,omitempty"`
+	Portion  string  `json:"portion"`
+	Price    float64 `json:"price"`
+}
+
+func (s *apiServer) handleVenuePrices(w http.ResponseWriter, r *http.Request) {
+	query, ok := itemQueryParam(w, r)
+	if !ok {
+		return
+	}
+	v, ok := s.pathVenue(w, r)
+	if !ok {
+		return
+	}
+	_, items, err := s.expand(v)
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	prices := []priceEntry{}
+	for _, it := range filterItems(items, query) {
+		for _, p := range it.Portions {
+			prices = append(prices, priceEntry{ItemID: it.ID, Item: it.Name, Category: it.Category, Portion: p.Label, Price: p.Price})
+		}
+	}
+	sort.SliceStable(prices, func(i, j int) bool { return prices[i].Price < prices[j].Price })
+	writeAPIJSON(w, http.StatusOK, prices)
+}
+
+func (s *apiServer) handleItems(w http.ResponseWriter, r *http.Request) {
+	// Without preloading, only the venues earlier requests happened to
+	// expand could be searched.
+	if !s.preload {
+		writeAPIError(w, http.StatusNotImplemented, errors.New("estate-wide item search needs serve to be started with -preload"))
+		return
+	}
+	if r.URL.Query().Get("q") == "" {
+		writeAPIError(w, http.StatusBadRequest, errors.New("q is required"))
+		return
+	}
+	query, ok := itemQueryParam(w, r)
+	if !ok {
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, filterItems(s.loadedItems(), query))
+}
+
+// fuzzyParam parses the fuzzy parameter, true unless set, writing a 400
+// response if it is invalid.
+func fuzzyParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	v := r.URL.Query().Get("fuzzy")
+	if v == "" {
+		return true, true
+	}
+	fuzzy, err := strconv.ParseBool(v)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid fuzzy %q", v))
+		return false, false
+	}
+	return fuzzy, true
+}
+
+// itemQueryParam parses the q parameter as an item query, fuzzy unless
+// fuzzy=false, writing a 400 response if either is invalid.
+func itemQueryParam(w http.ResponseWriter, r *http.Request) (*itemQuery, bool) {
+	fuzzy, ok := fuzzyParam(w, r)
+	if !ok {
+		return nil, false
+	}
+	query, err := parseItemQuery(r.URL.Query().Get("q"), fuzzy)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid q: %w", err))
+		return nil, false
+	}
+	return query, true
+}
+
+// filterItems returns the items matching query, as for -item-search. A
+// fuzzy query orders them by relevance and sets their scores. A nil query
+// matches every item.
+func filterItems(items []menuItem, query *itemQuery) []menuItem {
+	filtered := []menuItem{}
+	for _, it := range items {
+		score := query.score(it)
+		if score == 0 {
+			continue
+		}
+		if query != nil && query.fuzzy {
+			it.Score = roundScore(score)
+		}
+		filtered = append(filtered, it)
+	}
+	sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].Score > filtered[j].Score })
+	return filtered
+}
+
+// venuePage is the paginated response of /api/venues.
+type venuePage struct {
+	Total  int         `json:"total"`
+	Offset int         `json:"offset"`
+	Venues []jdw.Venue `json:"venues"`
+}
+
+func paginate(venues []jdw.Venue, q map[string][]string) (venuePage, error) {
+	page := venuePage{Total: len(venues)}
+	parse := func(key string) (int, error) {
+		vals := q[key]
+		if len(vals) == 0 || vals[0] == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(vals[0])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid %s %q", key, vals[0])
+		}
+		return n, nil
+	}
+	offset, err := parse("offset")
+	if err != nil {
+		return page, err
+	}
+	limit, err := parse("limit")
+	if err != nil {
+		return page, err
+	}
+
+	if offset > len(venues) {
+		offset = len(venues)
+	}
+	venues = venues[offset:]
+	if limit > 0 && limit < len(venues) {
+		venues = venues[:limit]
+	}
+	page.Offset = offset
+	page.Venues = venues
+	if page.Venues == nil {
+		page.Venues = []jdw.Venue{}
+	}
+	return page, nil
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIJSON(w, status, map[string]string{"error": err.Error()})
+}
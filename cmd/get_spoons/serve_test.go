@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+)
+
+func newTestAPIServer(t *testing.T, preload bool) (*jdwtest.Server, *httptest.Server) {
+	t.Helper()
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 8})
+	t.Cleanup(fake.Close)
+
+	api := newAPIServer(fake.NewClient(), expandOptions{Concurrency: 4, IncludeMenus: true, IncludeItems: true}, preload)
+	if err := api.refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	srv := httptest.NewServer(api.handler())
+	t.Cleanup(srv.Close)
+	return fake, srv
+}
+
+func getJSON(t *testing.T, url string, status int, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		t.Fatalf("GET %s: expected status %d, got %d", url, status, resp.StatusCode)
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s: decoding response failed: %v", url, err)
+		}
+	}
+}
+
+func TestServeVenues(t *testing.T) {
+	fake, srv := newTestAPIServer(t, false)
+	first := fake.Dataset.Venues[0]
+
+	var page venuePage
+	getJSON(t, srv.URL+"/api/venues", http.StatusOK, &page)
+	if page.Total != 8 || len(page.Venues) != 8 {
+		t.Errorf("Expected 8 venues, got %d/%d", page.Total, len(page.Venues))
+	}
+
+	getJSON(t, srv.URL+"/api/venues?limit=3&offset=2&sort=id", http.StatusOK, &page)
+	if len(page.Venues) != 3 || page.Venues[0].ID != fake.Dataset.Venues[2].ID {
+		t.Errorf("Unexpected page: %+v", page)
+	}
+
+	getJSON(t, srv.URL+"/api/venues?town="+strings.ToLower(first.Address.Town), http.StatusOK, &page)
+	for _, v := range page.Venues {
+		if v.Address.Town != first.Address.Town {
+			t.Errorf("Expected only venues in %s, got %s", first.Address.Town, v.Address.Town)
+		}
+	}
+
+	getJSON(t, srv.URL+"/api/venues?q="+strings.ReplaceAll(first.Name, " ", "+"), http.StatusOK, &page)
+	if len(page.Venues) == 0 || page.Venues[0].ID != first.ID {
+		t.Errorf("Expected %s as the best match, got %+v", first.Name, page.Venues)
+	}
+
+	// A typo is only tolerated by fuzzy search.
+	getJSON(t, srv.URL+"/api/venues?q=moon+undr+water&fuzzy=1", http.StatusOK, &page)
+	if len(page.Venues) == 0 {
+		t.Error("Expected fuzzy=1 to tolerate a typo")
+	}
+	for _, v := range []string{"0", "FALSE", "f"} {
+		getJSON(t, srv.URL+"/api/venues?q=moon+undr+water&fuzzy="+v, http.StatusOK, &page)
+		if len(page.Venues) != 0 {
+			t.Errorf("Expected fuzzy=%s to need an exact substring, got %d venues", v, len(page.Venues))
+		}
+	}
+	getJSON(t, srv.URL+"/api/venues?q=moon&fuzzy=maybe", http.StatusBadRequest, nil)
+
+	closed := 0
+	for _, v := range fake.Dataset.Venues {
+		if v.IsClosed {
+			closed++
+		}
+	}
+	for _, v := range []string{"true", "1", "True"} {
+		getJSON(t, srv.URL+"/api/venues?closed="+v, http.StatusOK, &page)
+		if page.Total != closed {
+			t.Errorf("Expected closed=%s to match %d venues, got %d", v, closed, page.Total)
+		}
+	}
+	getJSON(t, srv.URL+"/api/venues?closed=0", http.StatusOK, &page)
+	if page.Total != 8-closed {
+		t.Errorf("Expected closed=0 to match %d venues, got %d", 8-closed, page.Total)
+	}
+	getJSON(t, srv.URL+"/api/venues?closed=yes", http.StatusBadRequest, nil)
+
+	getJSON(t, srv.URL+"/api/venues?sort=rating", http.StatusBadRequest, nil)
+	getJSON(t, srv.URL+"/api/venues?limit=-1", http.StatusBadRequest, nil)
+}
+
+func TestServeNearest(t *testing.T) {
+	fake, srv := newTestAPIServer(t, false)
+	target := fake.Dataset.Venues[3]
+
+	var nearby []nearbyVenue
+	loc := target.Address.Location
+	getJSON(t, srv.URL+"/api/venues/nearest?limit=2&lat="+jsonFloat(loc.Latitude)+"&lng="+jsonFloat(loc.Longitude), http.StatusOK, &nearby)
+	if len(nearby) != 2 || nearby[0].ID != target.ID || nearby[0].DistanceKm != 0 {
+		t.Errorf("Expected %s first at distance 0, got %+v", target.Name, nearby)
+	}
+
+	getJSON(t, srv.URL+"/api/venues/nearest", http.StatusBadRequest, nil)
+}
+
+func TestServeVenueItemsAndPrices(t *testing.T) {
+	fake, srv := newTestAPIServer(t, false)
+	v := fake.Dataset.Venues[0]
+	base := srv.URL + "/api/venues/" + jsonFloat(float64(v.ID))
+
+	var detail struct {
+		Venue   jdw.Venue              `json:"venue"`
+		Details map[string]interface{} `json:"details"`
+	}
+	getJSON(t, base, http.StatusOK, &detail)
+	if detail.Venue.ID != v.ID || detail.Details["menus"] == nil {
+		t.Errorf("Expected expanded venue, got %+v", detail)
+	}
+
+	var items []menuItem
+	getJSON(t, base+"/items?q=stella", http.StatusOK, &items)
+	if len(items) != 1 || items[0].Name != "Stella Artois" {
+		t.Errorf("Expected Stella Artois, got %+v", items)
+	}
+
+	var prices []priceEntry
+	getJSON(t, base+"/prices?q=stella", http.StatusOK, &prices)
+	if len(prices) != 2 || prices[0].Portion != "Half" || prices[0].Price > prices[1].Price {
+		t.Errorf("Expected half then pint prices, got %+v", prices)
+	}
+
+	// Menus are fetched once per snapshot.
+	getJSON(t, base+"/items", http.StatusOK, &items)
+	if got := fake.Requests(jdw.EndpointMenus); got != 1 {
+		t.Errorf("Expected menus to be fetched once, got %d", got)
+	}
+
+	getJSON(t, srv.URL+"/api/venues/999999", http.StatusNotFound, nil)
+	getJSON(t, srv.URL+"/api/venues/abc", http.StatusBadRequest, nil)
+}
+
+func TestServeRetriesFailedExpansion(t *testing.T) {
+	fake, srv := newTestAPIServer(t, false)
+	base := srv.URL + "/api/venues/" + jsonFloat(float64(fake.Dataset.Venues[0].ID))
+
+	fake.FailEndpoint(jdw.EndpointVenueDetails, http.StatusServiceUnavailable)
+	getJSON(t, base, http.StatusBadGateway, nil)
+	failed := fake.Requests(jdw.EndpointVenueDetails)
+
+	// The failure isn't cached, so the venue recovers without a refresh.
+	fake.FailEndpoint(jdw.EndpointVenueDetails, 0)
+	getJSON(t, base, http.StatusOK, nil)
+	getJSON(t, base+"/items", http.StatusOK, nil)
+	if got := fake.Requests(jdw.EndpointVenueDetails); got != failed+1 {
+		t.Errorf("Expected one more details request after the failure, got %d", got-failed)
+	}
+}
+
+func TestServeItemsPreload(t *testing.T) {
+	_, srv := newTestAPIServer(t, true)
+
+	var items []menuItem
+	getJSON(t, srv.URL+"/api/items?q=guinness", http.StatusOK, &items)
+	if len(items) != 8 {
+		t.Errorf("Expected Guinness at all 8 venues, got %d", len(items))
+	}
+	getJSON(t, srv.URL+"/api/items", http.StatusBadRequest, nil)
+
+	getJSON(t, srv.URL+"/api/items?q=guinness+price%3C4", http.StatusOK, &items)
+	for _, it := range items {
+		if it.Portions[0].Price >= 4 && (len(it.Portions) == 1 || it.Portions[1].Price >= 4) {
+			t.Errorf("Expected a portion under £4, got %+v", it)
+		}
+	}
+	var apiErr map[string]string
+	getJSON(t, srv.URL+"/api/items?q=price%3Ccheap", http.StatusBadRequest, &apiErr)
+	if !strings.Contains(apiErr["error"], "invalid q") {
+		t.Errorf("Expected an invalid query error, got %v", apiErr)
+	}
+
+	getJSON(t, srv.URL+"/api/items?q=guiness", http.StatusOK, &items)
+	if len(items) != 8 || items[0].Score != 0.7 {
+		t.Errorf("Expected a typo to find Guinness with a lower score, got %d items", len(items))
+	}
+	getJSON(t, srv.URL+"/api/items?q=guiness&fuzzy=false", http.StatusOK, &items)
+	if len(items) != 0 {
+		t.Errorf("Expected fuzzy=false to need an exact substring, got %d items", len(items))
+	}
+	getJSON(t, srv.URL+"/api/items?q=guinness&fuzzy=maybe", http.StatusBadRequest, nil)
+}
+
+func TestServeItemsNeedsPreload(t *testing.T) {
+	fake, srv := newTestAPIServer(t, false)
+	// Even with a venue expanded, the results would be incomplete.
+	getJSON(t, srv.URL+"/api/venues/"+jsonFloat(float64(fake.Dataset.Venues[0].ID))+"/items", http.StatusOK, nil)
+
+	var apiErr map[string]string
+	getJSON(t, srv.URL+"/api/items?q=guinness", http.StatusNotImplemented, &apiErr)
+	if !strings.Contains(apiErr["error"], "-preload") {
+		t.Errorf("Expected the error to mention -preload, got %v", apiErr)
+	}
+}
+
+func TestServeStatusAndRefresh(t *testing.T) {
+	fake, srv := newTestAPIServer(t, false)
+
+	resp, err := http.Post(srv.URL+"/api/refresh", "", nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /api/refresh failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := fake.Requests(jdw.EndpointVenues); got != 2 {
+		t.Errorf("Expected 2 venue list requests, got %d", got)
+	}
+
+	fake.FailEndpoint(jdw.EndpointVenues, http.StatusServiceUnavailable)
+	resp, _ = http.Post(srv.URL+"/api/refresh", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected 502 when refresh fails, got %d", resp.StatusCode)
+	}
+
+	var status map[string]interface{}
+	getJSON(t, srv.URL+"/api/status", http.StatusOK, &status)
+	if status["venues"].(float64) != 8 {
+		t.Errorf("Expected previous snapshot to be kept, got %v", status)
+	}
+
+	resp, err = http.Get(srv.URL + "/openapi.yaml")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /openapi.yaml failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestServeSerializesRefreshes(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 4})
+	defer fake.Close()
+	client := fake.NewClient()
+	api := newAPIServer(client, expandOptions{Concurrency: 4, IncludeMenus: true, IncludeItems: true}, true)
+	if err := api.refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	crawl := fake.Requests(jdw.EndpointVenueDetails) + fake.Requests(jdw.EndpointMenus) + fake.Requests(jdw.EndpointMenuItems)
+
+	var mu sync.Mutex
+	var endpoints []string
+	client.SetRequestObserver(func(info jdw.RequestInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		endpoints = append(endpoints, info.Endpoint)
+	})
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := api.refresh(); err != nil {
+				t.Errorf("refresh failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Each refresh lists the venues and crawls them before the next starts.
+	if len(endpoints) != 3*(crawl+1) {
+		t.Fatalf("Expected 3 refreshes of %d requests, got %d", crawl+1, len(endpoints))
+	}
+	for i, e := range endpoints {
+		if isList := e == jdw.EndpointVenues; isList != (i%(crawl+1) == 0) {
+			t.Fatalf("Expected refreshes not to overlap, got %v", endpoints)
+		}
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	// London to Manchester is roughly 262km.
+	d := distanceKm(51.5074, -0.1278, 53.4808, -2.2426)
+	if math.Abs(d-262) > 5 {
+		t.Errorf("Expected ~262km, got %.1f", d)
+	}
+}
+
+func jsonFloat(f float64) string {
+	b, _ := json.Marshal(f)
+	return string(b)
+}
@@ -0,0 +1,133 @@
+package main
+
+import "strings"
+
+// stem reduces a lower-case English word to its stem with step 1 of the
+// Porter stemmer, which strips plurals and -ed/-ing endings ("burgers" and
+// "burger", "battered" and "batter", "chips" and "chip"). That is enough to
+// match menu wording without the over-stemming of the later steps. Words
+// that are short or not plain ASCII letters are returned unchanged.
+func stem(w string) string {
+	if len(w) <= 2 {
+		return w
+	}
+	for i := 0; i < len(w); i++ {
+		if w[i] < 'a' || w[i] > 'z' {
+			return w
+		}
+	}
+	return stepOneC(stepOneB(stepOneA(w)))
+}
+
+// stepOneA strips plurals: sses -> ss, ies -> i, s -> "". As in Porter2,
+// ies after a single letter becomes ie, so that "pies" stays with "pie".
+func stepOneA(w string) string {
+	switch {
+	case strings.HasSuffix(w, "sses"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "ies") && len(w) == 4:
+		return w[:len(w)-1]
+	case strings.HasSuffix(w, "ies"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "ss"):
+		return w
+	case strings.HasSuffix(w, "s"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// stepOneB strips -eed, -ed and -ing, tidying up the stem left behind.
+func stepOneB(w string) string {
+	if strings.HasSuffix(w, "eed") {
+		if measure(w[:len(w)-3]) > 0 {
+			return w[:len(w)-1]
+		}
+		return w
+	}
+
+	var base string
+	switch {
+	case strings.HasSuffix(w, "ed") && hasVowel(w[:len(w)-2]):
+		base = w[:len(w)-2]
+	case strings.HasSuffix(w, "ing") && hasVowel(w[:len(w)-3]):
+		base = w[:len(w)-3]
+	default:
+		return w
+	}
+
+	switch {
+	case strings.HasSuffix(base, "at"), strings.HasSuffix(base, "bl"), strings.HasSuffix(base, "iz"):
+		return base + "e"
+	case endsWithDoubleConsonant(base):
+		if last := base[len(base)-1]; last != 'l' && last != 's' && last != 'z' {
+			return base[:len(base)-1]
+		}
+	case measure(base) == 1 && endsCVC(base):
+		return base + "e"
+	}
+	return base
+}
+
+// stepOneC turns a final y into i when the stem has a vowel, so that
+// "curry" and "curries" meet at "curri".
+func stepOneC(w string) string {
+	if strings.HasSuffix(w, "y") && hasVowel(w[:len(w)-1]) {
+		return w[:len(w)-1] + "i"
+	}
+	return w
+}
+
+// isConsonant reports whether w[i] is a consonant; y is a consonant unless
+// it follows one.
+func isConsonant(w string, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(w, i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences in w, the m of [C](VC)^m[V].
+func measure(w string) int {
+	m := 0
+	vowel := false
+	for i := range len(w) {
+		if isConsonant(w, i) {
+			if vowel {
+				m++
+			}
+			vowel = false
+		} else {
+			vowel = true
+		}
+	}
+	return m
+}
+
+func hasVowel(w string) bool {
+	for i := range len(w) {
+		if !isConsonant(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+func endsWithDoubleConsonant(w string) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && isConsonant(w, n-1)
+}
+
+// endsCVC reports whether w ends consonant-vowel-consonant, where the last
+// consonant is not w, x or y ("hop", but not "snow").
+func endsCVC(w string) bool {
+	n := len(w)
+	if n < 3 || !isConsonant(w, n-3) || isConsonant(w, n-2) || !isConsonant(w, n-1) {
+		return false
+	}
+	last := w[n-1]
+	return last != 'w' && last != 'x' && last != 'y'
+}
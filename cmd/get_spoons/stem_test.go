@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"burgers":  "burger",
+		"burger":   "burger",
+		"chips":    "chip",
+		"battered": "batter",
+		"roasted":  "roast",
+		"glasses":  "glass",
+		"curries":  "curri",
+		"pies":     "pie",
+		"curry":    "curri",
+		"hopping":  "hop",
+		"agreed":   "agree",
+		"filling":  "fill",
+		"sizzled":  "sizzl",
+		"caked":    "cake",
+		"gas":      "ga",
+		"ox":       "ox",
+		"wv14":     "wv14",
+		"café":     "café",
+	}
+	for word, want := range tests {
+		if got := stem(word); got != want {
+			t.Errorf("stem(%q): expected %q, got %q", word, want, got)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// errNoToken is returned when no token source is configured.
+var errNoToken = errors.New("no JDW token configured: set JDW_TOKEN, or use -token, -token-file, -token-cmd or 'token' in a config profile")
+
+// tokenCmdTimeout bounds how long -token-cmd may run.
+const tokenCmdTimeout = 30 * time.Second
+
+// sourceRank orders flag sources by precedence.
+var sourceRank = map[string]int{sourceFlag: 3, sourceEnv: 2, sourceProfile: 1}
+
+// resolveToken returns the bearer token from whichever of -token, -token-file
+// and -token-cmd was set with the highest precedence (flag > env > profile).
+// Setting two of them at the same level is an error. No token is needed when
+// responses come only from fixtures or the cache.
+func (c *clientFlags) resolveToken() (string, error) {
+	sources := []struct {
+		flag, value string
+	}{
+		{"token", c.token},
+		{"token-file", c.tokenFile},
+		{"token-cmd", c.tokenCmd},
+	}
+
+	best, bestRank := "", -1
+	var tied []string
+	for _, s := range sources {
+		if s.value == "" {
+			continue
+		}
+		rank := 0
+		if c.eff != nil {
+			rank = sourceRank[c.eff.Sources[s.flag]]
+		}
+		switch {
+		case rank > bestRank:
+			best, bestRank, tied = s.flag, rank, []string{"-" + s.flag}
+		case rank == bestRank:
+			tied = append(tied, "-"+s.flag)
+		}
+	}
+	if len(tied) > 1 {
+		return "", fmt.Errorf("%s are set at the same level; use only one token source", strings.Join(tied, " and "))
+	}
+
+	var (
+		token string
+		err   error
+	)
+	switch best {
+	case "token":
+		token = c.token
+	case "token-file":
+		token, err = readTokenFile(c.tokenFile)
+	case "token-cmd":
+		token, err = runTokenCmd(c.tokenCmd)
+	default:
+		if c.replayDir != "" || c.offline {
+			return "", nil
+		}
+		return "", errNoToken
+	}
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", fmt.Errorf("-%s produced an empty token", best)
+	}
+	slog.Debug("Using token", "token", jdw.MaskToken(token), "source", "-"+best)
+	return token, nil
+}
+
+// readTokenFile reads a token from the first line of path, or of stdin when
+// path is "-". Files readable by other users are allowed but warned about.
+func readTokenFile(path string) (string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", fmt.Errorf("reading token file: %w", err)
+		}
+		defer f.Close()
+		if info, err := f.Stat(); err == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+			slog.Warn("Token file is accessible by other users; consider chmod 600", "path", path, "mode", info.Mode().Perm().String())
+		}
+		r = f
+	}
+	return firstLine(r)
+}
+
+// runTokenCmd runs cmd through the shell and returns the first line of its
+// output, like credential helpers such as "pass show jdw".
+func runTokenCmd(cmd string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCmdTimeout)
+	defer cancel()
+
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/C", cmd)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", cmd)
+	}
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("running -token-cmd: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("running -token-cmd: %w", err)
+	}
+	return firstLine(bytes.NewReader(out))
+}
+
+func firstLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+)
+
+func parseClientFlags(t *testing.T, args ...string) *clientFlags {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	if err := cf.parse(fs, args); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	return &cf
+}
+
+func TestResolveToken(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parseClientFlags(t).resolveToken(); !errors.Is(err, errNoToken) {
+		t.Errorf("Expected errNoToken, got %v", err)
+	}
+	if token, err := parseClientFlags(t, "-replay", dir).resolveToken(); err != nil || token != "" {
+		t.Errorf("Expected no token to be needed with -replay, got %q, %v", token, err)
+	}
+
+	if token, err := parseClientFlags(t, "-token-file", tokenFile).resolveToken(); err != nil || token != "file-token" {
+		t.Errorf("Expected token from file, got %q, %v", token, err)
+	}
+	if _, err := parseClientFlags(t, "-token-file", filepath.Join(dir, "missing")).resolveToken(); err == nil {
+		t.Error("Expected an error for a missing token file")
+	}
+
+	if runtime.GOOS != "windows" {
+		if token, err := parseClientFlags(t, "-token-cmd", "echo cmd-token; echo second-line").resolveToken(); err != nil || token != "cmd-token" {
+			t.Errorf("Expected token from command, got %q, %v", token, err)
+		}
+		_, err := parseClientFlags(t, "-token-cmd", "echo locked >&2; exit 1").resolveToken()
+		if err == nil || !strings.Contains(err.Error(), "locked") {
+			t.Errorf("Expected the command's stderr in the error, got %v", err)
+		}
+		if _, err := parseClientFlags(t, "-token-cmd", "true").resolveToken(); err == nil {
+			t.Error("Expected an error for an empty token")
+		}
+	}
+
+	// A flag beats the environment, but two flags conflict.
+	t.Setenv("JDW_TOKEN", "env-token")
+	if token, err := parseClientFlags(t).resolveToken(); err != nil || token != "env-token" {
+		t.Errorf("Expected token from JDW_TOKEN, got %q, %v", token, err)
+	}
+	if token, err := parseClientFlags(t, "-token-file", tokenFile).resolveToken(); err != nil || token != "file-token" {
+		t.Errorf("Expected -token-file to beat JDW_TOKEN, got %q, %v", token, err)
+	}
+	if _, err := parseClientFlags(t, "-token", "a", "-token-file", tokenFile).resolveToken(); err == nil {
+		t.Error("Expected an error when two token flags are set")
+	}
+}
+
+func TestTokenFromStdin(t *testing.T) {
+	r, w, _ := os.Pipe()
+	oldStdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = oldStdin }()
+
+	w.WriteString("stdin-token\n")
+	w.Close()
+
+	if token, err := parseClientFlags(t, "-token-file", "-").resolveToken(); err != nil || token != "stdin-token" {
+		t.Errorf("Expected token from stdin, got %q, %v", token, err)
+	}
+}
+
+func TestTokenMaskedInDebugOutput(t *testing.T) {
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 1})
+	defer fake.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte(fake.Token()), 0o600)
+
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	rOut, wOut, _ := os.Pipe()
+	r, w, _ := os.Pipe()
+	os.Stdout, os.Stderr = wOut, w
+
+	cf := parseClientFlags(t, "-token-file", tokenFile, "-api-url", fake.URL, "-debug")
+	client, err := cf.newClient()
+	if err == nil {
+		_, err = client.GetVenues()
+	}
+
+	wOut.Close()
+	w.Close()
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+	stdout, _ := io.ReadAll(rOut)
+	out, _ := io.ReadAll(r)
+
+	if err != nil {
+		t.Fatalf("GetVenues with -token-file failed: %v", err)
+	}
+	if len(stdout) != 0 {
+		t.Errorf("Expected no debug output on stdout, got:\n%s", stdout)
+	}
+	if strings.Contains(string(out), fake.Token()) {
+		t.Errorf("Expected the token to be masked in debug output:\n%s", out)
+	}
+	if !strings.Contains(string(out), jdw.MaskToken(fake.Token())) {
+		t.Errorf("Expected the masked token in debug output:\n%s", out)
+	}
+}
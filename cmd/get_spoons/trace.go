@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters for -trace.
+const (
+	traceNone    = "none"
+	traceOTLP    = "otlp"
+	traceStdout  = "stdout"
+	traceConsole = "console" // the OTEL_TRACES_EXPORTER name for stdout
+)
+
+// tracer traces crawl stages. It uses the global provider, which is a no-op
+// unless -trace is set.
+var tracer = otel.Tracer("github.com/KRoperUK/get_spoons/cmd/get_spoons")
+
+// shutdownTracing flushes and stops the tracer provider installed by
+// setupTracing. Run calls it before returning.
+var shutdownTracing = func(context.Context) error { return nil }
+
+// setupTracing installs a global tracer provider exporting spans with the
+// given exporter. The OTLP exporter is configured by the standard
+// OTEL_EXPORTER_OTLP_* environment variables; the stdout exporter writes to
+// stderr so that it doesn't mix with command output.
+func setupTracing(exporter string) error {
+	var (
+		exp sdktrace.SpanExporter
+		err error
+	)
+	switch exporter {
+	case traceNone:
+		return nil
+	case traceOTLP:
+		exp, err = otlptracehttp.New(context.Background())
+	case traceStdout, traceConsole:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+	default:
+		return fmt.Errorf("invalid -trace %q: must be none, otlp or stdout", exporter)
+	}
+	if err != nil {
+		return fmt.Errorf("creating %s trace exporter: %w", exporter, err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults.
+	res, err := resource.New(context.Background(),
+		resource.WithAttributes(
+			attribute.String("service.name", "get_spoons"),
+			attribute.String("service.version", Version),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return fmt.Errorf("creating trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	shutdownTracing = func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return tp.Shutdown(ctx)
+	}
+	return nil
+}
+
+// venueAttrs identifies a venue on crawl spans.
+func venueAttrs(v jdw.Venue) trace.SpanStartOption {
+	return trace.WithAttributes(
+		attribute.Int("jdw.venue_id", v.ID),
+		jdw.AttrVenueRef.Int(v.VenueRef),
+		attribute.String("jdw.venue_name", v.Name),
+	)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestExpandVenuesTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	old := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(old)
+
+	fake := jdwtest.NewServer(jdwtest.Options{Venues: 2})
+	defer fake.Close()
+	client := fake.NewClient()
+	venues, err := client.GetVenues()
+	if err != nil {
+		t.Fatalf("GetVenues failed: %v", err)
+	}
+
+	expandVenues(client, venues, expandOptions{Concurrency: 2, IncludeMenus: true, IncludeItems: true})
+
+	byID := make(map[string]sdktrace.ReadOnlySpan)
+	var root sdktrace.ReadOnlySpan
+	var venueSpans []sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		byID[s.SpanContext().SpanID().String()] = s
+		switch s.Name() {
+		case "expandVenues":
+			root = s
+		case "expandVenue":
+			venueSpans = append(venueSpans, s)
+		}
+	}
+	if root == nil {
+		t.Fatal("Expected an expandVenues span")
+	}
+	if len(venueSpans) != len(venues) {
+		t.Fatalf("Expected %d expandVenue spans, got %d", len(venues), len(venueSpans))
+	}
+	for _, s := range venueSpans {
+		if s.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("Expected expandVenue to be a child of expandVenues")
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, s := range recorder.Ended() {
+		parent, ok := byID[s.Parent().SpanID().String()]
+		switch s.Name() {
+		case "jdw.GetVenueDetails", "jdw.GetMenus", "jdw.GetMenuItems":
+			counts[s.Name()]++
+			if !ok || parent.Name() != "expandVenue" {
+				t.Errorf("Expected %s to be a child of expandVenue", s.Name())
+				continue
+			}
+			var ref int64
+			for _, kv := range s.Attributes() {
+				if kv.Key == jdw.AttrVenueRef {
+					ref = kv.Value.AsInt64()
+				}
+			}
+			var parentRef int64
+			for _, kv := range parent.Attributes() {
+				if kv.Key == jdw.AttrVenueRef {
+					parentRef = kv.Value.AsInt64()
+				}
+			}
+			if ref == 0 || ref != parentRef {
+				t.Errorf("Expected %s venue ref %d to match its expandVenue span, got %d", s.Name(), parentRef, ref)
+			}
+		}
+	}
+	if counts["jdw.GetVenueDetails"] != len(venues) || counts["jdw.GetMenus"] != len(venues) || counts["jdw.GetMenuItems"] == 0 {
+		t.Errorf("Unexpected client span counts: %v", counts)
+	}
+}
+
+func TestSetupTracingInvalid(t *testing.T) {
+	if err := setupTracing("zipkin"); err == nil {
+		t.Error("Expected an error for an unsupported exporter")
+	}
+	if err := setupTracing(traceNone); err != nil {
+		t.Errorf("Expected no error for none, got %v", err)
+	}
+}
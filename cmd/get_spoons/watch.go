@@ -0,0 +1,570 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// Change event types sent by "watch".
+const (
+	eventVenueOpened   = "venue_opened"
+	eventVenueClosed   = "venue_closed"
+	eventVenueReopened = "venue_reopened"
+	eventVenueRemoved  = "venue_removed"
+	eventPriceChanged  = "price_changed"
+)
+
+// Webhook payload formats.
+const (
+	formatJSON    = "json"
+	formatSlack   = "slack"
+	formatDiscord = "discord"
+)
+
+// signatureHeader carries the HMAC-SHA256 of the request body when a webhook
+// secret is configured.
+const signatureHeader = "X-Get-Spoons-Signature"
+
+const defaultMessageTemplate = `{{- if eq .Type "venue_opened" -}}
+New venue: {{.Venue.Name}}, {{.Venue.Town}} {{.Venue.Postcode}}
+{{- else if eq .Type "venue_closed" -}}
+{{.Venue.Name}}, {{.Venue.Town}} is now closed
+{{- else if eq .Type "venue_reopened" -}}
+{{.Venue.Name}}, {{.Venue.Town}} has reopened
+{{- else if eq .Type "venue_removed" -}}
+{{.Venue.Name}}, {{.Venue.Town}} is no longer listed
+{{- else if eq .Type "price_changed" -}}
+{{.Item}}{{if .Portion}} ({{.Portion}}){{end}} at {{.Venue.Name}}: £{{printf "%.2f" .OldPrice}} → £{{printf "%.2f" .NewPrice}}
+{{- end}}`
+
+// runWatch implements the "watch" subcommand: it polls the API, compares the
+// result against the previous poll and POSTs change events to webhooks.
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("get_spoons watch", flag.ContinueOnError)
+	var cf clientFlags
+	cf.register(fs)
+	var hooks webhookFlags
+	fs.Var(&hooks, "webhook", "Webhook to POST change events to, as URL or FORMAT=URL where FORMAT is json, slack or discord (repeatable)")
+	interval := fs.Duration("interval", 10*time.Minute, "How often to poll")
+	once := fs.Bool("once", false, "Poll once, send events and exit (use with -state)")
+	statePath := fs.String("state", "", "Persist the last poll to this file so changes are detected across runs")
+	secret := fs.String("secret", "", "Sign webhook bodies with HMAC-SHA256 using this secret (env JDW_WEBHOOK_SECRET)")
+	near := fs.String("near", "", "Only watch venues near this location, as 'lat,lng'")
+	radius := fs.Float64("radius", 5, "Radius in km for -near")
+	venueIDs := fs.String("venues", "", "Only watch these venue IDs (comma-separated)")
+	trackItems := fs.String("track-items", "", "Track prices of items matching these queries at watched venues (comma-separated, e.g. 'stella pint,guinness')")
+	messageTemplate := fs.String("message-template", defaultMessageTemplate, "Go template for event messages")
+	concurrency := fs.Int("concurrency", 4, "Number of concurrent requests when tracking items")
+	retries := fs.Int("retries", 1, "Number of times to retry failed detail, menu and item requests")
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+
+	filter, err := parseWatchFilter(*near, *radius, *venueIDs)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New("message").Parse(*messageTemplate)
+	if err != nil {
+		return fmt.Errorf("invalid -message-template: %w", err)
+	}
+	if len(hooks) == 0 {
+		slog.Warn("No -webhook configured; events will only be logged")
+	}
+
+	client, err := cf.newClient()
+	if err != nil {
+		return err
+	}
+
+	w := &watcher{
+		client:  client,
+		filter:  filter,
+		items:   splitList(*trackItems),
+		opts:    expandOptions{Concurrency: *concurrency, IncludeMenus: true, IncludeItems: true, Retries: *retries},
+		hooks:   hooks,
+		secret:  *secret,
+		message: tmpl,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+	if *statePath != "" {
+		if w.state, err = loadWatchState(*statePath); err != nil {
+			return err
+		}
+	}
+
+	poll := func() error {
+		if err := w.poll(); err != nil {
+			return err
+		}
+		if *statePath != "" {
+			return saveWatchState(*statePath, w.state)
+		}
+		return nil
+	}
+
+	if *once {
+		return poll()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := poll(); err != nil {
+			slog.Error("Polling failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// webhook is a destination for change events.
+type webhook struct {
+	URL    string
+	Format string
+}
+
+// webhookFlags collects repeated -webhook flags.
+type webhookFlags []webhook
+
+func (f *webhookFlags) String() string {
+	var urls []string
+	for _, h := range *f {
+		urls = append(urls, h.URL)
+	}
+	return strings.Join(urls, ",")
+}
+
+func (f *webhookFlags) Set(value string) error {
+	h := webhook{URL: value, Format: formatJSON}
+	if format, url, ok := strings.Cut(value, "="); ok {
+		switch format {
+		case formatJSON, formatSlack, formatDiscord:
+			h = webhook{URL: url, Format: format}
+		}
+	}
+	if !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+		return fmt.Errorf("invalid webhook URL %q", h.URL)
+	}
+	*f = append(*f, h)
+	return nil
+}
+
+// watchFilter selects the venues that are watched.
+type watchFilter struct {
+	near     bool
+	lat, lng float64
+	radiusKm float64
+	ids      map[int]bool
+}
+
+func parseWatchFilter(near string, radius float64, ids string) (watchFilter, error) {
+	var f watchFilter
+	if near != "" {
+		latStr, lngStr, ok := strings.Cut(near, ",")
+		lat, errLat := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+		lng, errLng := strconv.ParseFloat(strings.TrimSpace(lngStr), 64)
+		if !ok || errLat != nil || errLng != nil {
+			return f, fmt.Errorf("invalid -near %q: expected 'lat,lng'", near)
+		}
+		f.near, f.lat, f.lng, f.radiusKm = true, lat, lng, radius
+	}
+	for _, s := range splitList(ids) {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return f, fmt.Errorf("invalid venue ID %q", s)
+		}
+		if f.ids == nil {
+			f.ids = make(map[int]bool)
+		}
+		f.ids[id] = true
+	}
+	return f, nil
+}
+
+// matches reports whether v is watched. With both -near and -venues set, a
+// venue matching either is watched; with neither, every venue is.
+func (f watchFilter) matches(v jdw.Venue) bool {
+	if !f.near && f.ids == nil {
+		return true
+	}
+	if f.ids[v.ID] || f.ids[v.VenueRef] {
+		return true
+	}
+	return f.near && distanceKm(f.lat, f.lng, v.Address.Location.Latitude, v.Address.Location.Longitude) <= f.radiusKm
+}
+
+// watchState is the result of a poll, compared against the next one.
+type watchState struct {
+	PolledAt time.Time               `json:"polledAt"`
+	Venues   map[int]watchedVenue    `json:"venues"`
+	Prices   map[string]trackedPrice `json:"prices,omitempty"`
+}
+
+// watchedVenue is the subset of a venue included in state and events.
+type watchedVenue struct {
+	ID       int    `json:"id"`
+	VenueRef int    `json:"venueRef"`
+	Name     string `json:"name"`
+	Town     string `json:"town,omitempty"`
+	Postcode string `json:"postcode,omitempty"`
+	IsClosed bool   `json:"isClosed"`
+}
+
+// trackedPrice is the price of one portion of a tracked item at a venue.
+type trackedPrice struct {
+	VenueID int     `json:"venueId"`
+	Item    string  `json:"item"`
+	Portion string  `json:"portion,omitempty"`
+	Price   float64 `json:"price"`
+}
+
+func (p trackedPrice) key() string {
+	return fmt.Sprintf("%d/%s/%s", p.VenueID, p.Item, p.Portion)
+}
+
+// changeEvent is the JSON body POSTed to webhooks in the json format.
+type changeEvent struct {
+	Type     string       `json:"type"`
+	Time     time.Time    `json:"time"`
+	Venue    watchedVenue `json:"venue"`
+	Item     string       `json:"item,omitempty"`
+	Portion  string       `json:"portion,omitempty"`
+	OldPrice float64      `json:"oldPrice,omitempty"`
+	NewPrice float64      `json:"newPrice,omitempty"`
+	Message  string       `json:"message"`
+}
+
+func loadWatchState(path string) (*watchState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state watchState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("reading state %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+func saveWatchState(path string, state *watchState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes data to path via a temporary file in the same
+// directory, so an interrupted write never leaves a truncated file behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// watcher polls the API and delivers change events.
+type watcher struct {
+	client  *jdw.Client
+	filter  watchFilter
+	items   []string
+	opts    expandOptions
+	hooks   []webhook
+	secret  string
+	message *template.Template
+	http    *http.Client
+
+	// state is the previous poll; nil until the first poll, which only
+	// records a baseline.
+	state *watchState
+}
+
+// poll fetches the current state, sends events for changes since the
+// previous poll and makes the current state the new baseline.
+func (w *watcher) poll() error {
+	current, err := w.fetch()
+	if err != nil {
+		return err
+	}
+
+	if w.state == nil {
+		slog.Info("Recorded baseline", "venues", len(current.Venues), "prices", len(current.Prices))
+	} else {
+		events := diffWatchState(w.state, current)
+		for i := range events {
+			if err := w.render(&events[i]); err != nil {
+				return err
+			}
+			slog.Info(events[i].Message, "event", events[i].Type, "venue_id", events[i].Venue.ID)
+			w.deliver(events[i])
+		}
+	}
+	w.state = current
+	return nil
+}
+
+func (w *watcher) fetch() (*watchState, error) {
+	venues, err := w.client.GetVenues()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &watchState{PolledAt: time.Now().UTC(), Venues: make(map[int]watchedVenue)}
+	var watched []jdw.Venue
+	for _, v := range venues {
+		if !w.filter.matches(v) {
+			continue
+		}
+		watched = append(watched, v)
+		state.Venues[v.ID] = watchedVenue{
+			ID:       v.ID,
+			VenueRef: v.VenueRef,
+			Name:     v.Name,
+			Town:     v.Address.Town,
+			Postcode: v.Address.Postcode,
+			IsClosed: v.IsClosed,
+		}
+	}
+
+	if len(w.items) == 0 {
+		return state, nil
+	}
+
+	var open []jdw.Venue
+	for _, v := range watched {
+		if !v.IsClosed {
+			open = append(open, v)
+		}
+	}
+	details, failures := expandVenues(w.client, open, w.opts)
+	if len(failures) > 0 {
+		slog.Warn("Requests failed while fetching items; prices at those venues are unchanged", "failures", len(failures))
+	}
+
+	state.Prices = make(map[string]trackedPrice)
+	fetched := make(map[int]bool)
+	for _, d := range details {
+		items := extractItems(d)
+		if len(items) > 0 {
+			fetched[intField(d, "id")] = true
+		}
+		for _, it := range items {
+			for _, p := range it.Portions {
+				if w.tracks(it, p) {
+					tp := trackedPrice{VenueID: it.VenueID, Item: it.Name, Portion: p.Label, Price: p.Price}
+					state.Prices[tp.key()] = tp
+				}
+			}
+		}
+	}
+
+	// Keep previous prices for venues that could not be fetched, so a
+	// transient failure doesn't look like a price change later.
+	if w.state != nil {
+		for k, p := range w.state.Prices {
+			if _, ok := state.Venues[p.VenueID]; ok && !fetched[p.VenueID] {
+				state.Prices[k] = p
+			}
+		}
+	}
+	return state, nil
+}
+
+// tracks reports whether portion p of it matches a -track-items query: every
+// word of the query must appear in the item's name, description or category,
+// or in the portion label, so "stella pint" tracks only the pint.
+func (w *watcher) tracks(it menuItem, p portionPrice) bool {
+	text := strings.ToLower(it.Name + " " + it.Description + " " + it.Category + " " + p.Label)
+	for _, query := range w.items {
+		match := true
+		for _, word := range strings.Fields(strings.ToLower(query)) {
+			if !strings.Contains(text, word) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// diffWatchState returns the events between two polls, ordered by venue ID.
+func diffWatchState(prev, current *watchState) []changeEvent {
+	now := current.PolledAt
+	var events []changeEvent
+	for id, v := range current.Venues {
+		old, ok := prev.Venues[id]
+		switch {
+		case !ok:
+			events = append(events, changeEvent{Type: eventVenueOpened, Venue: v})
+		case v.IsClosed && !old.IsClosed:
+			events = append(events, changeEvent{Type: eventVenueClosed, Venue: v})
+		case !v.IsClosed && old.IsClosed:
+			events = append(events, changeEvent{Type: eventVenueReopened, Venue: v})
+		}
+	}
+	for id, v := range prev.Venues {
+		if _, ok := current.Venues[id]; !ok {
+			events = append(events, changeEvent{Type: eventVenueRemoved, Venue: v})
+		}
+	}
+	for k, p := range current.Prices {
+		old, ok := prev.Prices[k]
+		if ok && old.Price != p.Price {
+			events = append(events, changeEvent{
+				Type:     eventPriceChanged,
+				Venue:    current.Venues[p.VenueID],
+				Item:     p.Item,
+				Portion:  p.Portion,
+				OldPrice: old.Price,
+				NewPrice: p.Price,
+			})
+		}
+	}
+
+	for i := range events {
+		events[i].Time = now
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.Venue.ID != b.Venue.ID {
+			return a.Venue.ID < b.Venue.ID
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Item != b.Item {
+			return a.Item < b.Item
+		}
+		return a.Portion < b.Portion
+	})
+	return events
+}
+
+func (w *watcher) render(e *changeEvent) error {
+	var buf bytes.Buffer
+	if err := w.message.Execute(&buf, e); err != nil {
+		return fmt.Errorf("rendering message: %w", err)
+	}
+	e.Message = buf.String()
+	return nil
+}
+
+// deliver POSTs e to every webhook. Failed deliveries are reported and do not
+// stop the watch.
+func (w *watcher) deliver(e changeEvent) {
+	for _, h := range w.hooks {
+		if err := w.post(h, e); err != nil {
+			slog.Error("Webhook delivery failed", "event", e.Type, "url", h.URL, "error", err)
+		}
+	}
+}
+
+func (w *watcher) post(h webhook, e changeEvent) error {
+	body, err := webhookPayload(h.Format, e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "get_spoons/"+Version)
+	req.Header.Set("X-Get-Spoons-Event", e.Type)
+	if w.secret != "" {
+		req.Header.Set(signatureHeader, signPayload(w.secret, body))
+	}
+
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status: %s", resp.Status)
+	}
+	return nil
+}
+
+// webhookPayload builds the request body for e in the given format. Slack and
+// Discord incoming webhooks take the rendered message as "text" and
+// "content" respectively.
+func webhookPayload(format string, e changeEvent) ([]byte, error) {
+	switch format {
+	case formatSlack:
+		return json.Marshal(map[string]string{"text": e.Message})
+	case formatDiscord:
+		return json.Marshal(map[string]string{"content": e.Message})
+	default:
+		return json.Marshal(e)
+	}
+}
+
+// signPayload returns the signature header value for body: "sha256=" followed
+// by the hex HMAC-SHA256 of body keyed with secret.
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+	"github.com/KRoperUK/get_spoons/jdw/jdwtest"
+)
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(t *testing.T) (*httptest.Server, func() []receivedWebhook) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		received []receivedWebhook
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, receivedWebhook{header: r.Header.Clone(), body: body})
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []receivedWebhook {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]receivedWebhook(nil), received...)
+	}
+}
+
+// openVenues returns the indexes of open venues in d.
+func openVenues(d *jdwtest.Dataset) []int {
+	var idx []int
+	for i, v := range d.Venues {
+		if !v.IsClosed {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+func TestWatchSendsChangeEvents(t *testing.T) {
+	receiver, received := newWebhookReceiver(t)
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	args := []string{"watch", "-once", "-state", statePath, "-track-items", "stella pint",
+		"-webhook", receiver.URL + "/events", "-webhook", "slack=" + receiver.URL + "/slack", "-secret", "s3cret"}
+
+	runAgainst := func(d *jdwtest.Dataset) {
+		t.Helper()
+		fake := jdwtest.NewServer(jdwtest.Options{Dataset: d})
+		defer fake.Close()
+		os.Setenv("JDW_API_URL", fake.URL)
+		defer os.Unsetenv("JDW_API_URL")
+		os.Setenv("JDW_TOKEN", fake.Token())
+		defer os.Unsetenv("JDW_TOKEN")
+		if err := Run(args); err != nil {
+			t.Fatalf("Run watch failed: %v", err)
+		}
+	}
+
+	// The first poll only records a baseline.
+	before := jdwtest.NewDataset(1, 6)
+	runAgainst(before)
+	if n := len(received()); n != 0 {
+		t.Fatalf("Expected no events from the baseline poll, got %d", n)
+	}
+
+	after := jdwtest.NewDataset(1, 6)
+	open := openVenues(after)
+	closing, repriced := &after.Venues[open[0]], after.Venues[open[1]]
+	closing.IsClosed, closing.Status = true, "closed"
+	stella := &after.Menus[repriced.VenueRef][0].Categories[0].ItemGroups[0].Items[0]
+	oldPrice := stella.Portions[1].Price
+	stella.Portions[1].Price = oldPrice + 0.20
+	after.Venues = append(after.Venues, jdw.Venue{ID: 2000, VenueRef: 9000, Name: "The Brand New Inn", Status: "open"})
+
+	runAgainst(after)
+	got := received()
+	if len(got) != 6 {
+		t.Fatalf("Expected 3 events to each of 2 webhooks, got %d requests", len(got))
+	}
+
+	var events []changeEvent
+	for _, r := range got {
+		if want := signPayload("s3cret", r.body); r.header.Get(signatureHeader) != want {
+			t.Errorf("Expected signature %s, got %s", want, r.header.Get(signatureHeader))
+		}
+		var e changeEvent
+		if err := json.Unmarshal(r.body, &e); err != nil {
+			t.Fatalf("Decoding webhook body failed: %v", err)
+		}
+		if e.Type == "" {
+			var slack map[string]string
+			_ = json.Unmarshal(r.body, &slack)
+			if slack["text"] == "" {
+				t.Errorf("Expected a Slack payload with text, got %s", r.body)
+			}
+			continue
+		}
+		if e.Type != r.header.Get("X-Get-Spoons-Event") {
+			t.Errorf("Expected event header %s, got %s", e.Type, r.header.Get("X-Get-Spoons-Event"))
+		}
+		events = append(events, e)
+	}
+
+	byType := make(map[string]changeEvent)
+	for _, e := range events {
+		byType[e.Type] = e
+	}
+	if e := byType[eventVenueClosed]; e.Venue.ID != closing.ID {
+		t.Errorf("Expected %s to be reported closed, got %+v", closing.Name, e)
+	}
+	if e := byType[eventVenueOpened]; e.Venue.ID != 2000 {
+		t.Errorf("Expected the new venue to be reported, got %+v", e)
+	}
+	e := byType[eventPriceChanged]
+	if e.Venue.ID != repriced.ID || e.Item != "Stella Artois" || e.Portion != "Pint" || e.OldPrice != oldPrice || e.NewPrice != oldPrice+0.20 {
+		t.Errorf("Unexpected price change event: %+v", e)
+	}
+	if !strings.Contains(e.Message, "Stella Artois (Pint) at "+repriced.Name) {
+		t.Errorf("Unexpected message: %q", e.Message)
+	}
+}
+
+func TestDiffWatchState(t *testing.T) {
+	prev := &watchState{
+		Venues: map[int]watchedVenue{
+			1: {ID: 1, Name: "A"},
+			2: {ID: 2, Name: "B", IsClosed: true},
+			3: {ID: 3, Name: "C"},
+		},
+		Prices: map[string]trackedPrice{"1/Beer/Pint": {VenueID: 1, Item: "Beer", Portion: "Pint", Price: 3}},
+	}
+	current := &watchState{
+		Venues: map[int]watchedVenue{
+			1: {ID: 1, Name: "A"},
+			2: {ID: 2, Name: "B"},
+			4: {ID: 4, Name: "D"},
+		},
+		Prices: map[string]trackedPrice{"1/Beer/Pint": {VenueID: 1, Item: "Beer", Portion: "Pint", Price: 3.5}},
+	}
+
+	events := diffWatchState(prev, current)
+	want := []string{eventPriceChanged, eventVenueReopened, eventVenueRemoved, eventVenueOpened}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %+v", len(want), events)
+	}
+	for i, e := range events {
+		if e.Type != want[i] {
+			t.Errorf("Expected event %d to be %s, got %s", i, want[i], e.Type)
+		}
+	}
+}
+
+func TestWebhookFlags(t *testing.T) {
+	var hooks webhookFlags
+	for _, v := range []string{"https://example.com/hook?a=b", "discord=https://discord.com/api/webhooks/1"} {
+		if err := hooks.Set(v); err != nil {
+			t.Fatalf("Set(%q) failed: %v", v, err)
+		}
+	}
+	if hooks[0].Format != formatJSON || hooks[0].URL != "https://example.com/hook?a=b" {
+		t.Errorf("Unexpected webhook: %+v", hooks[0])
+	}
+	if hooks[1].Format != formatDiscord || hooks[1].URL != "https://discord.com/api/webhooks/1" {
+		t.Errorf("Unexpected webhook: %+v", hooks[1])
+	}
+	if err := hooks.Set("teams=https://example.com"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+
+	body, _ := webhookPayload(formatDiscord, changeEvent{Message: "hello"})
+	if string(body) != `{"content":"hello"}` {
+		t.Errorf("Unexpected Discord payload: %s", body)
+	}
+}
+
+func TestWatchFilter(t *testing.T) {
+	f, err := parseWatchFilter("51.5, -0.1", 2, "7")
+	if err != nil {
+		t.Fatalf("parseWatchFilter failed: %v", err)
+	}
+	near := jdw.Venue{ID: 1, Address: jdw.Address{Location: jdw.Location{Latitude: 51.505, Longitude: -0.1}}}
+	far := jdw.Venue{ID: 2, Address: jdw.Address{Location: jdw.Location{Latitude: 53.5, Longitude: -2.2}}}
+	byID := jdw.Venue{ID: 7, Address: far.Address}
+	if !f.matches(near) || f.matches(far) || !f.matches(byID) {
+		t.Error("Unexpected filter matches")
+	}
+
+	if _, err := parseWatchFilter("north", 2, ""); err == nil {
+		t.Error("Expected an error for an invalid -near")
+	}
+}
@@ -0,0 +1,35 @@
+// Command openapigen writes the Go code generated from an OpenAPI spec; see
+// package openapigen. It is run by go generate in the jdw package.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/KRoperUK/get_spoons/internal/openapigen"
+)
+
+func main() {
+	spec := flag.String("spec", "openapi.yaml", "OpenAPI spec to read")
+	out := flag.String("out", "models_gen.go", "Go file to write")
+	pkg := flag.String("package", "jdw", "Package name of the generated file")
+	flag.Parse()
+
+	if err := run(*spec, *out, *pkg); err != nil {
+		fmt.Fprintf(os.Stderr, "openapigen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(specPath, outPath, pkg string) error {
+	spec, err := os.ReadFile(specPath)
+	if err != nil {
+		return err
+	}
+	src, err := openapigen.Generate(spec, pkg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, src, 0o644)
+}
@@ -0,0 +1,458 @@
+// Package openapigen generates the jdw package's models and typed client
+// methods from openapi.yaml, so that the spec is the source of truth for the
+// Go API surface.
+//
+// Every object schema in components.schemas becomes a struct, except
+// response envelopes (objects with only "success" and "data" properties).
+// Inline object properties become their own types, named after the parent
+// type and property. Every GET operation with an operationId becomes a
+// method returning the envelope's data, plus a Context variant.
+//
+// Supported extensions:
+//   - x-go-trace-attr on a path parameter names the jdw attribute.Key constant
+//     recorded on the request's trace span.
+//   - x-omitempty on a property adds ",omitempty" to its JSON tag, for fields
+//     only some endpoints return.
+package openapigen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Header starts every generated file.
+const Header = "// Code generated by openapigen from openapi.yaml. DO NOT EDIT.\n"
+
+type schema struct {
+	Ref                  string    `yaml:"$ref"`
+	Type                 string    `yaml:"type"`
+	Format               string    `yaml:"format"`
+	Nullable             bool      `yaml:"nullable"`
+	Description          string    `yaml:"description"`
+	Properties           yaml.Node `yaml:"properties"`
+	Items                *schema   `yaml:"items"`
+	AdditionalProperties yaml.Node `yaml:"additionalProperties"`
+	OmitEmpty            bool      `yaml:"x-omitempty"`
+}
+
+type parameter struct {
+	Name      string  `yaml:"name"`
+	In        string  `yaml:"in"`
+	Schema    *schema `yaml:"schema"`
+	TraceAttr string  `yaml:"x-go-trace-attr"`
+}
+
+type operation struct {
+	OperationID string               `yaml:"operationId"`
+	Summary     string               `yaml:"summary"`
+	Description string               `yaml:"description"`
+	Parameters  []parameter          `yaml:"parameters"`
+	Responses   map[string]*response `yaml:"responses"`
+}
+
+type response struct {
+	Content map[string]struct {
+		Schema *schema `yaml:"schema"`
+	} `yaml:"content"`
+}
+
+type document struct {
+	Paths      yaml.Node `yaml:"paths"`
+	Components struct {
+		Schemas yaml.Node `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+// entry is a key and value of a YAML mapping, in document order.
+type entry struct {
+	Key   string
+	Value *yaml.Node
+}
+
+func entries(n *yaml.Node) []entry {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	var out []entry
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		out = append(out, entry{Key: n.Content[i].Value, Value: n.Content[i+1]})
+	}
+	return out
+}
+
+type generator struct {
+	schemas map[string]*schema
+	buf     bytes.Buffer
+	// pending holds inline object types still to be written.
+	pending []namedSchema
+}
+
+type namedSchema struct {
+	Name   string
+	Schema *schema
+}
+
+// Generate returns the gofmt-ed Go source for package pkg generated from the
+// OpenAPI document spec.
+func Generate(spec []byte, pkg string) ([]byte, error) {
+	var doc document
+	if err := yaml.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("parsing spec: %w", err)
+	}
+
+	g := &generator{schemas: make(map[string]*schema)}
+	var order []string
+	for _, e := range entries(&doc.Components.Schemas) {
+		var s schema
+		if err := e.Value.Decode(&s); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", e.Key, err)
+		}
+		g.schemas[e.Key] = &s
+		order = append(order, e.Key)
+	}
+
+	var methods bytes.Buffer
+	needFmt := false
+	for _, p := range entries(&doc.Paths) {
+		var ops map[string]*operation
+		if err := p.Value.Decode(&ops); err != nil {
+			return nil, fmt.Errorf("path %s: %w", p.Key, err)
+		}
+		op := ops["get"]
+		if op == nil || op.OperationID == "" {
+			continue
+		}
+		usesFmt, err := g.method(&methods, p.Key, op)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.OperationID, p.Key, err)
+		}
+		needFmt = needFmt || usesFmt
+	}
+
+	fmt.Fprintf(&g.buf, "%s\npackage %s\n\n", Header, pkg)
+	if methods.Len() > 0 {
+		g.buf.WriteString("import (\n\t\"context\"\n")
+		if needFmt {
+			g.buf.WriteString("\t\"fmt\"\n")
+		}
+		g.buf.WriteString(")\n\n")
+	}
+
+	for _, name := range order {
+		s := g.schemas[name]
+		if isEnvelope(s) || s.Type != "object" || len(entries(&s.Properties)) == 0 {
+			continue
+		}
+		if err := g.structType(name, s); err != nil {
+			return nil, err
+		}
+		for len(g.pending) > 0 {
+			next := g.pending[0]
+			g.pending = g.pending[1:]
+			if err := g.structType(next.Name, next.Schema); err != nil {
+				return nil, err
+			}
+		}
+	}
+	g.buf.Write(methods.Bytes())
+
+	out, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, g.buf.Bytes())
+	}
+	return out, nil
+}
+
+// isEnvelope reports whether s is a {"success": ..., "data": ...} response
+// wrapper, which the client unwraps itself.
+func isEnvelope(s *schema) bool {
+	props := entries(&s.Properties)
+	if len(props) != 2 {
+		return false
+	}
+	keys := []string{props[0].Key, props[1].Key}
+	sort.Strings(keys)
+	return keys[0] == "data" && keys[1] == "success"
+}
+
+func (g *generator) structType(name string, s *schema) error {
+	desc := strings.TrimSpace(s.Description)
+	if desc == "" {
+		desc = "is generated from the " + name + " schema."
+	} else {
+		desc = "is " + lowerFirst(desc)
+	}
+	writeComment(&g.buf, "", name+" "+desc)
+	fmt.Fprintf(&g.buf, "type %s struct {\n", name)
+	for _, p := range entries(&s.Properties) {
+		var ps schema
+		if err := p.Value.Decode(&ps); err != nil {
+			return fmt.Errorf("%s.%s: %w", name, p.Key, err)
+		}
+		typ, err := g.goType(&ps, name+exportName(p.Key))
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", name, p.Key, err)
+		}
+		if ps.Description != "" {
+			writeComment(&g.buf, "\t", strings.TrimSpace(ps.Description))
+		}
+		tag := p.Key
+		if ps.OmitEmpty {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&g.buf, "\t%s %s `json:\"%s\"`\n", exportName(p.Key), typ, tag)
+	}
+	g.buf.WriteString("}\n\n")
+	return nil
+}
+
+// goType returns the Go type for s. Inline objects with properties are
+// queued as new types named inlineName.
+func (g *generator) goType(s *schema, inlineName string) (string, error) {
+	if s.Ref != "" {
+		name, err := refName(s.Ref)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := g.schemas[name]; !ok {
+			return "", fmt.Errorf("unknown schema %s", s.Ref)
+		}
+		if s.Nullable {
+			return "*" + name, nil
+		}
+		return name, nil
+	}
+
+	var typ string
+	switch s.Type {
+	case "string":
+		typ = "string"
+	case "integer":
+		typ = "int"
+	case "number":
+		typ = "float64"
+	case "boolean":
+		typ = "bool"
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		elem, err := g.goType(s.Items, inlineName)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + elem, nil
+	case "object":
+		if len(entries(&s.Properties)) > 0 {
+			g.pending = append(g.pending, namedSchema{Name: inlineName, Schema: s})
+			typ = inlineName
+			break
+		}
+		elem := "interface{}"
+		if ap := &s.AdditionalProperties; ap.Kind == yaml.MappingNode && len(ap.Content) > 0 {
+			var as schema
+			if err := ap.Decode(&as); err != nil {
+				return "", err
+			}
+			var err error
+			if elem, err = g.goType(&as, inlineName+"Value"); err != nil {
+				return "", err
+			}
+		}
+		return "map[string]" + elem, nil
+	default:
+		return "", fmt.Errorf("unsupported type %q", s.Type)
+	}
+	if s.Nullable {
+		return "*" + typ, nil
+	}
+	return typ, nil
+}
+
+// method writes the methods for a GET operation and reports whether they use
+// fmt.
+func (g *generator) method(w *bytes.Buffer, path string, op *operation) (bool, error) {
+	name := exportName(op.OperationID)
+	result, err := g.resultType(op)
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		params   []string
+		args     []string
+		attrs    []string
+		fmtArgs  []string
+		usesFmt  bool
+		pathExpr = path
+	)
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "header":
+			continue // the client sets its own headers
+		case "path":
+		default:
+			return false, fmt.Errorf("unsupported %s parameter %s", p.In, p.Name)
+		}
+		if p.Schema == nil || p.Schema.Type != "integer" {
+			return false, fmt.Errorf("path parameter %s must be an integer", p.Name)
+		}
+		arg := lowerFirst(exportName(p.Name))
+		params = append(params, arg)
+		args = append(args, arg)
+		fmtArgs = append(fmtArgs, arg)
+		pathExpr = strings.Replace(pathExpr, "{"+p.Name+"}", "%d", 1)
+		if p.TraceAttr != "" {
+			attrs = append(attrs, fmt.Sprintf("%s.Int(%s)", p.TraceAttr, arg))
+		}
+	}
+	if strings.Contains(pathExpr, "{") {
+		return false, fmt.Errorf("path has undeclared parameters")
+	}
+
+	pathArg := fmt.Sprintf("%q", pathExpr)
+	if len(fmtArgs) > 0 {
+		pathArg = fmt.Sprintf("fmt.Sprintf(%q, %s)", pathExpr, strings.Join(fmtArgs, ", "))
+		usesFmt = true
+	}
+	var paramList string
+	if len(params) > 0 {
+		paramList = strings.Join(params, ", ") + " int"
+	}
+	ctxParams := "ctx context.Context"
+	if paramList != "" {
+		ctxParams += ", " + paramList
+	}
+	callArgs := append([]string{"context.Background()"}, args...)
+	extra := ""
+	if len(attrs) > 0 {
+		extra = ", " + strings.Join(attrs, ", ")
+	}
+
+	desc := strings.TrimSpace(op.Description)
+	if desc == "" {
+		desc = strings.TrimSpace(op.Summary) + "."
+	}
+	writeComment(w, "", name+" "+lowerFirst(desc))
+	fmt.Fprintf(w, "func (c *Client) %s(%s) (%s, error) {\n", name, paramList, result.ret)
+	fmt.Fprintf(w, "\treturn c.%sContext(%s)\n}\n\n", name, strings.Join(callArgs, ", "))
+
+	writeComment(w, "", fmt.Sprintf("%sContext is like %s but uses ctx for the request and as the parent of its trace span.", name, name))
+	fmt.Fprintf(w, "func (c *Client) %sContext(%s) (%s, error) {\n", name, ctxParams, result.ret)
+	fmt.Fprintf(w, "\tvar result %s\n", result.elem)
+	fmt.Fprintf(w, "\terr := c.doRequest(ctx, \"GET\", %s, nil, &result%s)\n", pathArg, extra)
+	if result.pointer {
+		w.WriteString("\treturn &result, err\n}\n\n")
+	} else {
+		w.WriteString("\treturn result, err\n}\n\n")
+	}
+	return usesFmt, nil
+}
+
+type resultType struct {
+	ret     string // the method's return type
+	elem    string // the type decoded into
+	pointer bool
+}
+
+// resultType returns the type of the data in an operation's 200 response
+// envelope.
+func (g *generator) resultType(op *operation) (resultType, error) {
+	resp := op.Responses["200"]
+	if resp == nil {
+		return resultType{}, fmt.Errorf("no 200 response")
+	}
+	media, ok := resp.Content["application/json"]
+	if !ok || media.Schema == nil || media.Schema.Ref == "" {
+		return resultType{}, fmt.Errorf("200 response must reference a schema")
+	}
+	name, err := refName(media.Schema.Ref)
+	if err != nil {
+		return resultType{}, err
+	}
+	env, ok := g.schemas[name]
+	if !ok || !isEnvelope(env) {
+		return resultType{}, fmt.Errorf("%s is not a response envelope", name)
+	}
+	for _, p := range entries(&env.Properties) {
+		if p.Key != "data" {
+			continue
+		}
+		var data schema
+		if err := p.Value.Decode(&data); err != nil {
+			return resultType{}, err
+		}
+		typ, err := g.goType(&data, name+"Data")
+		if err != nil {
+			return resultType{}, err
+		}
+		if data.Ref != "" && !strings.HasPrefix(typ, "*") {
+			return resultType{ret: "*" + typ, elem: typ, pointer: true}, nil
+		}
+		return resultType{ret: typ, elem: typ}, nil
+	}
+	return resultType{}, fmt.Errorf("%s has no data property", name)
+}
+
+func refName(ref string) (string, error) {
+	const prefix = "#/components/schemas/"
+	if !strings.HasPrefix(ref, prefix) {
+		return "", fmt.Errorf("unsupported $ref %q", ref)
+	}
+	return strings.TrimPrefix(ref, prefix), nil
+}
+
+// exportName turns a JSON property or operation name into an exported Go
+// identifier, following Go initialism conventions for "id" and "url".
+func exportName(s string) string {
+	if s == "" {
+		return s
+	}
+	switch strings.ToLower(s) {
+	case "id":
+		return "ID"
+	case "url":
+		return "URL"
+	}
+	s = strings.ToUpper(s[:1]) + s[1:]
+	for _, suffix := range []string{"Id", "Url"} {
+		if strings.HasSuffix(s, suffix) {
+			s = strings.TrimSuffix(s, suffix) + strings.ToUpper(suffix)
+		}
+	}
+	return s
+}
+
+// lowerFirst lowercases the first letter of s unless it starts an
+// initialism such as "ID".
+func lowerFirst(s string) string {
+	if s == "ID" {
+		return "id"
+	}
+	if len(s) > 1 && unicode.IsUpper(rune(s[1])) {
+		return s
+	}
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// writeComment writes text as a // comment wrapped at about 80 columns.
+func writeComment(w *bytes.Buffer, indent, text string) {
+	line := indent + "//"
+	for _, word := range strings.Fields(text) {
+		if len(line)+1+len(word) > 80 && line != indent+"//" {
+			w.WriteString(line + "\n")
+			line = indent + "//"
+		}
+		line += " " + word
+	}
+	w.WriteString(line + "\n")
+}
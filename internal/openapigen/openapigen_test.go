@@ -0,0 +1,107 @@
+package openapigen
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSpec = `
+openapi: 3.0.3
+paths:
+  /things/{thingId}:
+    get:
+      operationId: getThing
+      description: Fetches a thing.
+      parameters:
+        - name: thingId
+          in: path
+          schema:
+            type: integer
+          x-go-trace-attr: AttrThingID
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/ThingResponse"
+  /untyped:
+    get:
+      summary: Not generated without an operationId
+components:
+  schemas:
+    ThingResponse:
+      type: object
+      properties:
+        success:
+          type: boolean
+        data:
+          $ref: "#/components/schemas/Thing"
+    Thing:
+      description: A thing.
+      type: object
+      properties:
+        id:
+          type: integer
+        imageUrl:
+          type: string
+          nullable: true
+        tags:
+          type: array
+          items:
+            type: string
+          x-omitempty: true
+        size:
+          type: object
+          properties:
+            width:
+              type: number
+        extra:
+          type: object
+          additionalProperties:
+            type: integer
+`
+
+func TestGenerate(t *testing.T) {
+	src, err := Generate([]byte(testSpec), "things")
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	out := string(src)
+
+	for _, want := range []string{
+		Header,
+		"package things",
+		"// Thing is a thing.",
+		"ID       int            `json:\"id\"`",
+		"ImageURL *string        `json:\"imageUrl\"`",
+		"Tags     []string       `json:\"tags,omitempty\"`",
+		"Size     ThingSize      `json:\"size\"`",
+		"Extra    map[string]int `json:\"extra\"`",
+		"type ThingSize struct {",
+		"// GetThing fetches a thing.",
+		"func (c *Client) GetThing(thingID int) (*Thing, error) {",
+		`err := c.doRequest(ctx, "GET", fmt.Sprintf("/things/%d", thingID), nil, &result, AttrThingID.Int(thingID))`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected generated code to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "ThingResponse") {
+		t.Errorf("Expected response envelopes to be skipped, got:\n%s", out)
+	}
+	if strings.Contains(out, "Untyped") {
+		t.Errorf("Expected operations without an operationId to be skipped, got:\n%s", out)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	spec := strings.Replace(testSpec, "in: path", "in: query", 1)
+	if _, err := Generate([]byte(spec), "things"); err == nil || !strings.Contains(err.Error(), "unsupported query parameter") {
+		t.Errorf("Expected an unsupported parameter error, got %v", err)
+	}
+
+	spec = strings.Replace(testSpec, `$ref: "#/components/schemas/Thing"`, `$ref: "#/components/schemas/Missing"`, 1)
+	if _, err := Generate([]byte(spec), "things"); err == nil {
+		t.Error("Expected an error for an unknown $ref")
+	}
+}
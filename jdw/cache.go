@@ -0,0 +1,199 @@
+package jdw
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCacheMiss is returned in offline mode when a response is not in the cache.
+var ErrCacheMiss = errors.New("response not in cache")
+
+// Endpoint names used to configure per-endpoint cache TTLs.
+const (
+	EndpointVenues       = "venues"
+	EndpointVenueDetails = "venue_details"
+	EndpointMenus        = "menus"
+	EndpointMenuItems    = "menu_items"
+	EndpointSettings     = "settings"
+	EndpointBanners      = "banners"
+)
+
+var endpointPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{EndpointVenues, regexp.MustCompile(`^/api/v0\.1/venues$`)},
+	{EndpointVenueDetails, regexp.MustCompile(`^/api/v0\.1/jdw/venues/\d+$`)},
+	{EndpointMenus, regexp.MustCompile(`^/api/v0\.1/jdw/venues/\d+/sales-areas/\d+/menus$`)},
+	{EndpointMenuItems, regexp.MustCompile(`^/api/v0\.1/jdw/venues/\d+/sales-areas/\d+/menus/\d+$`)},
+	{EndpointSettings, regexp.MustCompile(`^/api/v0\.1/settings$`)},
+	{EndpointBanners, regexp.MustCompile(`^/api/v0\.1/content/promotional-banners$`)},
+}
+
+// EndpointForPath returns the endpoint name for an API path, or "" if unknown.
+func EndpointForPath(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	for _, e := range endpointPatterns {
+		if e.pattern.MatchString(path) {
+			return e.name
+		}
+	}
+	return ""
+}
+
+// CacheEntry is a cached API response body along with its validators.
+type CacheEntry struct {
+	Body         []byte
+	ETag         string
+	LastModified string
+	StoredAt     time.Time
+}
+
+// Cache stores raw API responses keyed by request URL.
+type Cache interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry) error
+}
+
+// CacheOptions controls how the client uses its cache.
+type CacheOptions struct {
+	// DefaultTTL is how long a cached response is served without revalidation.
+	DefaultTTL time.Duration
+	// TTLs overrides DefaultTTL per endpoint (see the Endpoint* constants).
+	TTLs map[string]time.Duration
+	// Offline serves responses only from the cache, regardless of age, and
+	// returns ErrCacheMiss instead of making a request.
+	Offline bool
+}
+
+func (o CacheOptions) ttlFor(path string) time.Duration {
+	if ttl, ok := o.TTLs[EndpointForPath(path)]; ok {
+		return ttl
+	}
+	return o.DefaultTTL
+}
+
+// SetCache enables response caching for GET requests. Passing a nil cache
+// disables caching.
+func (c *Client) SetCache(cache Cache, opts CacheOptions) {
+	c.cache = cache
+	c.cacheOpts = opts
+}
+
+// MemoryCache is an in-memory Cache safe for concurrent use.
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]*CacheEntry
+}
+
+// NewMemoryCache creates an empty in-memory cache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]*CacheEntry)}
+}
+
+// Get returns a copy of the entry stored under key.
+func (m *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	cp := *entry
+	return &cp, true
+}
+
+// Set stores a copy of entry under key.
+func (m *MemoryCache) Set(key string, entry *CacheEntry) error {
+	cp := *entry
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = &cp
+	return nil
+}
+
+// DiskCache is a Cache that stores one JSON file per entry in a directory.
+type DiskCache struct {
+	dir string
+}
+
+// diskEntry is the on-disk representation of a CacheEntry. The body is kept as
+// raw JSON so cache files stay human-readable.
+type diskEntry struct {
+	Key          string          `json:"key"`
+	Body         json.RawMessage `json:"body"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"lastModified,omitempty"`
+	StoredAt     time.Time       `json:"storedAt"`
+}
+
+// NewDiskCache creates a disk cache rooted at dir, creating it if necessary.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get reads the entry stored under key. Unreadable or corrupt files are
+// treated as misses.
+func (d *DiskCache) Get(key string) (*CacheEntry, bool) {
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var e diskEntry
+	if err := json.Unmarshal(data, &e); err != nil || e.Key != key {
+		return nil, false
+	}
+	return &CacheEntry{
+		Body:         e.Body,
+		ETag:         e.ETag,
+		LastModified: e.LastModified,
+		StoredAt:     e.StoredAt,
+	}, true
+}
+
+// Set writes entry to disk, replacing any existing file atomically.
+func (d *DiskCache) Set(key string, entry *CacheEntry) error {
+	data, err := json.Marshal(diskEntry{
+		Key:          key,
+		Body:         entry.Body,
+		ETag:         entry.ETag,
+		LastModified: entry.LastModified,
+		StoredAt:     entry.StoredAt,
+	})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), d.path(key))
+}
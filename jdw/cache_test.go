@@ -0,0 +1,151 @@
+package jdw
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEndpointForPath(t *testing.T) {
+	tests := map[string]string{
+		"/api/v0.1/venues":                               EndpointVenues,
+		"/api/v0.1/jdw/venues/123":                       EndpointVenueDetails,
+		"/api/v0.1/jdw/venues/123/sales-areas/4/menus":   EndpointMenus,
+		"/api/v0.1/jdw/venues/123/sales-areas/4/menus/5": EndpointMenuItems,
+		"/api/v0.1/settings":                             EndpointSettings,
+		"/api/v0.1/content/promotional-banners":          EndpointBanners,
+		"/api/v0.1/unknown":                              "",
+	}
+	for path, want := range tests {
+		if got := EndpointForPath(path); got != want {
+			t.Errorf("EndpointForPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestCaches(t *testing.T) {
+	disk, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDiskCache failed: %v", err)
+	}
+
+	for name, cache := range map[string]Cache{"Memory": NewMemoryCache(), "Disk": disk} {
+		t.Run(name, func(t *testing.T) {
+			if _, ok := cache.Get("missing"); ok {
+				t.Error("Expected miss for unknown key")
+			}
+
+			stored := time.Now().Truncate(time.Second)
+			err := cache.Set("key", &CacheEntry{Body: []byte(`{"success":true}`), ETag: `"abc"`, StoredAt: stored})
+			if err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+
+			entry, ok := cache.Get("key")
+			if !ok {
+				t.Fatal("Expected hit after Set")
+			}
+			if string(entry.Body) != `{"success":true}` || entry.ETag != `"abc"` || !entry.StoredAt.Equal(stored) {
+				t.Errorf("Unexpected entry: %+v", entry)
+			}
+		})
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"success": true, "data": [{"id": 1, "name": "Cached Venue"}]}`)
+	}))
+	defer server.Close()
+
+	t.Run("FreshEntryServedFromCache", func(t *testing.T) {
+		calls.Store(0)
+		client := NewClient("v", "t", "u")
+		client.SetBaseURL(server.URL)
+		client.SetCache(NewMemoryCache(), CacheOptions{DefaultTTL: time.Hour})
+
+		for i := 0; i < 3; i++ {
+			venues, err := client.GetVenues()
+			if err != nil || len(venues) != 1 || venues[0].Name != "Cached Venue" {
+				t.Fatalf("GetVenues failed: %v, %v", venues, err)
+			}
+		}
+		if got := calls.Load(); got != 1 {
+			t.Errorf("Expected 1 request, got %d", got)
+		}
+	})
+
+	t.Run("StaleEntryRevalidated", func(t *testing.T) {
+		calls.Store(0)
+		client := NewClient("v", "t", "u")
+		client.SetBaseURL(server.URL)
+		client.SetCache(NewMemoryCache(), CacheOptions{
+			DefaultTTL: time.Hour,
+			TTLs:       map[string]time.Duration{EndpointVenues: 0},
+		})
+
+		for i := 0; i < 2; i++ {
+			venues, err := client.GetVenues()
+			if err != nil || len(venues) != 1 {
+				t.Fatalf("GetVenues failed: %v, %v", venues, err)
+			}
+		}
+		if got := calls.Load(); got != 2 {
+			t.Errorf("Expected 2 requests (fetch + revalidate), got %d", got)
+		}
+	})
+
+	t.Run("Offline", func(t *testing.T) {
+		cache := NewMemoryCache()
+		client := NewClient("v", "t", "u")
+		client.SetBaseURL(server.URL)
+		client.SetCache(cache, CacheOptions{})
+		if _, err := client.GetVenues(); err != nil {
+			t.Fatalf("GetVenues failed: %v", err)
+		}
+
+		calls.Store(0)
+		client.SetCache(cache, CacheOptions{Offline: true})
+		if _, err := client.GetVenues(); err != nil {
+			t.Errorf("Expected stale entry to be served offline, got %v", err)
+		}
+		if _, err := client.GetSettings(); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("Expected ErrCacheMiss, got %v", err)
+		}
+		if got := calls.Load(); got != 0 {
+			t.Errorf("Expected no requests in offline mode, got %d", got)
+		}
+	})
+
+	t.Run("FailuresNotCached", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			fmt.Fprint(w, `{"success": false}`)
+		}))
+		defer server.Close()
+
+		client := NewClient("v", "t", "u")
+		client.SetBaseURL(server.URL)
+		client.SetCache(NewMemoryCache(), CacheOptions{DefaultTTL: time.Hour})
+		for i := 0; i < 2; i++ {
+			if _, err := client.GetVenues(); !errors.Is(err, ErrAPIFailure) {
+				t.Fatalf("Expected ErrAPIFailure, got %v", err)
+			}
+		}
+		if got := calls.Load(); got != 2 {
+			t.Errorf("Expected 2 requests, got %d", got)
+		}
+	})
+}
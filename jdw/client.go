@@ -1,14 +1,35 @@
 package jdw
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const DefaultBaseURL = "https://ca.jdw-apps.net"
 
+// ErrAPIFailure is returned when the API responds with "success": false.
+var ErrAPIFailure = errors.New("API response indicated failure")
+
+// APIError is returned when the API responds with a non-200 status code.
+type APIError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API returned status: %v", e.Status)
+}
+
 // Client is a JDW API client.
 type Client struct {
 	httpClient *http.Client
@@ -17,9 +38,19 @@ type Client struct {
 	token      string
 	userAgent  string
 	debug      bool
+	cache      Cache
+	cacheOpts  CacheOptions
+	observer   RequestObserver
+	logger     *slog.Logger
+	tracers    trace.TracerProvider
+	middleware []Middleware
+	validator  *SchemaValidator
+	// sender is httpClient with its transport wrapped in the middleware.
+	sender *http.Client
 }
 
-// SetDebug enables or disables debug logging for the client.
+// SetDebug enables or disables debug logging to stderr. It has no effect
+// once a logger is set with SetLogger or WithLogger.
 func (c *Client) SetDebug(debug bool) {
 	c.debug = debug
 }
@@ -32,54 +63,182 @@ func (c *Client) SetBaseURL(url string) {
 // GetVenueDetails fetches the full details for a specific venue by ID and returns it as a raw map.
 // This is useful for retrieving fields that are not defined in the Venue struct.
 func (c *Client) GetVenueDetails(id int) (map[string]interface{}, error) {
+	return c.GetVenueDetailsContext(context.Background(), id)
+}
+
+// GetVenueDetailsContext is like GetVenueDetails but uses ctx for the request
+// and as the parent of its trace span.
+func (c *Client) GetVenueDetailsContext(ctx context.Context, id int) (map[string]interface{}, error) {
 	var result map[string]interface{}
-	err := c.doRequest("GET", fmt.Sprintf("/api/v0.1/jdw/venues/%d", id), nil, &result)
+	err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v0.1/jdw/venues/%d", id), nil, &result, AttrVenueRef.Int(id))
 	return result, err
 }
 
-// GetMenus fetches the menus for a specific venue and sales area.
+// GetMenus fetches the menus for a specific venue and sales area as raw JSON,
+// including fields not in the spec. GetSalesAreaMenus returns them typed.
 func (c *Client) GetMenus(venueID, salesAreaID int) ([]interface{}, error) {
+	return c.GetMenusContext(context.Background(), venueID, salesAreaID)
+}
+
+// GetMenusContext is like GetMenus but uses ctx for the request and as the
+// parent of its trace span.
+func (c *Client) GetMenusContext(ctx context.Context, venueID, salesAreaID int) ([]interface{}, error) {
 	var result []interface{}
-	err := c.doRequest("GET", fmt.Sprintf("/api/v0.1/jdw/venues/%d/sales-areas/%d/menus", venueID, salesAreaID), nil, &result)
+	err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v0.1/jdw/venues/%d/sales-areas/%d/menus", venueID, salesAreaID), nil, &result,
+		AttrVenueRef.Int(venueID), AttrSalesAreaID.Int(salesAreaID))
 	return result, err
 }
 
-// GetMenuItems fetches the details (items, sections) for a specific menu.
+// GetMenuItems fetches the details (items, sections) for a specific menu as
+// raw JSON, including fields not in the spec. GetMenu returns them typed.
 func (c *Client) GetMenuItems(venueID, salesAreaID, menuID int) (map[string]interface{}, error) {
+	return c.GetMenuItemsContext(context.Background(), venueID, salesAreaID, menuID)
+}
+
+// GetMenuItemsContext is like GetMenuItems but uses ctx for the request and
+// as the parent of its trace span.
+func (c *Client) GetMenuItemsContext(ctx context.Context, venueID, salesAreaID, menuID int) (map[string]interface{}, error) {
 	var result map[string]interface{}
-	err := c.doRequest("GET", fmt.Sprintf("/api/v0.1/jdw/venues/%d/sales-areas/%d/menus/%d", venueID, salesAreaID, menuID), nil, &result)
+	err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v0.1/jdw/venues/%d/sales-areas/%d/menus/%d", venueID, salesAreaID, menuID), nil, &result,
+		AttrVenueRef.Int(venueID), AttrSalesAreaID.Int(salesAreaID), AttrMenuID.Int(menuID))
 	return result, err
 }
 
 // NewClient creates a new JDW API client.
-func NewClient(appVersion, token, userAgent string) *Client {
-	return &Client{
+func NewClient(appVersion, token, userAgent string, opts ...Option) *Client {
+	c := &Client{
 		httpClient: &http.Client{},
 		baseURL:    DefaultBaseURL,
 		appVersion: appVersion,
 		token:      token,
 		userAgent:  userAgent,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.buildChain()
+	return c
+}
+
+// doRequest performs an API call, tracing, logging and reporting it to the
+// observer. attrs are added to its trace span.
+func (c *Client) doRequest(ctx context.Context, method, path string, body io.Reader, result any, attrs ...attribute.KeyValue) error {
+	log := c.log()
+	info := RequestInfo{ID: newRequestID(), Method: method, Path: path, Endpoint: EndpointForPath(path)}
+	ctx, span := c.startSpan(ctx, &info, attrs)
+	c.logRequest(log, &info)
+
+	start := time.Now()
+	info.Err = c.do(ctx, method, path, body, result, &info)
+	info.Duration = time.Since(start)
+
+	endSpan(span, &info)
+	logResponse(log, &info)
+	if c.observer != nil {
+		c.observer(info)
+	}
+	return info.Err
+}
+
+// do performs the request, recording the response status in info.
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, result any, info *RequestInfo) error {
+	if c.cache != nil && method == http.MethodGet {
+		return c.doCachedRequest(ctx, path, result, info)
+	}
+
+	resp, respBody, err := c.send(ctx, method, path, body, nil)
+	if err != nil {
+		return err
+	}
+	info.StatusCode = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	c.validate(method, path, respBody, info)
+	return decodeResponse(respBody, result)
 }
 
-func (c *Client) doRequest(method, path string, body io.Reader, result any) (err error) {
-	req, err := http.NewRequest(method, c.baseURL+path, body)
+// doCachedRequest serves a GET request from the cache when the entry is fresh,
+// revalidates stale entries with the server's validators, and stores
+// successful responses.
+func (c *Client) doCachedRequest(ctx context.Context, path string, result any, info *RequestInfo) error {
+	key := c.baseURL + path
+	entry, found := c.cache.Get(key)
+
+	if found && (c.cacheOpts.Offline || time.Since(entry.StoredAt) < c.cacheOpts.ttlFor(path)) {
+		info.Cached = true
+		return decodeResponse(entry.Body, result)
+	}
+	if c.cacheOpts.Offline {
+		return fmt.Errorf("%w: %s", ErrCacheMiss, path)
+	}
+
+	header := http.Header{}
+	if found {
+		if entry.ETag != "" {
+			header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, respBody, err := c.send(ctx, http.MethodGet, path, nil, header)
 	if err != nil {
 		return err
 	}
+	info.StatusCode = resp.StatusCode
+
+	if resp.StatusCode == http.StatusNotModified && found {
+		entry.StoredAt = time.Now()
+		c.storeCacheEntry(key, entry)
+		return decodeResponse(entry.Body, result)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	c.validate(http.MethodGet, path, respBody, info)
+	// Only successful, well-formed responses are cached.
+	if err := decodeResponse(respBody, result); err != nil {
+		return err
+	}
+	c.storeCacheEntry(key, &CacheEntry{
+		Body:         respBody,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		StoredAt:     time.Now(),
+	})
+	return nil
+}
 
-	if c.debug {
-		fmt.Printf("DEBUG: %s %s\n", method, c.baseURL+path)
+// storeCacheEntry writes to the cache. A failed write only costs a future
+// cache miss, so it is logged rather than returned.
+func (c *Client) storeCacheEntry(key string, entry *CacheEntry) {
+	if err := c.cache.Set(key, entry); err != nil {
+		c.log().Warn("jdw cache write failed", "key", key, "error", err)
+	}
+}
+
+// send performs an HTTP request with the standard JDW headers plus any extra
+// headers, and returns the response along with its fully-read body.
+func (c *Client) send(ctx context.Context, method, path string, body io.Reader, header http.Header) (resp *http.Response, respBody []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	req.Header.Set("App-Version", c.appVersion)
 	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Accept", "application/json, text/plain, */*")
 	req.Header.Set("User-Agent", c.userAgent)
+	for k, v := range header {
+		req.Header[k] = v
+	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err = c.sender.Do(req)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer func() {
 		if cerr := resp.Body.Close(); cerr != nil && err == nil {
@@ -87,15 +246,29 @@ func (c *Client) doRequest(method, path string, body io.Reader, result any) (err
 		}
 	}()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API returned status: %v", resp.Status)
+	respBody, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
 	}
+	return resp, respBody, nil
+}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
+// MaskToken hides all but the first and last four characters of a token, or
+// all of it when it is too short for that to be safe. It is used wherever a
+// token may be printed, such as debug output.
+func MaskToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	if len(token) < 16 {
+		return strings.Repeat("*", 8)
 	}
+	return token[:4] + strings.Repeat("*", 8) + token[len(token)-4:]
+}
 
+// decodeResponse unwraps the standard {"success": ..., "data": ...} envelope
+// into result.
+func decodeResponse(respBody []byte, result any) error {
 	// We use a generic response wrapper to unmarshal accurately
 	wrapper := struct {
 		Success bool            `json:"success"`
@@ -107,7 +280,7 @@ func (c *Client) doRequest(method, path string, body io.Reader, result any) (err
 	}
 
 	if !wrapper.Success {
-		return fmt.Errorf("API response indicated failure")
+		return ErrAPIFailure
 	}
 
 	return json.Unmarshal(wrapper.Data, result)
@@ -1,10 +1,15 @@
 package jdw
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestGetVenues(t *testing.T) {
@@ -259,6 +264,10 @@ func TestDoRequestErrors(t *testing.T) {
 		if err == nil || err.Error() != "API returned status: 403 Forbidden" {
 			t.Errorf("Expected 403 error, got %v", err)
 		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected *APIError with status 403, got %#v", err)
+		}
 	})
 
 	t.Run("SuccessFalse", func(t *testing.T) {
@@ -270,7 +279,7 @@ func TestDoRequestErrors(t *testing.T) {
 		client := NewClient("v", "t", "u")
 		client.baseURL = server.URL
 		_, err := client.GetVenues()
-		if err == nil || err.Error() != "API response indicated failure" {
+		if !errors.Is(err, ErrAPIFailure) {
 			t.Errorf("Expected failure error, got %v", err)
 		}
 	})
@@ -289,3 +298,98 @@ func TestDoRequestErrors(t *testing.T) {
 		}
 	})
 }
+
+func TestRequestObserver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v0.1/settings" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"success": true, "data": []}`)
+	}))
+	defer server.Close()
+
+	client := NewClient("1.2.3", "test-token", "test-ua")
+	client.baseURL = server.URL
+	client.SetCache(NewMemoryCache(), CacheOptions{DefaultTTL: time.Hour})
+
+	var seen []RequestInfo
+	client.SetRequestObserver(func(info RequestInfo) {
+		seen = append(seen, info)
+	})
+
+	if _, err := client.GetVenues(); err != nil {
+		t.Fatalf("GetVenues failed: %v", err)
+	}
+	if _, err := client.GetVenues(); err != nil {
+		t.Fatalf("GetVenues failed: %v", err)
+	}
+	if _, err := client.GetSettings(); err == nil {
+		t.Fatal("Expected GetSettings to fail")
+	}
+
+	if len(seen) != 3 {
+		t.Fatalf("Expected 3 observed requests, got %d", len(seen))
+	}
+	if seen[0].Endpoint != EndpointVenues || seen[0].StatusCode != http.StatusOK || seen[0].Cached || seen[0].Err != nil {
+		t.Errorf("Unexpected first request: %+v", seen[0])
+	}
+	if !seen[1].Cached || seen[1].StatusCode != 0 {
+		t.Errorf("Expected second request to be a cache hit, got %+v", seen[1])
+	}
+	if seen[2].Endpoint != EndpointSettings || seen[2].StatusCode != http.StatusServiceUnavailable || seen[2].Err == nil {
+		t.Errorf("Unexpected third request: %+v", seen[2])
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success": true, "data": []}`)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := NewClient("1.2.3", "1|abcdefghijklmnopqrstuvwxyz", "test-ua",
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	client.baseURL = server.URL
+	if _, err := client.GetVenues(); err != nil {
+		t.Fatalf("GetVenues failed: %v", err)
+	}
+
+	var lines []map[string]interface{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var line map[string]interface{}
+		if err := dec.Decode(&line); err != nil {
+			t.Fatalf("Invalid log line: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log lines, got %d: %s", len(lines), buf.String())
+	}
+	if lines[0]["msg"] != "jdw request" || lines[0]["authorization"] != "Bearer 1|ab********wxyz" {
+		t.Errorf("Unexpected request line: %v", lines[0])
+	}
+	if lines[1]["msg"] != "jdw response" || lines[1]["status"] != float64(http.StatusOK) || lines[1]["endpoint"] != EndpointVenues {
+		t.Errorf("Unexpected response line: %v", lines[1])
+	}
+	if _, ok := lines[1]["duration"]; !ok {
+		t.Errorf("Expected a duration, got %v", lines[1])
+	}
+	if id := lines[0]["request_id"]; id == nil || id == "" || id != lines[1]["request_id"] {
+		t.Errorf("Expected matching request IDs, got %v and %v", id, lines[1]["request_id"])
+	}
+}
+
+func TestMaskToken(t *testing.T) {
+	if got := MaskToken("1|abcdefghijklmnopqrstuvwxyz0123456789"); got != "1|ab********6789" {
+		t.Errorf("Unexpected mask: %s", got)
+	}
+	if got := MaskToken("short"); got != "********" {
+		t.Errorf("Unexpected mask: %s", got)
+	}
+	if got := MaskToken(""); got != "" {
+		t.Errorf("Expected empty mask, got %s", got)
+	}
+}
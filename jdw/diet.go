@@ -0,0 +1,272 @@
+package jdw
+
+import (
+	"sort"
+	"strings"
+)
+
+// Diets an item can be marked as suitable for.
+const (
+	DietVegan      = "vegan"
+	DietVegetarian = "vegetarian"
+	DietGlutenFree = "gluten-free"
+)
+
+// Diets lists the diets DietaryInfo records, in display order.
+var Diets = []string{DietVegan, DietVegetarian, DietGlutenFree}
+
+// DietaryInfo is the dietary and allergen information of a menu item. The
+// API has no fields for it; menus carry it in item descriptions, as in
+// "Plant-based patty. Vegan. Contains: gluten, soya.", and sometimes as
+// markers such as "(VG)" in item names.
+type DietaryInfo struct {
+	Vegan      bool `json:"vegan"`
+	Vegetarian bool `json:"vegetarian"`
+	GlutenFree bool `json:"glutenFree"`
+	// Allergens are the allergens the item contains, and MayContain those
+	// it may contain traces of, by the names in CanonicalAllergen.
+	Allergens  []string `json:"allergens,omitempty"`
+	MayContain []string `json:"mayContain,omitempty"`
+}
+
+// DietaryInfo extracts the item's dietary and allergen information.
+func (it Item) DietaryInfo() DietaryInfo {
+	return ParseDietaryInfo(it.Name, it.Description)
+}
+
+// dietLabels maps the labels and name markers that mark an item as suitable
+// for a diet.
+var dietLabels = map[string]string{
+	"vegan":                    DietVegan,
+	"vg":                       DietVegan,
+	"ve":                       DietVegan,
+	"suitable for vegans":      DietVegan,
+	"vegetarian":               DietVegetarian,
+	"v":                        DietVegetarian,
+	"suitable for vegetarians": DietVegetarian,
+	"gluten-free":              DietGlutenFree,
+	"gluten free":              DietGlutenFree,
+	"gf":                       DietGlutenFree,
+	"suitable for coeliacs":    DietGlutenFree,
+}
+
+// Allergens are the names DietaryInfo uses for the 14 major allergens that
+// UK menus must declare.
+var Allergens = []string{
+	"celery", "crustaceans", "egg", "fish", "gluten", "lupin", "milk",
+	"molluscs", "mustard", "nuts", "peanuts", "sesame", "soya", "sulphites",
+}
+
+// allergenAliases maps other names of the 14 major allergens, including
+// the individual tree nuts, to the names used in DietaryInfo.
+var allergenAliases = map[string]string{
+	"cereals containing gluten": "gluten",
+	"wheat":                     "gluten",
+	"eggs":                      "egg",
+	"dairy":                     "milk",
+	"lactose":                   "milk",
+	"soy":                       "soya",
+	"soybeans":                  "soya",
+	"soya beans":                "soya",
+	"peanut":                    "peanuts",
+	"groundnuts":                "peanuts",
+	"nut":                       "nuts",
+	"tree nuts":                 "nuts",
+	"tree nut":                  "nuts",
+	"almond":                    "nuts",
+	"almonds":                   "nuts",
+	"hazelnut":                  "nuts",
+	"hazelnuts":                 "nuts",
+	"walnut":                    "nuts",
+	"walnuts":                   "nuts",
+	"cashew":                    "nuts",
+	"cashews":                   "nuts",
+	"pecan":                     "nuts",
+	"pecans":                    "nuts",
+	"pistachio":                 "nuts",
+	"pistachios":                "nuts",
+	"brazil nut":                "nuts",
+	"brazil nuts":               "nuts",
+	"macadamia":                 "nuts",
+	"macadamia nuts":            "nuts",
+	"queensland nuts":           "nuts",
+	"crustacean":                "crustaceans",
+	"shellfish":                 "crustaceans",
+	"mollusc":                   "molluscs",
+	"sulphur dioxide":           "sulphites",
+	"sulfites":                  "sulphites",
+	"sulphite":                  "sulphites",
+	"celeriac":                  "celery",
+	"lupine":                    "lupin",
+	"sesame seeds":              "sesame",
+}
+
+// CanonicalAllergen returns the name DietaryInfo uses for an allergen, such
+// as "peanuts" for "Peanut" or "milk" for "dairy". Unknown allergens are
+// returned lower-cased.
+func CanonicalAllergen(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if canonical, ok := allergenAliases[name]; ok {
+		return canonical
+	}
+	return name
+}
+
+// LookupAllergen returns the name DietaryInfo uses for one of the 14 major
+// allergens, given it or one of its aliases, and false for any other name.
+func LookupAllergen(name string) (string, bool) {
+	canonical := CanonicalAllergen(name)
+	for _, a := range Allergens {
+		if a == canonical {
+			return canonical, true
+		}
+	}
+	return "", false
+}
+
+// CanonicalDiet returns the diet, one of Diets, that a label such as "vg"
+// or "Gluten free" stands for.
+func CanonicalDiet(label string) (string, bool) {
+	diet, ok := dietLabels[strings.ToLower(strings.TrimSpace(label))]
+	return diet, ok
+}
+
+// ParseDietaryInfo extracts dietary and allergen information from an
+// item's name and description. A diet is recognised from a sentence of
+// labels ("Vegan, gluten-free."), a marker in the name ("Chilli (VG)") or
+// the diet named in the name ("Vegan Burger"), but not from a mention such
+// as "with vegan cheese". Allergens are read from "Contains:" and "May
+// contain:" lists. Vegan items are also vegetarian, and an item containing
+// gluten is never gluten-free.
+func ParseDietaryInfo(name, description string) DietaryInfo {
+	diets := make(map[string]bool)
+	var info DietaryInfo
+
+	for _, marker := range nameMarkers(name) {
+		diets[dietLabels[marker]] = true
+	}
+	for _, word := range strings.Fields(strings.ToLower(name)) {
+		if word == DietVegan || word == DietVegetarian {
+			diets[word] = true
+		}
+	}
+
+	for _, sentence := range strings.Split(description, ".") {
+		sentence = strings.TrimSpace(sentence)
+		lower := strings.ToLower(sentence)
+		switch {
+		case strings.HasPrefix(lower, "contains:"):
+			info.Allergens = appendAllergens(info.Allergens, sentence[len("contains:"):])
+		case strings.HasPrefix(lower, "may contain:"):
+			info.MayContain = appendAllergens(info.MayContain, sentence[len("may contain:"):])
+		default:
+			labels := splitList(lower)
+			var found []string
+			for _, label := range labels {
+				diet, ok := dietLabels[label]
+				if !ok {
+					found = nil
+					break
+				}
+				found = append(found, diet)
+			}
+			for _, diet := range found {
+				diets[diet] = true
+			}
+		}
+	}
+
+	info.Vegan = diets[DietVegan]
+	info.Vegetarian = diets[DietVegetarian] || info.Vegan
+	info.GlutenFree = diets[DietGlutenFree] && !info.Contains("gluten")
+	return info
+}
+
+// nameMarkers returns the lower-cased diet markers in parentheses in an
+// item name, such as "vg" and "gf" in "Chilli (VG, GF)".
+func nameMarkers(name string) []string {
+	var markers []string
+	for rest := name; ; {
+		open := strings.Index(rest, "(")
+		if open < 0 {
+			return markers
+		}
+		end := strings.Index(rest[open:], ")")
+		if end < 0 {
+			return markers
+		}
+		for _, m := range splitList(strings.ToLower(rest[open+1 : open+end])) {
+			if _, ok := dietLabels[m]; ok {
+				markers = append(markers, m)
+			}
+		}
+		rest = rest[open+end+1:]
+	}
+}
+
+// splitList splits a list such as "gluten, milk and egg" into its trimmed
+// elements.
+func splitList(s string) []string {
+	s = strings.ReplaceAll(s, " and ", ",")
+	s = strings.ReplaceAll(s, "/", ",")
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// appendAllergens adds the allergens in a "Contains:" list to list, sorted
+// and without duplicates.
+func appendAllergens(list []string, s string) []string {
+	for _, a := range splitList(s) {
+		a = CanonicalAllergen(a)
+		if i := sort.SearchStrings(list, a); i == len(list) || list[i] != a {
+			list = append(list, "")
+			copy(list[i+1:], list[i:])
+			list[i] = a
+		}
+	}
+	return list
+}
+
+// Contains reports whether the item contains allergen, by any of its names.
+func (d DietaryInfo) Contains(allergen string) bool {
+	allergen = CanonicalAllergen(allergen)
+	for _, a := range d.Allergens {
+		if a == allergen {
+			return true
+		}
+	}
+	return false
+}
+
+// MayContainAllergen reports whether the item contains, or may contain
+// traces of, allergen.
+func (d DietaryInfo) MayContainAllergen(allergen string) bool {
+	if d.Contains(allergen) {
+		return true
+	}
+	allergen = CanonicalAllergen(allergen)
+	for _, a := range d.MayContain {
+		if a == allergen {
+			return true
+		}
+	}
+	return false
+}
+
+// Suits reports whether the item is suitable for diet, one of Diets.
+func (d DietaryInfo) Suits(diet string) bool {
+	switch diet {
+	case DietVegan:
+		return d.Vegan
+	case DietVegetarian:
+		return d.Vegetarian
+	case DietGlutenFree:
+		return d.GlutenFree
+	}
+	return false
+}
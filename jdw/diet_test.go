@@ -0,0 +1,86 @@
+package jdw
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDietaryInfo(t *testing.T) {
+	tests := []struct {
+		name, description string
+		want              DietaryInfo
+	}{
+		{
+			"Classic Beef Burger", "6oz beef patty in a brioche bun. Contains: gluten, milk, egg, sesame.",
+			DietaryInfo{Allergens: []string{"egg", "gluten", "milk", "sesame"}},
+		},
+		{
+			"Vegan Moving Mountains Burger", "Plant-based patty with vegan cheese. Vegan. Contains: gluten, soya.",
+			DietaryInfo{Vegan: true, Vegetarian: true, Allergens: []string{"gluten", "soya"}},
+		},
+		{
+			"Superfood Salad", "Quinoa, edamame, avocado and mixed leaves. Vegan, gluten-free.",
+			DietaryInfo{Vegan: true, Vegetarian: true, GlutenFree: true},
+		},
+		{
+			"Halloumi Wrap (V)", "Contains: Wheat, Dairy and Eggs. May contain: peanut, tree nuts.",
+			DietaryInfo{Vegetarian: true, Allergens: []string{"egg", "gluten", "milk"}, MayContain: []string{"nuts", "peanuts"}},
+		},
+		{
+			"Chilli (VG, GF)", "Suitable for vegans",
+			DietaryInfo{Vegan: true, Vegetarian: true, GlutenFree: true},
+		},
+		// A mention of a diet is not a label.
+		{"Cheeseburger", "With vegan cheese and gluten-free bun", DietaryInfo{}},
+		// Containing gluten overrides a gluten-free label.
+		{"Pasta", "Gluten free. Contains: gluten.", DietaryInfo{Allergens: []string{"gluten"}}},
+		{"Pepsi Max", "", DietaryInfo{}},
+	}
+	for _, tt := range tests {
+		if got := ParseDietaryInfo(tt.name, tt.description); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestDietaryInfoQueries(t *testing.T) {
+	info := ParseDietaryInfo("Satay Bowl", "Contains: peanuts, soy. May contain: sesame seeds.")
+	if !info.Contains("Peanut") || !info.Contains("soya") || info.Contains("sesame") {
+		t.Errorf("Unexpected Contains results for %+v", info)
+	}
+	if !info.MayContainAllergen("sesame") || info.MayContainAllergen("milk") {
+		t.Errorf("Unexpected MayContainAllergen results for %+v", info)
+	}
+	if info.Suits(DietVegan) || info.Suits("keto") {
+		t.Errorf("Expected no diets for %+v", info)
+	}
+
+	for label, want := range map[string]string{"VG": DietVegan, "Gluten free": DietGlutenFree, "vegetarian": DietVegetarian} {
+		if got, ok := CanonicalDiet(label); !ok || got != want {
+			t.Errorf("CanonicalDiet(%q): expected %q, got %q", label, want, got)
+		}
+	}
+	if _, ok := CanonicalDiet("keto"); ok {
+		t.Error("Expected keto not to be a known diet")
+	}
+
+	for _, name := range []string{"Almonds", "hazelnuts", "walnut", "Cashews", "pecans", "pistachios", "tree nuts"} {
+		if got, ok := LookupAllergen(name); !ok || got != "nuts" {
+			t.Errorf("LookupAllergen(%q): expected nuts, got %q", name, got)
+		}
+	}
+	if got, ok := LookupAllergen("Dairy"); !ok || got != "milk" {
+		t.Errorf("LookupAllergen(Dairy): expected milk, got %q", got)
+	}
+	if _, ok := LookupAllergen("peanutz"); ok {
+		t.Error("Expected peanutz not to be a known allergen")
+	}
+	if info := ParseDietaryInfo("Bakewell Tart", "Contains: almonds, gluten."); !info.Contains("nuts") {
+		t.Errorf("Expected almonds to count as nuts, got %+v", info)
+	}
+
+	item := Item{Name: "Superfood Salad", Description: "Vegan, gluten-free."}
+	if !item.DietaryInfo().Suits(DietGlutenFree) {
+		t.Error("Expected Item.DietaryInfo to parse the description")
+	}
+}
@@ -0,0 +1,211 @@
+package jdw
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Volumes in millilitres of the measures drinks are served in. A UK pint is
+// 568ml, and a schooner two thirds of one.
+const (
+	PintML       = 568
+	HalfPintML   = 284
+	ThirdPintML  = 189
+	SchoonerML   = 379
+	SingleShotML = 25
+	DoubleShotML = 50
+)
+
+var (
+	// metricVolumePattern matches explicit volumes such as "330ml", "50cl"
+	// and "0.7 litre".
+	metricVolumePattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(ml|cl|litres?|liters?|l)\b`)
+	// metricVolumeMentionPattern matches an explicit volume and the word
+	// after it, as in "330ml bottle".
+	metricVolumeMentionPattern = regexp.MustCompile(`(\d+(?:\.\d+)?\s*(?:ml|cl|litres?|liters?|l)\b)(?:\s+([a-z]+))?`)
+	// pintPattern matches pints and fractions of one, such as "Pint",
+	// "2 pints", "half pint" and "2/3 pint".
+	pintPattern = regexp.MustCompile(`(?:(\d+(?:\.\d+)?|\d/\d|half|third|two[- ]thirds?)\s+(?:of\s+)?(?:a\s+)?)?pints?\b`)
+	// abvPattern matches strengths such as "4.6% ABV", "40% vol" and
+	// "ABV 5%".
+	abvPattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*%\s*(?:abv|vol|alc)\b|\babv:?\s*(\d+(?:\.\d+)?)\s*%`)
+)
+
+// namedMeasures are portion labels that stand for a volume without giving
+// it, longest first.
+var namedMeasures = []struct {
+	name string
+	ml   float64
+}{
+	{"two thirds", SchoonerML},
+	{"two-thirds", SchoonerML},
+	{"2/3", SchoonerML},
+	{"schooner", SchoonerML},
+	{"small glass", 125},
+	{"medium glass", 175},
+	{"large glass", 250},
+	{"half", HalfPintML},
+	{"third", ThirdPintML},
+	{"single", SingleShotML},
+	{"double", DoubleShotML},
+}
+
+// ParseVolume returns the volume in millilitres that text, such as a
+// portion label, gives: an explicit volume ("330ml bottle", "50cl"), a
+// number or fraction of pints ("Pint", "half pint") or a standard measure
+// ("Half", "Schooner", "Large glass", "Double").
+func ParseVolume(text string) (float64, bool) {
+	text = strings.ToLower(text)
+	if m := metricVolumePattern.FindStringSubmatch(text); m != nil {
+		n, _ := strconv.ParseFloat(m[1], 64)
+		switch m[2] {
+		case "ml":
+		case "cl":
+			n *= 10
+		default:
+			n *= 1000
+		}
+		return n, n > 0
+	}
+	if m := pintPattern.FindStringSubmatch(text); m != nil {
+		return pints(m[1]) * PintML, true
+	}
+	padded := " " + strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return r == ' ' || r == '(' || r == ')' || r == ','
+	}), " ") + " "
+	for _, m := range namedMeasures {
+		if strings.Contains(padded, " "+m.name+" ") {
+			return m.ml, true
+		}
+	}
+	return 0, false
+}
+
+// pints returns the number of pints a pintPattern quantity stands for.
+func pints(quantity string) float64 {
+	switch quantity {
+	case "":
+		return 1
+	case "half", "1/2":
+		return 0.5
+	case "third", "1/3":
+		return 1.0 / 3
+	case "two thirds", "two-thirds", "two third", "two-third", "2/3":
+		return 2.0 / 3
+	}
+	if n, err := strconv.ParseFloat(quantity, 64); err == nil {
+		return n
+	}
+	return 1
+}
+
+// PortionVolume returns the volume of a portion from its label or, when the
+// label doesn't give one, the item's description, as in "Bottle" of a beer
+// described as "330ml bottle". A description stating several volumes gives
+// the one followed by a word of the label, so "Bottle" of a wine described
+// as "175ml glass or 750ml bottle" is 750ml, and otherwise none. Several
+// pints, as in "2 pints for £8", are taken to be an offer, not a volume.
+func PortionVolume(label, description string) (float64, bool) {
+	if ml, ok := ParseVolume(label); ok {
+		return ml, true
+	}
+	mentions := volumeMentions(description)
+	words := strings.Fields(strings.ToLower(label))
+	var matched []volumeMention
+	for _, m := range mentions {
+		for _, w := range words {
+			if m.noun != "" && strings.TrimSuffix(m.noun, "s") == strings.TrimSuffix(w, "s") {
+				matched = append(matched, m)
+				break
+			}
+		}
+	}
+	if ml, ok := singleVolume(matched); ok {
+		return ml, true
+	}
+	return singleVolume(mentions)
+}
+
+// volumeMention is a volume stated in a description, with the word after it
+// ("bottle" in "330ml bottle").
+type volumeMention struct {
+	ml   float64
+	noun string
+}
+
+// volumeMentions returns the volumes stated in text, leaving out multiples
+// of a pint.
+func volumeMentions(text string) []volumeMention {
+	text = strings.ToLower(text)
+	var out []volumeMention
+	for _, m := range metricVolumeMentionPattern.FindAllStringSubmatch(text, -1) {
+		if ml, ok := ParseVolume(m[1]); ok {
+			out = append(out, volumeMention{ml: ml, noun: m[2]})
+		}
+	}
+	for _, m := range pintPattern.FindAllStringSubmatch(text, -1) {
+		if n := pints(m[1]); n <= 1 {
+			out = append(out, volumeMention{ml: n * PintML})
+		}
+	}
+	return out
+}
+
+// singleVolume returns the volume of mentions if they all state the same
+// one.
+func singleVolume(mentions []volumeMention) (float64, bool) {
+	if len(mentions) == 0 {
+		return 0, false
+	}
+	for _, m := range mentions[1:] {
+		if m.ml != mentions[0].ml {
+			return 0, false
+		}
+	}
+	return mentions[0].ml, true
+}
+
+// ParseABV returns the alcohol by volume, as a percentage, stated in text
+// such as "Premium lager, 4.6% ABV". Percentages without "ABV", "vol" or
+// "alc" are not taken to be strengths.
+func ParseABV(text string) (float64, bool) {
+	m := abvPattern.FindStringSubmatch(strings.ToLower(text))
+	if m == nil {
+		return 0, false
+	}
+	s := m[1]
+	if s == "" {
+		s = m[2]
+	}
+	abv, err := strconv.ParseFloat(s, 64)
+	if err != nil || abv > 100 {
+		return 0, false
+	}
+	return abv, true
+}
+
+// AlcoholUnits returns the UK units of alcohol, each 10ml of pure alcohol,
+// in ml of a drink of the given ABV.
+func AlcoholUnits(ml, abv float64) float64 {
+	return ml * abv / 1000
+}
+
+// ABV returns the item's alcohol by volume from its name or description.
+func (it Item) ABV() (float64, bool) {
+	if abv, ok := ParseABV(it.Name); ok {
+		return abv, true
+	}
+	return ParseABV(it.Description)
+}
+
+// Volume returns the portion's volume in millilitres from its label. See
+// Item.PortionVolume to fall back to the item's description.
+func (p PortionOption) Volume() (float64, bool) {
+	return ParseVolume(p.Label)
+}
+
+// PortionVolume returns the volume of one of the item's portions.
+func (it Item) PortionVolume(p PortionOption) (float64, bool) {
+	return PortionVolume(p.Label, it.Description)
+}
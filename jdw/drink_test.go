@@ -0,0 +1,84 @@
+package jdw
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseVolume(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+	}{
+		{"Pint", 568},
+		{"Half", 284},
+		{"Half pint", 284},
+		{"2/3 pint", 379},
+		{"Schooner", 379},
+		{"Third of a pint", 189},
+		{"4 Pint Jug", 2272},
+		{"330ml bottle", 330},
+		{"Bottle (50cl)", 500},
+		{"0.7 Litre", 700},
+		{"Large glass", 250},
+		{"Medium Glass (175ml)", 175},
+		{"Double", 50},
+		{"Bottle", 0},
+		{"Regular", 0},
+		{"Large", 0},
+	}
+	for _, tt := range tests {
+		got, ok := ParseVolume(tt.text)
+		if ok != (tt.want > 0) || math.Abs(got-tt.want) > 0.5 {
+			t.Errorf("ParseVolume(%q): expected %v, got %v (%v)", tt.text, tt.want, got, ok)
+		}
+	}
+
+	portions := []struct {
+		label, description string
+		want               float64
+	}{
+		{"Bottle", "Premium lager. 330ml bottle, 4.8% ABV.", 330},
+		{"Bottle", "Mexican beer, 330ml. 4.5% ABV.", 330},
+		// Several volumes: the one for the portion, or none.
+		{"Bottle", "Rioja. 175ml glass or 750ml bottle, 13.5% ABV.", 750},
+		{"Glass", "Rioja. 175ml glass or 750ml bottle, 13.5% ABV.", 175},
+		{"Bottle", "Rioja. 175ml or 750ml, 13.5% ABV.", 0},
+		// A multi-buy offer isn't a volume.
+		{"Draught", "Golden ale, 4.1% ABV. 2 pints for £8.", 0},
+	}
+	for _, tt := range portions {
+		got, ok := PortionVolume(tt.label, tt.description)
+		if ok != (tt.want > 0) || got != tt.want {
+			t.Errorf("PortionVolume(%q, %q): expected %v, got %v (%v)", tt.label, tt.description, tt.want, got, ok)
+		}
+	}
+}
+
+func TestParseABV(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+		ok   bool
+	}{
+		{"Premium Belgian lager, 4.6% ABV", 4.6, true},
+		{"London dry gin (37.5% vol)", 37.5, true},
+		{"ABV: 5%", 5, true},
+		{"Alcohol-free lager, 0.0% ABV", 0, true},
+		{"20% off all burgers", 0, false},
+		{"Refillable soft drink", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseABV(tt.text)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseABV(%q): expected %v (%v), got %v (%v)", tt.text, tt.want, tt.ok, got, ok)
+		}
+	}
+
+	it := Item{Name: "Stella Artois", Description: "Premium Belgian lager, 4.6% ABV"}
+	abv, _ := it.ABV()
+	ml, _ := it.PortionVolume(PortionOption{Label: "Pint"})
+	if units := AlcoholUnits(ml, abv); math.Abs(units-2.61) > 0.01 {
+		t.Errorf("Expected a pint of Stella to be 2.61 units, got %.2f", units)
+	}
+}
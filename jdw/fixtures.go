@@ -0,0 +1,209 @@
+package jdw
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrFixtureNotFound is returned by ReplayTransport when no fixture matches a request.
+var ErrFixtureNotFound = errors.New("no fixture recorded for request")
+
+// redactedToken replaces the bearer token in recorded fixtures.
+const redactedToken = "Bearer REDACTED"
+
+// Fixture is a recorded request/response pair.
+type Fixture struct {
+	Request  FixtureRequest  `json:"request"`
+	Response FixtureResponse `json:"response"`
+}
+
+// FixtureRequest is the recorded part of an HTTP request.
+type FixtureRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   string            `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// FixtureResponse is the recorded part of an HTTP response. JSON bodies are
+// stored inline so fixtures stay readable; anything else is kept as text.
+type FixtureResponse struct {
+	Status   int               `json:"status"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     json.RawMessage   `json:"body,omitempty"`
+	BodyText string            `json:"bodyText,omitempty"`
+}
+
+var unsafeFixtureChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// FixtureName returns the file name used to store the fixture for a request.
+func FixtureName(method, path, query string) string {
+	name := method + "_" + strings.Trim(path, "/")
+	if query != "" {
+		name += "_" + query
+	}
+	return unsafeFixtureChars.ReplaceAllString(name, "_") + ".json"
+}
+
+// RecordingTransport passes requests through to Base and writes each
+// request/response pair to Dir. The Authorization header is never written.
+type RecordingTransport struct {
+	Dir  string
+	Base http.RoundTripper
+}
+
+// NewRecordingTransport creates a RecordingTransport writing to dir, creating
+// it if necessary. A nil base uses http.DefaultTransport.
+func NewRecordingTransport(dir string, base http.RoundTripper) (*RecordingTransport, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating fixtures directory: %w", err)
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RecordingTransport{Dir: dir, Base: base}, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fixture := Fixture{
+		Request: FixtureRequest{
+			Method:  req.Method,
+			Path:    req.URL.Path,
+			Query:   req.URL.RawQuery,
+			Headers: flattenHeader(req.Header),
+		},
+		Response: FixtureResponse{
+			Status:  resp.StatusCode,
+			Headers: flattenHeader(resp.Header),
+		},
+	}
+	if _, ok := fixture.Request.Headers["Authorization"]; ok {
+		fixture.Request.Headers["Authorization"] = redactedToken
+	}
+	if json.Valid(body) {
+		fixture.Response.Body = body
+	} else {
+		fixture.Response.BodyText = string(body)
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	name := FixtureName(req.Method, req.URL.Path, req.URL.RawQuery)
+	if err := os.WriteFile(filepath.Join(t.Dir, name), append(data, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("writing fixture: %w", err)
+	}
+	return resp, nil
+}
+
+// ReplayTransport serves responses from fixtures recorded by RecordingTransport.
+// Requests are matched on method, path and query; the host is ignored.
+type ReplayTransport struct {
+	mu       sync.RWMutex
+	fixtures map[string]Fixture
+}
+
+// NewReplayTransport loads every fixture in dir.
+func NewReplayTransport(dir string) (*ReplayTransport, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &ReplayTransport{fixtures: make(map[string]Fixture)}
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var f Fixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("parsing fixture %s: %w", filepath.Base(p), err)
+		}
+		t.Add(f)
+	}
+	return t, nil
+}
+
+// Add registers a fixture, replacing any existing fixture for the same request.
+func (t *ReplayTransport) Add(f Fixture) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fixtures[FixtureName(f.Request.Method, f.Request.Path, f.Request.Query)] = f
+}
+
+// Len returns the number of loaded fixtures.
+func (t *ReplayTransport) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.fixtures)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	f, ok := t.fixtures[FixtureName(req.Method, req.URL.Path, req.URL.RawQuery)]
+	t.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %s", ErrFixtureNotFound, req.Method, req.URL.RequestURI())
+	}
+
+	body := []byte(f.Response.Body)
+	if len(body) == 0 {
+		body = []byte(f.Response.BodyText)
+	}
+
+	header := make(http.Header)
+	for k, v := range f.Response.Headers {
+		header.Set(k, v)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Response.Status, http.StatusText(f.Response.Status)),
+		StatusCode:    f.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func flattenHeader(h http.Header) map[string]string {
+	if len(h) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		out[k] = strings.Join(v, ", ")
+	}
+	return out
+}
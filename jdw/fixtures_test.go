@@ -0,0 +1,107 @@
+package jdw
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixtureName(t *testing.T) {
+	got := FixtureName("GET", "/api/v0.1/jdw/venues/1/sales-areas/2/menus", "")
+	if got != "GET_api_v0.1_jdw_venues_1_sales-areas_2_menus.json" {
+		t.Errorf("Unexpected fixture name %q", got)
+	}
+	got = FixtureName("GET", "/api/v0.1/geocode/location", "latitude=51.5&longitude=-0.1")
+	if got != "GET_api_v0.1_geocode_location_latitude_51.5_longitude_-0.1.json" {
+		t.Errorf("Unexpected fixture name %q", got)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"success": true, "data": [{"id": 1, "name": "Recorded Venue"}]}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder, err := NewRecordingTransport(dir, nil)
+	if err != nil {
+		t.Fatalf("NewRecordingTransport failed: %v", err)
+	}
+
+	client := NewClient("1.2.3", "secret-token", "test-ua")
+	client.SetBaseURL(server.URL)
+	client.SetTransport(recorder)
+	if _, err := client.GetVenues(); err != nil {
+		t.Fatalf("GetVenues while recording failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "GET_api_v0.1_venues.json"))
+	if err != nil {
+		t.Fatalf("Expected fixture file: %v", err)
+	}
+	if strings.Contains(string(data), "secret-token") {
+		t.Error("Fixture must not contain the bearer token")
+	}
+	if !strings.Contains(string(data), redactedToken) {
+		t.Errorf("Expected redacted Authorization header, got %s", data)
+	}
+
+	server.Close()
+
+	replay, err := NewReplayTransport(dir)
+	if err != nil {
+		t.Fatalf("NewReplayTransport failed: %v", err)
+	}
+	client = NewClient("1.2.3", "another-token", "test-ua")
+	client.SetTransport(replay)
+
+	venues, err := client.GetVenues()
+	if err != nil {
+		t.Fatalf("GetVenues while replaying failed: %v", err)
+	}
+	if len(venues) != 1 || venues[0].Name != "Recorded Venue" {
+		t.Errorf("Unexpected replayed venues: %v", venues)
+	}
+
+	if _, err := client.GetSettings(); !errors.Is(err, ErrFixtureNotFound) {
+		t.Errorf("Expected ErrFixtureNotFound, got %v", err)
+	}
+}
+
+func TestReplayTestdata(t *testing.T) {
+	replay, err := NewReplayTransport("testdata/fixtures")
+	if err != nil {
+		t.Fatalf("NewReplayTransport failed: %v", err)
+	}
+	client := NewClient("6.7.1", "t", "u")
+	client.SetTransport(replay)
+
+	venues, err := client.GetVenues()
+	if err != nil || len(venues) != 3 {
+		t.Fatalf("Expected 3 venues, got %d (%v)", len(venues), err)
+	}
+
+	items, err := client.GetMenuItems(7001, 301, 11)
+	if err != nil {
+		t.Fatalf("GetMenuItems failed: %v", err)
+	}
+	if categories, ok := items["categories"].([]interface{}); !ok || len(categories) == 0 {
+		t.Errorf("Expected categories in menu items, got %v", items)
+	}
+
+	var apiErr *APIError
+	if _, err := client.GetMenus(7003, 303); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected recorded 404, got %v", err)
+	}
+
+	if _, err := NewReplayTransport("testdata/missing"); err == nil {
+		t.Error("Expected error for missing fixtures directory")
+	}
+}
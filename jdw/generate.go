@@ -0,0 +1,7 @@
+package jdw
+
+// The models and typed methods in models_gen.go are generated from the
+// OpenAPI spec, which is the source of truth for the API surface. Edit
+// openapi.yaml and run "go generate ./jdw"; TestGeneratedCodeUpToDate fails
+// when they drift apart.
+//go:generate go run ../internal/openapigen/cmd/openapigen -spec openapi.yaml -out models_gen.go -package jdw
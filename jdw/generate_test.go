@@ -0,0 +1,104 @@
+package jdw
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/KRoperUK/get_spoons/internal/openapigen"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGeneratedCodeUpToDate(t *testing.T) {
+	spec, err := os.ReadFile("openapi.yaml")
+	if err != nil {
+		t.Fatalf("reading spec: %v", err)
+	}
+	want, err := openapigen.Generate(spec, "jdw")
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	got, err := os.ReadFile("models_gen.go")
+	if err != nil {
+		t.Fatalf("reading models_gen.go: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("models_gen.go is out of date with openapi.yaml; run go generate ./jdw")
+	}
+}
+
+// TestClientPathsInSpec calls every Get method of the client, including the
+// hand-written raw ones, and checks that each request is documented in the
+// spec.
+func TestClientPathsInSpec(t *testing.T) {
+	spec, err := os.ReadFile("openapi.yaml")
+	if err != nil {
+		t.Fatalf("reading spec: %v", err)
+	}
+	var doc struct {
+		Paths map[string]map[string]interface{} `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(spec, &doc); err != nil {
+		t.Fatalf("parsing spec: %v", err)
+	}
+	var documented []*regexp.Regexp
+	for path, ops := range doc.Paths {
+		if _, ok := ops["get"]; !ok {
+			continue
+		}
+		pattern := regexp.MustCompile(`\\\{[^}]+\\\}`).ReplaceAllString(regexp.QuoteMeta(path), `[^/]+`)
+		documented = append(documented, regexp.MustCompile("^"+pattern+"$"))
+	}
+
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		fmt.Fprint(w, `{"success": true, "data": null}`)
+	}))
+	defer server.Close()
+
+	client := NewClient("1.2.3", "test-token", "test-ua")
+	client.SetBaseURL(server.URL)
+	v := reflect.ValueOf(client)
+	checked := 0
+	for i := 0; i < v.NumMethod(); i++ {
+		m := v.Type().Method(i)
+		if !strings.HasPrefix(m.Name, "Get") || strings.HasSuffix(m.Name, "Context") {
+			continue
+		}
+		var args []reflect.Value
+		for j := 1; j < m.Type.NumIn(); j++ {
+			if m.Type.In(j).Kind() != reflect.Int {
+				t.Fatalf("%s: unsupported parameter type %s", m.Name, m.Type.In(j))
+			}
+			args = append(args, reflect.ValueOf(j))
+		}
+
+		requested = ""
+		v.Method(i).Call(args)
+		if requested == "" {
+			t.Errorf("%s made no request", m.Name)
+			continue
+		}
+		found := false
+		for _, re := range documented {
+			if re.MatchString(requested) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("%s requests %s, which is not documented in openapi.yaml", m.Name, requested)
+		}
+		checked++
+	}
+	if checked < 9 {
+		t.Errorf("Expected to check at least 9 methods, checked %d", checked)
+	}
+}
@@ -0,0 +1,316 @@
+package jdwtest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// Dataset is the synthetic estate served by a Server.
+type Dataset struct {
+	Venues     []jdw.Venue
+	SalesAreas map[int]int    // venueRef -> sales area ID
+	Menus      map[int][]Menu // venueRef -> menus
+	Settings   jdw.Settings
+	Banners    []jdw.Banner
+}
+
+// Menu is a venue menu along with its categories.
+type Menu struct {
+	ID          int
+	Name        string
+	Description string
+	CanOrder    bool
+	Categories  []Category
+}
+
+// Category groups items on a menu.
+type Category struct {
+	ID         int
+	Name       string
+	Hidden     bool
+	ItemGroups []ItemGroup
+}
+
+// ItemGroup is a titled group of items within a category.
+type ItemGroup struct {
+	Description *string
+	Items       []Item
+}
+
+// Item is a menu item with its portion options.
+type Item struct {
+	ID              int
+	Name            string
+	Description     string
+	Calories        int
+	DisplayRecordID int
+	ItemType        string
+	IsOutOfStock    bool
+	Portions        []Portion
+}
+
+// Portion is a priced size or variant of an item.
+type Portion struct {
+	Label string
+	Price float64
+}
+
+var (
+	venueNames = []string{
+		"The Moon Under Water", "The Sir John Oldcastle", "The Knights Templar", "The Crosse Keys",
+		"The Lord Moon of the Mall", "The Toll Gate", "The Sir Henry Newbolt", "The Old Swan",
+		"The Shakespeare", "The Standing Order", "The Hope & Anchor", "The Goldengate",
+		"The Drum & Monkey", "The Railway", "The Picture House", "The Commercial Rooms",
+	}
+	towns = []struct {
+		town, county, postcode string
+		lat, lng               float64
+	}{
+		{"London", "Greater London", "EC1A 1BB", 51.5155, -0.0922},
+		{"Manchester", "Greater Manchester", "M1 1AE", 53.4808, -2.2426},
+		{"Birmingham", "West Midlands", "B1 1AA", 52.4862, -1.8904},
+		{"Bath", "Somerset", "BA1 1TP", 51.3811, -2.3590},
+		{"Leeds", "West Yorkshire", "LS1 1UR", 53.7997, -1.5492},
+		{"Bristol", "Bristol", "BS1 4DJ", 51.4545, -2.5879},
+		{"Bilston", "West Midlands", "WV14 0EP", 52.5660, -2.0735},
+		{"Edinburgh", "City of Edinburgh", "EH1 1YZ", 55.9533, -3.1883},
+	}
+	streets = []string{"High Street", "Station Road", "Market Place", "Church Street", "Victoria Road", "King Street"}
+
+	drinks = []struct {
+		name, desc string
+		calories   int
+		half, pint float64
+	}{
+		{"Stella Artois", "Premium Belgian lager, 4.6% ABV", 227, 2.35, 4.49},
+		{"Peroni Nastro Azzurro", "Italian lager, 5.0% ABV", 245, 2.55, 4.89},
+		{"Carling", "British lager, 3.7% ABV", 189, 1.85, 3.49},
+		{"Guinness Draught", "Irish stout, 4.1% ABV", 210, 2.45, 4.69},
+		{"Ruddles Best", "Amber bitter, 3.7% ABV", 170, 1.49, 2.49},
+	}
+	softDrinks = []struct {
+		name     string
+		calories int
+		price    float64
+	}{
+		{"Coca-Cola", 139, 1.99},
+		{"Diet Coke", 1, 1.99},
+		{"Pepsi Max", 1, 1.89},
+	}
+	foods = []struct {
+		name, desc string
+		calories   int
+		price      float64
+	}{
+		{"Classic Beef Burger", "6oz beef patty in a brioche bun. Contains: gluten, milk, egg, sesame.", 1074, 7.99},
+		{"Vegan Moving Mountains Burger", "Plant-based patty with vegan cheese. Vegan. Contains: gluten, soya.", 912, 8.49},
+		{"Superfood Salad", "Quinoa, edamame, avocado and mixed leaves. Vegan, gluten-free.", 412, 7.29},
+		{"Traditional Breakfast", "Bacon, sausage, egg, beans, hash brown and toast. Contains: gluten, milk, egg.", 942, 5.99},
+		{"Fish & Chips", "Battered cod, chips and peas. Contains: fish, gluten.", 1287, 9.49},
+	}
+)
+
+// NewDataset builds a deterministic synthetic estate of n venues from seed.
+func NewDataset(seed int64, n int) *Dataset {
+	rng := rand.New(rand.NewSource(seed))
+	d := &Dataset{
+		SalesAreas: make(map[int]int),
+		Menus:      make(map[int][]Menu),
+		Settings: jdw.Settings{
+			MinVersion: "6.5.0",
+			Urls:       map[string]string{"terms": "https://example.com/terms", "privacy": "https://example.com/privacy"},
+			Features:   map[string]interface{}{"tableOrdering": true},
+		},
+		Banners: []jdw.Banner{
+			{Campaign: "Curry Club", ImageURL: "https://example.com/banners/curry-club.jpg", URL: "https://example.com/curry-club"},
+			{Campaign: "Steak Club", ImageURL: "https://example.com/banners/steak-club.jpg", URL: "https://example.com/steak-club"},
+		},
+	}
+
+	for i := 0; i < n; i++ {
+		t := towns[rng.Intn(len(towns))]
+		name := venueNames[i%len(venueNames)]
+		if i >= len(venueNames) {
+			name = fmt.Sprintf("%s (%s)", name, t.town)
+		}
+		venueRef := 5000 + i
+		closed := rng.Intn(10) == 0
+		status := "open"
+		if closed {
+			status = "closed"
+		}
+
+		d.Venues = append(d.Venues, jdw.Venue{
+			ID:       1000 + i,
+			VenueRef: venueRef,
+			Name:     name,
+			Status:   status,
+			Type:     "pub",
+			IsClosed: closed,
+			Address: jdw.Address{
+				Line1:    fmt.Sprintf("%d %s", 1+rng.Intn(200), streets[rng.Intn(len(streets))]),
+				Town:     t.town,
+				County:   t.county,
+				Postcode: t.postcode,
+				Location: jdw.Location{
+					Latitude:  round(t.lat+(rng.Float64()-0.5)*0.05, 6),
+					Longitude: round(t.lng+(rng.Float64()-0.5)*0.05, 6),
+				},
+			},
+		})
+		d.SalesAreas[venueRef] = 100 + i
+		d.Menus[venueRef] = newMenus(rng, venueRef)
+	}
+	return d
+}
+
+func newMenus(rng *rand.Rand, venueRef int) []Menu {
+	// Regional price variation of up to +20%.
+	mult := 1 + rng.Float64()*0.2
+	price := func(p float64) float64 { return round(p*mult, 2) }
+	itemID := venueRef * 100
+
+	var beers, softs, mains []Item
+	for _, dr := range drinks {
+		itemID++
+		beers = append(beers, Item{
+			ID: itemID, Name: dr.name, Description: dr.desc, Calories: dr.calories,
+			DisplayRecordID: itemID * 10, ItemType: "product", IsOutOfStock: rng.Intn(8) == 0,
+			Portions: []Portion{{"Half", price(dr.half)}, {"Pint", price(dr.pint)}},
+		})
+	}
+	for _, sd := range softDrinks {
+		itemID++
+		softs = append(softs, Item{
+			ID: itemID, Name: sd.name, Description: "Refillable soft drink", Calories: sd.calories,
+			DisplayRecordID: itemID * 10, ItemType: "product",
+			Portions: []Portion{{"Regular", price(sd.price)}},
+		})
+	}
+	for _, f := range foods {
+		itemID++
+		mains = append(mains, Item{
+			ID: itemID, Name: f.name, Description: f.desc, Calories: f.calories,
+			DisplayRecordID: itemID * 10, ItemType: "product", IsOutOfStock: rng.Intn(12) == 0,
+			Portions: []Portion{{"With a drink", price(f.price + 1.5)}, {"Without a drink", price(f.price)}},
+		})
+	}
+
+	lager := "Draught"
+	return []Menu{
+		{
+			ID: 1, Name: "Drinks", Description: "Beer, wine, spirits and soft drinks", CanOrder: true,
+			Categories: []Category{
+				{ID: 11, Name: "Beer", ItemGroups: []ItemGroup{{Description: &lager, Items: beers}}},
+				{ID: 12, Name: "Soft Drinks", ItemGroups: []ItemGroup{{Items: softs}}},
+			},
+		},
+		{
+			ID: 2, Name: "Food", Description: "Main menu", CanOrder: true,
+			Categories: []Category{
+				{ID: 21, Name: "Mains", ItemGroups: []ItemGroup{{Items: mains}}},
+			},
+		},
+	}
+}
+
+// VenueByRef returns the venue with the given venueRef or ID.
+func (d *Dataset) VenueByRef(ref int) (jdw.Venue, bool) {
+	for _, v := range d.Venues {
+		if v.VenueRef == ref || v.ID == ref {
+			return v, true
+		}
+	}
+	return jdw.Venue{}, false
+}
+
+// Nearest returns the venue closest to the given coordinates.
+func (d *Dataset) Nearest(lat, lng float64) (jdw.Venue, bool) {
+	best, bestDist := -1, math.MaxFloat64
+	for i, v := range d.Venues {
+		dLat := v.Address.Location.Latitude - lat
+		dLng := v.Address.Location.Longitude - lng
+		if dist := dLat*dLat + dLng*dLng; dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	if best < 0 {
+		return jdw.Venue{}, false
+	}
+	return d.Venues[best], true
+}
+
+func (d *Dataset) venueDetails(v jdw.Venue) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        v.ID,
+		"venueRef":  v.VenueRef,
+		"name":      v.Name,
+		"status":    v.Status,
+		"type":      v.Type,
+		"isClosed":  v.IsClosed,
+		"franchise": v.Franchise,
+		"address":   v.Address,
+		"salesAreas": []interface{}{
+			map[string]interface{}{"id": d.SalesAreas[v.VenueRef], "name": "Main Bar", "canOrder": !v.IsClosed},
+		},
+	}
+}
+
+func (m Menu) summary() map[string]interface{} {
+	return map[string]interface{}{
+		"id":          m.ID,
+		"name":        m.Name,
+		"description": m.Description,
+		"canOrder":    m.CanOrder,
+	}
+}
+
+func (m Menu) details() map[string]interface{} {
+	var categories []interface{}
+	for _, c := range m.Categories {
+		var groups []interface{}
+		for _, g := range c.ItemGroups {
+			var items []interface{}
+			for _, it := range g.Items {
+				items = append(items, it.toMap())
+			}
+			groups = append(groups, map[string]interface{}{"description": g.Description, "items": items})
+		}
+		categories = append(categories, map[string]interface{}{
+			"id": c.ID, "name": c.Name, "hidden": c.Hidden, "itemGroups": groups,
+		})
+	}
+	return map[string]interface{}{"id": m.ID, "name": m.Name, "categories": categories}
+}
+
+func (it Item) toMap() map[string]interface{} {
+	var portions []interface{}
+	for _, p := range it.Portions {
+		portions = append(portions, map[string]interface{}{
+			"label": p.Label,
+			"value": map[string]interface{}{"price": map[string]interface{}{"value": p.Price}},
+		})
+	}
+	return map[string]interface{}{
+		"id":              it.ID,
+		"name":            it.Name,
+		"description":     it.Description,
+		"calories":        it.Calories,
+		"displayRecordId": it.DisplayRecordID,
+		"itemType":        it.ItemType,
+		"isOutOfStock":    it.IsOutOfStock,
+		"options": map[string]interface{}{
+			"portion": map[string]interface{}{"title": "Choose a size", "options": portions},
+			"addOns":  []interface{}{},
+			"choices": []interface{}{},
+		},
+	}
+}
+
+func round(f float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(f*p) / p
+}
@@ -0,0 +1,369 @@
+// Package jdwtest provides an in-process fake of the JDW API for integration
+// tests. It serves every endpoint described in openapi.yaml from a seeded
+// synthetic Dataset and can inject latency, errors, rate limiting and
+// authentication failures.
+package jdwtest
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+// DefaultToken is the bearer token accepted when Options.Token is empty.
+const DefaultToken = "1|jdwtest"
+
+// Endpoint names for the endpoints that are not part of jdw.Client.
+const (
+	EndpointGeocode = "geocode"
+	EndpointToken   = "oauth2_token"
+)
+
+// Options configures a Server.
+type Options struct {
+	// Seed and Venues control the synthetic dataset (default 1 and 10).
+	Seed   int64
+	Venues int
+	// Dataset overrides the generated dataset.
+	Dataset *Dataset
+	// Token is the bearer token required on API requests (default DefaultToken).
+	Token string
+	// Latency is added to every response.
+	Latency time.Duration
+	// ErrorRate is the fraction of API requests answered with a 500.
+	ErrorRate float64
+	// RateLimit is the maximum number of API requests per second; further
+	// requests receive a 429. Zero disables rate limiting.
+	RateLimit int
+}
+
+// Server is a fake JDW API server.
+type Server struct {
+	// URL is the base URL of the server, suitable for jdw.Client.SetBaseURL.
+	URL     string
+	Dataset *Dataset
+
+	srv *httptest.Server
+
+	mu            sync.Mutex
+	opts          Options
+	rng           *rand.Rand
+	rejectAuth    bool
+	failNext      []int
+	failEndpoints map[string]int
+	requests      map[string]int
+	windowStart   time.Time
+	windowCount   int
+}
+
+// NewServer starts a fake JDW API server. Call Close when done.
+func NewServer(opts Options) *Server {
+	if opts.Seed == 0 {
+		opts.Seed = 1
+	}
+	if opts.Venues == 0 {
+		opts.Venues = 10
+	}
+	if opts.Token == "" {
+		opts.Token = DefaultToken
+	}
+	dataset := opts.Dataset
+	if dataset == nil {
+		dataset = NewDataset(opts.Seed, opts.Venues)
+	}
+
+	s := &Server{
+		Dataset:       dataset,
+		opts:          opts,
+		rng:           rand.New(rand.NewSource(opts.Seed)),
+		failEndpoints: make(map[string]int),
+		requests:      make(map[string]int),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// NewClient returns a jdw.Client pointed at the server with a valid token.
+func (s *Server) NewClient() *jdw.Client {
+	client := jdw.NewClient("6.7.1", s.Token(), "jdwtest")
+	client.SetBaseURL(s.URL)
+	return client
+}
+
+// Token returns the bearer token the server accepts.
+func (s *Server) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opts.Token
+}
+
+// SetLatency changes the delay added to every response.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.Latency = d
+}
+
+// SetErrorRate changes the fraction of API requests answered with a 500.
+func (s *Server) SetErrorRate(rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.ErrorRate = rate
+}
+
+// SetRateLimit changes the maximum number of API requests per second.
+func (s *Server) SetRateLimit(perSecond int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.RateLimit = perSecond
+}
+
+// RejectAuth makes every API request fail with a 401 until reset.
+func (s *Server) RejectAuth(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejectAuth = reject
+}
+
+// FailNext answers the next API requests with the given status codes, one
+// status per request, before resuming normal behaviour.
+func (s *Server) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext = append(s.failNext, statuses...)
+}
+
+// FailEndpoint answers every request to endpoint (see the jdw.Endpoint*
+// constants) with status. A status of 0 clears the failure.
+func (s *Server) FailEndpoint(endpoint string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status == 0 {
+		delete(s.failEndpoints, endpoint)
+		return
+	}
+	s.failEndpoints[endpoint] = status
+}
+
+// Requests returns the number of requests received for endpoint.
+func (s *Server) Requests(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[endpoint]
+}
+
+var (
+	venueDetailsPath = regexp.MustCompile(`^/api/v0\.1/jdw/venues/(\d+)$`)
+	menusPath        = regexp.MustCompile(`^/api/v0\.1/jdw/venues/(\d+)/sales-areas/(\d+)/menus$`)
+	menuItemsPath    = regexp.MustCompile(`^/api/v0\.1/jdw/venues/(\d+)/sales-areas/(\d+)/menus/(\d+)$`)
+)
+
+func endpointFor(path string) string {
+	switch path {
+	case "/api/v0.1/geocode/location":
+		return EndpointGeocode
+	case "/oauth2/token":
+		return EndpointToken
+	}
+	return jdw.EndpointForPath(path)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := endpointFor(r.URL.Path)
+
+	s.mu.Lock()
+	s.requests[endpoint]++
+	latency := s.opts.Latency
+	status := s.injectedStatus(r, endpoint)
+	s.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if status != 0 {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		writeJSON(w, status, jdw.APIResponse{Success: false, Error: http.StatusText(status)})
+		return
+	}
+
+	if endpoint == EndpointToken {
+		s.handleToken(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, jdw.APIResponse{Success: false, Error: "method not allowed"})
+		return
+	}
+
+	switch endpoint {
+	case jdw.EndpointVenues:
+		writeData(w, s.Dataset.Venues)
+	case jdw.EndpointVenueDetails:
+		m := venueDetailsPath.FindStringSubmatch(r.URL.Path)
+		v, ok := s.Dataset.VenueByRef(atoi(m[1]))
+		if !ok {
+			notFound(w)
+			return
+		}
+		writeData(w, s.Dataset.venueDetails(v))
+	case jdw.EndpointMenus:
+		m := menusPath.FindStringSubmatch(r.URL.Path)
+		menus, ok := s.menus(atoi(m[1]), atoi(m[2]))
+		if !ok {
+			notFound(w)
+			return
+		}
+		summaries := []interface{}{}
+		for _, menu := range menus {
+			summaries = append(summaries, menu.summary())
+		}
+		writeData(w, summaries)
+	case jdw.EndpointMenuItems:
+		m := menuItemsPath.FindStringSubmatch(r.URL.Path)
+		menus, ok := s.menus(atoi(m[1]), atoi(m[2]))
+		if !ok {
+			notFound(w)
+			return
+		}
+		for _, menu := range menus {
+			if menu.ID == atoi(m[3]) {
+				writeData(w, menu.details())
+				return
+			}
+		}
+		notFound(w)
+	case jdw.EndpointSettings:
+		writeData(w, s.Dataset.Settings)
+	case jdw.EndpointBanners:
+		writeData(w, s.Dataset.Banners)
+	case EndpointGeocode:
+		s.handleGeocode(w, r)
+	default:
+		notFound(w)
+	}
+}
+
+// injectedStatus decides whether a request should fail. It must be called
+// with s.mu held.
+func (s *Server) injectedStatus(r *http.Request, endpoint string) int {
+	if endpoint != EndpointToken {
+		if s.rejectAuth || r.Header.Get("Authorization") != "Bearer "+s.opts.Token {
+			return http.StatusUnauthorized
+		}
+	}
+	if s.opts.RateLimit > 0 {
+		now := time.Now()
+		if now.Sub(s.windowStart) >= time.Second {
+			s.windowStart, s.windowCount = now, 0
+		}
+		s.windowCount++
+		if s.windowCount > s.opts.RateLimit {
+			return http.StatusTooManyRequests
+		}
+	}
+	if len(s.failNext) > 0 {
+		status := s.failNext[0]
+		s.failNext = s.failNext[1:]
+		return status
+	}
+	if status, ok := s.failEndpoints[endpoint]; ok {
+		return status
+	}
+	if s.opts.ErrorRate > 0 && s.rng.Float64() < s.opts.ErrorRate {
+		return http.StatusInternalServerError
+	}
+	return 0
+}
+
+func (s *Server) menus(venueRef, salesAreaID int) ([]Menu, bool) {
+	v, ok := s.Dataset.VenueByRef(venueRef)
+	if !ok || s.Dataset.SalesAreas[v.VenueRef] != salesAreaID {
+		return nil, false
+	}
+	return s.Dataset.Menus[v.VenueRef], true
+}
+
+func (s *Server) handleGeocode(w http.ResponseWriter, r *http.Request) {
+	lat, errLat := strconv.ParseFloat(r.URL.Query().Get("latitude"), 64)
+	lng, errLng := strconv.ParseFloat(r.URL.Query().Get("longitude"), 64)
+	if errLat != nil || errLng != nil {
+		writeJSON(w, http.StatusBadRequest, jdw.APIResponse{Success: false, Error: "latitude and longitude are required"})
+		return
+	}
+	v, ok := s.Dataset.Nearest(lat, lng)
+	if !ok {
+		notFound(w)
+		return
+	}
+	addr := v.Address
+	addr.Location = jdw.Location{Latitude: lat, Longitude: lng}
+	writeData(w, map[string]interface{}{"latitude": lat, "longitude": lng, "address": addr})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		if r.PostForm.Get("code") == "" || r.PostForm.Get("client_id") == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+	case "refresh_token":
+		if r.PostForm.Get("refresh_token") == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token":  s.Token(),
+		"refresh_token": "jdwtest-refresh",
+		"id_token":      "jdwtest-id",
+		"token_type":    "Bearer",
+	})
+}
+
+func notFound(w http.ResponseWriter) {
+	writeJSON(w, http.StatusNotFound, jdw.APIResponse{Success: false, Error: "not found"})
+}
+
+func writeData(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, jdw.APIResponse{Success: true, Data: data})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,242 @@
+package jdwtest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KRoperUK/get_spoons/jdw"
+)
+
+func TestNewDatasetDeterministic(t *testing.T) {
+	a, b := NewDataset(42, 20), NewDataset(42, 20)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("Expected datasets with the same seed to be identical")
+	}
+	if len(a.Venues) != 20 {
+		t.Errorf("Expected 20 venues, got %d", len(a.Venues))
+	}
+	if reflect.DeepEqual(a.Venues, NewDataset(7, 20).Venues) {
+		t.Error("Expected different seeds to produce different venues")
+	}
+}
+
+func TestServerEndpoints(t *testing.T) {
+	srv := NewServer(Options{Venues: 5})
+	defer srv.Close()
+	client := srv.NewClient()
+
+	venues, err := client.GetVenues()
+	if err != nil || len(venues) != 5 {
+		t.Fatalf("GetVenues: expected 5 venues, got %d (%v)", len(venues), err)
+	}
+
+	v := venues[0]
+	details, err := client.GetVenueDetails(v.VenueRef)
+	if err != nil {
+		t.Fatalf("GetVenueDetails failed: %v", err)
+	}
+	salesAreaID := int(details["salesAreas"].([]interface{})[0].(map[string]interface{})["id"].(float64))
+
+	menus, err := client.GetMenus(v.VenueRef, salesAreaID)
+	if err != nil || len(menus) == 0 {
+		t.Fatalf("GetMenus failed: %v (%d menus)", err, len(menus))
+	}
+	menuID := int(menus[0].(map[string]interface{})["id"].(float64))
+
+	items, err := client.GetMenuItems(v.VenueRef, salesAreaID, menuID)
+	if err != nil {
+		t.Fatalf("GetMenuItems failed: %v", err)
+	}
+	if categories, ok := items["categories"].([]interface{}); !ok || len(categories) == 0 {
+		t.Errorf("Expected categories, got %v", items)
+	}
+
+	if _, err := client.GetMenuItems(v.VenueRef, salesAreaID, 999); err == nil {
+		t.Error("Expected error for unknown menu")
+	}
+
+	settings, err := client.GetSettings()
+	if err != nil || settings.MinVersion == "" {
+		t.Errorf("GetSettings failed: %v, %+v", err, settings)
+	}
+	banners, err := client.GetBanners()
+	if err != nil || len(banners) == 0 {
+		t.Errorf("GetBanners failed: %v, %v", err, banners)
+	}
+
+	if got := srv.Requests(jdw.EndpointVenues); got != 1 {
+		t.Errorf("Expected 1 venues request, got %d", got)
+	}
+}
+
+func TestServerMatchesSpec(t *testing.T) {
+	srv := NewServer(Options{Venues: 3})
+	defer srv.Close()
+	client := srv.NewClient()
+	client.SetSchemaValidator(jdw.DefaultSchemaValidator())
+	var issues []jdw.SchemaIssue
+	client.SetRequestObserver(func(info jdw.RequestInfo) {
+		issues = append(issues, info.SchemaIssues...)
+	})
+
+	venues, err := client.GetVenues()
+	if err != nil {
+		t.Fatalf("GetVenues failed: %v", err)
+	}
+	v := venues[0]
+	salesAreaID := srv.Dataset.SalesAreas[v.VenueRef]
+	if _, err := client.GetVenue(v.VenueRef); err != nil {
+		t.Fatalf("GetVenue failed: %v", err)
+	}
+	menus, err := client.GetSalesAreaMenus(v.VenueRef, salesAreaID)
+	if err != nil || len(menus) == 0 {
+		t.Fatalf("GetSalesAreaMenus failed: %v (%d menus)", err, len(menus))
+	}
+	if _, err := client.GetMenu(v.VenueRef, salesAreaID, menus[0].ID); err != nil {
+		t.Fatalf("GetMenu failed: %v", err)
+	}
+	if _, err := client.GetSettings(); err != nil {
+		t.Fatalf("GetSettings failed: %v", err)
+	}
+	if _, err := client.GetBanners(); err != nil {
+		t.Fatalf("GetBanners failed: %v", err)
+	}
+
+	for _, issue := range issues {
+		t.Errorf("%s %s", issue.Endpoint, issue)
+	}
+}
+
+func TestServerGeocodeAndToken(t *testing.T) {
+	srv := NewServer(Options{Venues: 3})
+	defer srv.Close()
+
+	loc := srv.Dataset.Venues[1].Address.Location
+	req, _ := http.NewRequest("GET", srv.URL+"/api/v0.1/geocode/location?latitude="+
+		jsonNumber(loc.Latitude)+"&longitude="+jsonNumber(loc.Longitude), nil)
+	req.Header.Set("Authorization", "Bearer "+srv.Token())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("geocode request failed: %v", err)
+	}
+	var geo struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Address jdw.Address `json:"address"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&geo)
+	resp.Body.Close()
+	if !geo.Success || geo.Data.Address.Postcode != srv.Dataset.Venues[1].Address.Postcode {
+		t.Errorf("Unexpected geocode response: %+v", geo)
+	}
+
+	resp, err = http.PostForm(srv.URL+"/oauth2/token", url.Values{
+		"grant_type": {"authorization_code"},
+		"code":       {"abc"},
+		"client_id":  {"client"},
+	})
+	if err != nil {
+		t.Fatalf("token request failed: %v", err)
+	}
+	var tok map[string]string
+	json.NewDecoder(resp.Body).Decode(&tok)
+	resp.Body.Close()
+	if tok["access_token"] != srv.Token() || tok["token_type"] != "Bearer" {
+		t.Errorf("Unexpected token response: %v", tok)
+	}
+
+	resp, _ = http.PostForm(srv.URL+"/oauth2/token", url.Values{"grant_type": {"password"}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unsupported grant, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerFaultInjection(t *testing.T) {
+	srv := NewServer(Options{Venues: 2})
+	defer srv.Close()
+	client := srv.NewClient()
+
+	t.Run("Auth", func(t *testing.T) {
+		bad := jdw.NewClient("6.7.1", "wrong", "ua")
+		bad.SetBaseURL(srv.URL)
+		assertStatus(t, func() error { _, err := bad.GetVenues(); return err }, http.StatusUnauthorized)
+
+		srv.RejectAuth(true)
+		assertStatus(t, func() error { _, err := client.GetVenues(); return err }, http.StatusUnauthorized)
+		srv.RejectAuth(false)
+	})
+
+	t.Run("FailNext", func(t *testing.T) {
+		srv.FailNext(http.StatusServiceUnavailable)
+		assertStatus(t, func() error { _, err := client.GetVenues(); return err }, http.StatusServiceUnavailable)
+		if _, err := client.GetVenues(); err != nil {
+			t.Errorf("Expected recovery after injected failure, got %v", err)
+		}
+	})
+
+	t.Run("FailEndpoint", func(t *testing.T) {
+		srv.FailEndpoint(jdw.EndpointSettings, http.StatusBadGateway)
+		assertStatus(t, func() error { _, err := client.GetSettings(); return err }, http.StatusBadGateway)
+		if _, err := client.GetVenues(); err != nil {
+			t.Errorf("Expected other endpoints to succeed, got %v", err)
+		}
+		srv.FailEndpoint(jdw.EndpointSettings, 0)
+		if _, err := client.GetSettings(); err != nil {
+			t.Errorf("Expected settings to recover, got %v", err)
+		}
+	})
+
+	t.Run("ErrorRate", func(t *testing.T) {
+		srv.SetErrorRate(1)
+		assertStatus(t, func() error { _, err := client.GetBanners(); return err }, http.StatusInternalServerError)
+		srv.SetErrorRate(0)
+	})
+
+	t.Run("RateLimit", func(t *testing.T) {
+		srv.SetRateLimit(2)
+		defer srv.SetRateLimit(0)
+		var limited bool
+		for i := 0; i < 5; i++ {
+			var apiErr *jdw.APIError
+			if _, err := client.GetVenues(); errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+				limited = true
+			}
+		}
+		if !limited {
+			t.Error("Expected at least one request to be rate limited")
+		}
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		srv.SetLatency(20 * time.Millisecond)
+		defer srv.SetLatency(0)
+		start := time.Now()
+		if _, err := client.GetSettings(); err != nil {
+			t.Fatalf("GetSettings failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("Expected latency of at least 20ms, got %v", elapsed)
+		}
+	})
+}
+
+func assertStatus(t *testing.T, call func() error, status int) {
+	t.Helper()
+	var apiErr *jdw.APIError
+	if err := call(); !errors.As(err, &apiErr) || apiErr.StatusCode != status {
+		t.Errorf("Expected status %d, got %v", status, err)
+	}
+}
+
+func jsonNumber(f float64) string {
+	b, _ := json.Marshal(f)
+	return strings.TrimSpace(string(b))
+}
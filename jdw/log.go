@@ -0,0 +1,82 @@
+package jdw
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+)
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// WithLogger sets the logger used for request diagnostics; see SetLogger.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// SetLogger sets the logger used for request diagnostics. Every API call is
+// logged at debug level with a request ID, its endpoint, status code, whether
+// it was served from the cache and its duration; failed cache writes are
+// logged as warnings. Pass nil to stop logging. By default nothing is logged
+// unless SetDebug(true) is called.
+func (c *Client) SetLogger(l *slog.Logger) {
+	c.logger = l
+}
+
+// log returns the client's logger. With SetDebug(true) and no logger set,
+// debug output goes to stderr.
+func (c *Client) log() *slog.Logger {
+	switch {
+	case c.logger != nil:
+		return c.logger
+	case c.debug:
+		return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	return discardLogger
+}
+
+// newRequestID returns a random ID used to correlate the log lines of one
+// API call.
+func newRequestID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// logRequest logs an API call before it is made.
+func (c *Client) logRequest(log *slog.Logger, info *RequestInfo) {
+	if !log.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	log.Debug("jdw request",
+		"request_id", info.ID,
+		"method", info.Method,
+		"url", c.baseURL+info.Path,
+		"endpoint", info.Endpoint,
+		"app_version", c.appVersion,
+		"authorization", "Bearer "+MaskToken(c.token),
+	)
+}
+
+// logResponse logs the outcome of an API call.
+func logResponse(log *slog.Logger, info *RequestInfo) {
+	if !log.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	attrs := []any{
+		"request_id", info.ID,
+		"endpoint", info.Endpoint,
+		"status", info.StatusCode,
+		"cached", info.Cached,
+		"duration", info.Duration,
+	}
+	if info.Err != nil {
+		attrs = append(attrs, "error", info.Err)
+	}
+	log.Debug("jdw response", attrs...)
+}
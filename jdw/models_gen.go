@@ -0,0 +1,226 @@
+// Code generated by openapigen from openapi.yaml. DO NOT EDIT.
+
+package jdw
+
+import (
+	"context"
+	"fmt"
+)
+
+// Venue is a Wetherspoon pub.
+type Venue struct {
+	ID        int     `json:"id"`
+	VenueRef  int     `json:"venueRef"`
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Type      string  `json:"type"`
+	IsClosed  bool    `json:"isClosed"`
+	Address   Address `json:"address"`
+	Franchise string  `json:"franchise"`
+	// Only returned by the venue details endpoint.
+	Phone string `json:"phone,omitempty"`
+	// Only returned by the venue details endpoint.
+	OpeningTimes []OpeningTime `json:"openingTimes,omitempty"`
+	// Only returned by the venue details endpoint.
+	SalesAreas []SalesArea `json:"salesAreas,omitempty"`
+}
+
+// OpeningTime is the opening hours of a venue on one day of the week.
+type OpeningTime struct {
+	Day   string `json:"day"`
+	Open  string `json:"open"`
+	Close string `json:"close"`
+}
+
+// SalesArea is an area of a venue with its own menus, such as a bar or garden.
+type SalesArea struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	CanOrder bool   `json:"canOrder"`
+}
+
+// Address is a physical address.
+type Address struct {
+	Line1    string   `json:"line1"`
+	Line2    *string  `json:"line2"`
+	Line3    *string  `json:"line3"`
+	Town     string   `json:"town"`
+	County   string   `json:"county"`
+	Postcode string   `json:"postcode"`
+	Location Location `json:"location"`
+}
+
+// Location is a pair of geographic coordinates.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Settings is the application configuration.
+type Settings struct {
+	MinVersion string                 `json:"minVersion"`
+	Urls       map[string]string      `json:"urls"`
+	Features   map[string]interface{} `json:"features"`
+}
+
+// Banner is a promotional banner.
+type Banner struct {
+	Campaign string `json:"campaign"`
+	ImageURL string `json:"imageUrl"`
+	URL      string `json:"url"`
+}
+
+// Menu is a menu available in a sales area.
+type Menu struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CanOrder    bool   `json:"canOrder"`
+	// Only set when menus are expanded with their items; the menus endpoint
+	// doesn't return it.
+	Details MenuDetails `json:"details"`
+}
+
+// MenuDetails is the contents of a menu, grouped into categories.
+type MenuDetails struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Categories []Category `json:"categories"`
+}
+
+// Category is a section of a menu.
+type Category struct {
+	ID         int         `json:"id"`
+	Name       string      `json:"name"`
+	Hidden     bool        `json:"hidden"`
+	ItemGroups []ItemGroup `json:"itemGroups"`
+}
+
+// ItemGroup is a group of related items within a category.
+type ItemGroup struct {
+	Description *string `json:"description"`
+	Items       []Item  `json:"items"`
+}
+
+// Item is a product on a menu.
+type Item struct {
+	ID              int         `json:"id"`
+	Name            string      `json:"name"`
+	Description     string      `json:"description"`
+	Calories        int         `json:"calories"`
+	DisplayRecordID int         `json:"displayRecordId"`
+	ItemType        string      `json:"itemType"`
+	IsOutOfStock    bool        `json:"isOutOfStock"`
+	Options         ItemOptions `json:"options"`
+}
+
+// ItemOptions is the set of portions, add-ons and choices available for an
+// item.
+type ItemOptions struct {
+	Portion Portion                  `json:"portion"`
+	AddOns  []map[string]interface{} `json:"addOns"`
+	Choices []map[string]interface{} `json:"choices"`
+}
+
+// Portion is the set of portion sizes an item is sold in.
+type Portion struct {
+	Title   string          `json:"title"`
+	Options []PortionOption `json:"options"`
+}
+
+// PortionOption is a portion size and its price.
+type PortionOption struct {
+	Label string `json:"label"`
+	// The price of the portion.
+	Value PortionOptionValue `json:"value"`
+}
+
+// PortionOptionValue is the price of the portion.
+type PortionOptionValue struct {
+	// A price in pounds.
+	Price PortionOptionValuePrice `json:"price"`
+}
+
+// PortionOptionValuePrice is a price in pounds.
+type PortionOptionValuePrice struct {
+	Value float64 `json:"value"`
+}
+
+// GetVenues returns a comprehensive list of all Wetherspoon venues.
+func (c *Client) GetVenues() ([]Venue, error) {
+	return c.GetVenuesContext(context.Background())
+}
+
+// GetVenuesContext is like GetVenues but uses ctx for the request and as the
+// parent of its trace span.
+func (c *Client) GetVenuesContext(ctx context.Context) ([]Venue, error) {
+	var result []Venue
+	err := c.doRequest(ctx, "GET", "/api/v0.1/venues", nil, &result)
+	return result, err
+}
+
+// GetVenue fetches details for a specific venue by ID.
+func (c *Client) GetVenue(id int) (*Venue, error) {
+	return c.GetVenueContext(context.Background(), id)
+}
+
+// GetVenueContext is like GetVenue but uses ctx for the request and as the
+// parent of its trace span.
+func (c *Client) GetVenueContext(ctx context.Context, id int) (*Venue, error) {
+	var result Venue
+	err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v0.1/jdw/venues/%d", id), nil, &result, AttrVenueRef.Int(id))
+	return &result, err
+}
+
+// GetSalesAreaMenus fetches the menus of a venue's sales area, without their
+// items.
+func (c *Client) GetSalesAreaMenus(id, salesAreaID int) ([]Menu, error) {
+	return c.GetSalesAreaMenusContext(context.Background(), id, salesAreaID)
+}
+
+// GetSalesAreaMenusContext is like GetSalesAreaMenus but uses ctx for the
+// request and as the parent of its trace span.
+func (c *Client) GetSalesAreaMenusContext(ctx context.Context, id, salesAreaID int) ([]Menu, error) {
+	var result []Menu
+	err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v0.1/jdw/venues/%d/sales-areas/%d/menus", id, salesAreaID), nil, &result, AttrVenueRef.Int(id), AttrSalesAreaID.Int(salesAreaID))
+	return result, err
+}
+
+// GetMenu fetches the categories and items of a menu.
+func (c *Client) GetMenu(id, salesAreaID, menuID int) (*MenuDetails, error) {
+	return c.GetMenuContext(context.Background(), id, salesAreaID, menuID)
+}
+
+// GetMenuContext is like GetMenu but uses ctx for the request and as the parent
+// of its trace span.
+func (c *Client) GetMenuContext(ctx context.Context, id, salesAreaID, menuID int) (*MenuDetails, error) {
+	var result MenuDetails
+	err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v0.1/jdw/venues/%d/sales-areas/%d/menus/%d", id, salesAreaID, menuID), nil, &result, AttrVenueRef.Int(id), AttrSalesAreaID.Int(salesAreaID), AttrMenuID.Int(menuID))
+	return &result, err
+}
+
+// GetBanners fetches the promotional banners.
+func (c *Client) GetBanners() ([]Banner, error) {
+	return c.GetBannersContext(context.Background())
+}
+
+// GetBannersContext is like GetBanners but uses ctx for the request and as the
+// parent of its trace span.
+func (c *Client) GetBannersContext(ctx context.Context) ([]Banner, error) {
+	var result []Banner
+	err := c.doRequest(ctx, "GET", "/api/v0.1/content/promotional-banners", nil, &result)
+	return result, err
+}
+
+// GetSettings fetches the application settings.
+func (c *Client) GetSettings() (*Settings, error) {
+	return c.GetSettingsContext(context.Background())
+}
+
+// GetSettingsContext is like GetSettings but uses ctx for the request and as
+// the parent of its trace span.
+func (c *Client) GetSettingsContext(ctx context.Context) (*Settings, error) {
+	var result Settings
+	err := c.doRequest(ctx, "GET", "/api/v0.1/settings", nil, &result)
+	return &result, err
+}
@@ -0,0 +1,34 @@
+package jdw
+
+import "time"
+
+// RequestInfo describes a completed API call, as passed to a RequestObserver.
+type RequestInfo struct {
+	// ID is a random identifier for the call, also used in log output.
+	ID     string
+	Method string
+	Path   string
+	// Endpoint is the endpoint name (see the Endpoint* constants).
+	Endpoint string
+	// StatusCode is the HTTP status of the last response, or 0 when no
+	// response was received (network errors and cache hits).
+	StatusCode int
+	// Cached reports whether the response was served from the cache without
+	// a network request.
+	Cached   bool
+	Duration time.Duration
+	// Err is the error returned to the caller, if any.
+	Err error
+	// SchemaIssues lists the differences between the response body and the
+	// spec, when a SchemaValidator is set and the body came from the network.
+	SchemaIssues []SchemaIssue
+}
+
+// RequestObserver is called after every API call made through the client.
+type RequestObserver func(RequestInfo)
+
+// SetRequestObserver registers fn to be called after every API call, e.g. to
+// record metrics. Pass nil to remove it. fn may be called concurrently.
+func (c *Client) SetRequestObserver(fn RequestObserver) {
+	c.observer = fn
+}
@@ -0,0 +1,98 @@
+package jdw
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures a Client in NewClient.
+type Option func(*Client)
+
+// Middleware wraps the RoundTripper that sends every API request, so it can
+// inspect or modify requests and responses (logging, tracing, header
+// injection, ...). It is called once when the client is configured, not per
+// request.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to an http.RoundTripper, which is
+// convenient for writing Middleware.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithHTTPClient makes the client send requests with hc, e.g. to configure a
+// proxy, TLS settings or cookie jar. hc is not modified by the client.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithTransport sets the RoundTripper used to send requests. It replaces the
+// transport of the HTTP client set with WithHTTPClient, if any.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.setTransport(rt)
+	}
+}
+
+// WithTimeout sets a time limit for each request, including reading the
+// response body. Zero means no timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		hc := *c.httpClient
+		hc.Timeout = d
+		c.httpClient = &hc
+	}
+}
+
+// WithMiddleware adds middleware to the client. The first middleware is the
+// outermost: it sees each request first and each response last.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *Client) {
+		c.middleware = append(c.middleware, mw...)
+	}
+}
+
+// Use adds middleware to an existing client, after (inside) any middleware
+// it already has.
+func (c *Client) Use(mw ...Middleware) {
+	c.middleware = append(c.middleware, mw...)
+	c.buildChain()
+}
+
+// SetTransport replaces the HTTP transport used by the client. Middleware
+// still applies to requests sent through it.
+func (c *Client) SetTransport(rt http.RoundTripper) {
+	c.setTransport(rt)
+	c.buildChain()
+}
+
+func (c *Client) setTransport(rt http.RoundTripper) {
+	hc := *c.httpClient
+	hc.Transport = rt
+	c.httpClient = &hc
+}
+
+// buildChain wraps the HTTP client's transport in the middleware, producing
+// the client used to send requests.
+func (c *Client) buildChain() {
+	if len(c.middleware) == 0 {
+		c.sender = c.httpClient
+		return
+	}
+
+	rt := c.httpClient.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		rt = c.middleware[i](rt)
+	}
+	hc := *c.httpClient
+	hc.Transport = rt
+	c.sender = &hc
+}
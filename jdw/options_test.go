@@ -0,0 +1,138 @@
+package jdw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func settingsServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler != nil {
+			handler(w, r)
+		}
+		if _, err := fmt.Fprint(w, `{"success": true, "data": {"minVersion": "1.0.0"}}`); err != nil {
+			t.Errorf("failed to write mock response: %v", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWithMiddleware(t *testing.T) {
+	server := settingsServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Trace") != "abc" {
+			t.Errorf("Expected X-Trace header 'abc', got '%s'", r.Header.Get("X-Trace"))
+		}
+	})
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name+" request")
+				resp, err := next.RoundTrip(req)
+				calls = append(calls, name+" response")
+				return resp, err
+			})
+		}
+	}
+	injectHeader := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("X-Trace", "abc")
+			return next.RoundTrip(req)
+		})
+	}
+
+	client := NewClient("1.2.3", "test-token", "test-ua", WithMiddleware(record("outer"), injectHeader), WithMiddleware(record("inner")))
+	client.SetBaseURL(server.URL)
+	if _, err := client.GetSettings(); err != nil {
+		t.Fatalf("GetSettings failed: %v", err)
+	}
+
+	want := "outer request,inner request,inner response,outer response"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("Expected calls %q, got %q", want, got)
+	}
+}
+
+func TestUseAfterSetTransport(t *testing.T) {
+	var transportCalls, middlewareCalls int
+	transport := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		transportCalls++
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(`{"success": true, "data": {}}`)),
+			Request:    req,
+		}, nil
+	})
+
+	client := NewClient("1.2.3", "test-token", "test-ua")
+	client.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			middlewareCalls++
+			return next.RoundTrip(req)
+		})
+	})
+	client.SetTransport(transport)
+	if _, err := client.GetSettings(); err != nil {
+		t.Fatalf("GetSettings failed: %v", err)
+	}
+
+	if transportCalls != 1 {
+		t.Errorf("Expected 1 transport call, got %d", transportCalls)
+	}
+	if middlewareCalls != 1 {
+		t.Errorf("Expected 1 middleware call, got %d", middlewareCalls)
+	}
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	server := settingsServer(t, nil)
+
+	var used bool
+	hc := &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		used = true
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+	client := NewClient("1.2.3", "test-token", "test-ua", WithHTTPClient(hc), WithTimeout(time.Second))
+	client.SetBaseURL(server.URL)
+	if _, err := client.GetSettings(); err != nil {
+		t.Fatalf("GetSettings failed: %v", err)
+	}
+
+	if !used {
+		t.Error("Expected the custom HTTP client's transport to be used")
+	}
+	if hc.Timeout != 0 {
+		t.Errorf("Expected WithTimeout not to modify the caller's client, got timeout %v", hc.Timeout)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := settingsServer(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	defer close(release)
+
+	client := NewClient("1.2.3", "test-token", "test-ua", WithTimeout(50*time.Millisecond))
+	client.SetBaseURL(server.URL)
+	_, err := client.GetSettings()
+	if err == nil {
+		t.Fatal("Expected a timeout error, got nil")
+	}
+	var netErr interface{ Timeout() bool }
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+}
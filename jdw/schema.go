@@ -0,0 +1,361 @@
+package jdw
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+// Spec returns the OpenAPI specification the models are generated from.
+func Spec() []byte {
+	return bytes.Clone(spec)
+}
+
+// Kinds of SchemaIssue.
+const (
+	IssueUnknownField    = "unknown_field"
+	IssueMissingRequired = "missing_required"
+	IssueTypeMismatch    = "type_mismatch"
+)
+
+// SchemaIssue is a difference between a response body and its schema in the
+// spec.
+type SchemaIssue struct {
+	// Endpoint is the spec path template, e.g. "/api/v0.1/jdw/venues/{id}".
+	Endpoint string `json:"endpoint"`
+	// Field is the JSON path of the field, e.g. "data[].address.town". Map
+	// entries are written as ".*".
+	Field string `json:"field"`
+	Kind  string `json:"kind"`
+	// Expected and Got are the schema and JSON types of a type mismatch.
+	Expected string `json:"expected,omitempty"`
+	Got      string `json:"got,omitempty"`
+	// Count is the number of times the issue occurred in the body, e.g. once
+	// per array element.
+	Count int `json:"count"`
+}
+
+func (i SchemaIssue) String() string {
+	switch i.Kind {
+	case IssueUnknownField:
+		return fmt.Sprintf("%s: unknown field", i.Field)
+	case IssueMissingRequired:
+		return fmt.Sprintf("%s: missing required field", i.Field)
+	default:
+		return fmt.Sprintf("%s: expected %s, got %s", i.Field, i.Expected, i.Got)
+	}
+}
+
+// schema is the subset of an OpenAPI schema object used for validation.
+type schema struct {
+	Ref        string             `yaml:"$ref"`
+	Type       string             `yaml:"type"`
+	Nullable   bool               `yaml:"nullable"`
+	Properties map[string]*schema `yaml:"properties"`
+	Required   []string           `yaml:"required"`
+	Items      *schema            `yaml:"items"`
+	// AdditionalProperties is a yaml.Node because it may be a boolean or a
+	// schema, and yaml.v3 doesn't decode *yaml.Node fields.
+	AdditionalProperties yaml.Node `yaml:"additionalProperties"`
+}
+
+// route matches request paths to a documented endpoint.
+type route struct {
+	method   string
+	template string
+	pattern  *regexp.Regexp
+	schema   *schema
+}
+
+// SchemaValidator checks response bodies against the 200 application/json
+// response schemas of an OpenAPI spec.
+type SchemaValidator struct {
+	routes  []route
+	schemas map[string]*schema
+}
+
+// NewSchemaValidator parses an OpenAPI spec, such as the one returned by Spec.
+func NewSchemaValidator(specYAML []byte) (*SchemaValidator, error) {
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Responses map[string]struct {
+				Content map[string]struct {
+					Schema *schema `yaml:"schema"`
+				} `yaml:"content"`
+			} `yaml:"responses"`
+		} `yaml:"paths"`
+		Components struct {
+			Schemas map[string]*schema `yaml:"schemas"`
+		} `yaml:"components"`
+	}
+	if err := yaml.Unmarshal(specYAML, &doc); err != nil {
+		return nil, fmt.Errorf("parsing spec: %w", err)
+	}
+
+	v := &SchemaValidator{schemas: doc.Components.Schemas}
+	for template, ops := range doc.Paths {
+		pattern := "^" + regexp.MustCompile(`\\\{[^}]+\\\}`).ReplaceAllString(regexp.QuoteMeta(template), `[^/]+`) + "$"
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %w", template, err)
+		}
+		for method, op := range ops {
+			content, ok := op.Responses["200"].Content["application/json"]
+			if !ok || content.Schema == nil {
+				continue
+			}
+			v.routes = append(v.routes, route{
+				method:   strings.ToUpper(method),
+				template: template,
+				pattern:  re,
+				schema:   content.Schema,
+			})
+		}
+	}
+	// Literal segments are longer than their parameters, so trying the
+	// longest templates first prefers them when more than one matches.
+	sort.Slice(v.routes, func(i, j int) bool {
+		return len(v.routes[i].template) > len(v.routes[j].template)
+	})
+	return v, nil
+}
+
+var defaultValidator = sync.OnceValues(func() (*SchemaValidator, error) {
+	return NewSchemaValidator(spec)
+})
+
+// DefaultSchemaValidator returns a validator for the embedded spec.
+func DefaultSchemaValidator() *SchemaValidator {
+	v, err := defaultValidator()
+	if err != nil {
+		// The embedded spec is checked by the package tests.
+		panic(err)
+	}
+	return v
+}
+
+// Validate checks a response body to method and path, which may include a
+// query string, against its schema. Paths the spec doesn't document, and
+// responses with "success": false, have no issues. Issues are returned in
+// body order, visiting object keys alphabetically, with repeats counted
+// rather than listed.
+func (v *SchemaValidator) Validate(method, path string, body []byte) ([]SchemaIssue, error) {
+	path, _, _ = strings.Cut(path, "?")
+	var r *route
+	for i := range v.routes {
+		if v.routes[i].method == method && v.routes[i].pattern.MatchString(path) {
+			r = &v.routes[i]
+			break
+		}
+	}
+	if r == nil {
+		return nil, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	// Failures have their own envelope, which decoding reports as
+	// ErrAPIFailure rather than drift.
+	if obj, ok := value.(map[string]any); ok && obj["success"] == false {
+		return nil, nil
+	}
+
+	w := walker{v: v, endpoint: r.template, seen: make(map[SchemaIssue]int)}
+	w.walk(value, r.schema, "")
+	return w.issues, nil
+}
+
+// walker accumulates the issues found in a single body.
+type walker struct {
+	v        *SchemaValidator
+	endpoint string
+	issues   []SchemaIssue
+	// seen maps an issue, with a zero Count, to its index in issues.
+	seen map[SchemaIssue]int
+}
+
+func (w *walker) report(field, kind, expected, got string) {
+	key := SchemaIssue{Endpoint: w.endpoint, Field: field, Kind: kind, Expected: expected, Got: got}
+	if i, ok := w.seen[key]; ok {
+		w.issues[i].Count++
+		return
+	}
+	w.seen[key] = len(w.issues)
+	key.Count = 1
+	w.issues = append(w.issues, key)
+}
+
+// resolve follows $ref to a component schema.
+func (w *walker) resolve(s *schema) *schema {
+	for s != nil && s.Ref != "" {
+		s = w.v.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+func (w *walker) walk(value any, s *schema, field string) {
+	s = w.resolve(s)
+	if s == nil {
+		return
+	}
+	typ := s.Type
+	if typ == "" && s.Properties != nil {
+		typ = "object"
+	}
+	if value == nil {
+		if typ != "" && !s.Nullable {
+			w.report(fieldName(field), IssueTypeMismatch, typ, "null")
+		}
+		return
+	}
+
+	switch typ {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			w.report(fieldName(field), IssueTypeMismatch, typ, jsonType(value))
+			return
+		}
+		w.walkObject(obj, s, field)
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			w.report(fieldName(field), IssueTypeMismatch, typ, jsonType(value))
+			return
+		}
+		for _, elem := range arr {
+			w.walk(elem, s.Items, field+"[]")
+		}
+	case "string", "boolean", "number", "integer":
+		if got := jsonType(value); got != typ && !(typ == "number" && got == "integer") {
+			w.report(fieldName(field), IssueTypeMismatch, typ, got)
+		}
+	}
+}
+
+func (w *walker) walkObject(obj map[string]any, s *schema, field string) {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			w.report(joinField(field, name), IssueMissingRequired, "", "")
+		}
+	}
+
+	var extra *schema
+	openEnded := s.Properties == nil
+	if s.AdditionalProperties.Kind == yaml.MappingNode {
+		extra = new(schema)
+		if err := s.AdditionalProperties.Decode(extra); err != nil {
+			extra = nil
+		}
+		openEnded = true
+	} else if s.AdditionalProperties.Kind == yaml.ScalarNode {
+		openEnded = s.AdditionalProperties.Value == "true"
+	}
+
+	// Keys are visited in order so that issues come out in a stable order.
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if prop, ok := s.Properties[k]; ok {
+			w.walk(obj[k], prop, joinField(field, k))
+			continue
+		}
+		switch {
+		case extra != nil:
+			w.walk(obj[k], extra, joinField(field, "*"))
+		case !openEnded:
+			w.report(joinField(field, k), IssueUnknownField, "", "")
+		}
+	}
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// fieldName names the body itself "(root)".
+func fieldName(field string) string {
+	if field == "" {
+		return "(root)"
+	}
+	return field
+}
+
+// jsonType returns the JSON Schema type of a value decoded with UseNumber.
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// WithSchemaValidation validates every response body fetched from the network
+// with v; see SetSchemaValidator.
+func WithSchemaValidation(v *SchemaValidator) Option {
+	return func(c *Client) {
+		c.validator = v
+	}
+}
+
+// SetSchemaValidator validates every successful response body fetched from
+// the network with v, e.g. DefaultSchemaValidator(). Issues are logged as
+// warnings and reported in RequestInfo.SchemaIssues; they don't fail the
+// request. Pass nil to stop validating.
+func (c *Client) SetSchemaValidator(v *SchemaValidator) {
+	c.validator = v
+}
+
+// validate checks a response body, recording any issues in info.
+func (c *Client) validate(method, path string, body []byte, info *RequestInfo) {
+	if c.validator == nil {
+		return
+	}
+	issues, err := c.validator.Validate(method, path, body)
+	if err != nil {
+		// The body is malformed, which decoding reports to the caller.
+		return
+	}
+	info.SchemaIssues = issues
+	if len(issues) > 0 {
+		descs := make([]string, len(issues))
+		for i, issue := range issues {
+			descs[i] = issue.String()
+		}
+		c.log().Warn("jdw schema drift", "request_id", info.ID, "endpoint", info.Endpoint, "path", info.Path, "issues", strings.Join(descs, "; "))
+	}
+}
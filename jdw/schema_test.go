@@ -0,0 +1,161 @@
+package jdw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSchemaValidatorValidate(t *testing.T) {
+	v := DefaultSchemaValidator()
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want []string
+	}{
+		{
+			name: "valid venues",
+			path: "/api/v0.1/venues",
+			body: `{"success": true, "data": [{"id": 1, "venueRef": 7001, "name": "The Moon", "address": {"line2": null, "location": {"latitude": 51.5, "longitude": 0}}}]}`,
+		},
+		{
+			name: "drift across venues",
+			path: "/api/v0.1/venues",
+			body: `{"success": true, "data": [
+				{"id": 1, "venueRef": "7001", "name": "A", "wifi": true},
+				{"id": 2, "venueRef": 7002, "wifi": false, "address": {"town": null}}
+			]}`,
+			want: []string{
+				"data[].venueRef: expected integer, got string",
+				"data[].wifi: unknown field (x2)",
+				"data[].name: missing required field",
+				"data[].address.town: expected string, got null",
+			},
+		},
+		{
+			name: "float where integer expected",
+			path: "/api/v0.1/jdw/venues/7001/sales-areas/301/menus?x=1",
+			body: `{"success": true, "data": [{"id": 1.5, "name": "Food"}]}`,
+			want: []string{"data[].id: expected integer, got number"},
+		},
+		{
+			name: "map values",
+			path: "/api/v0.1/settings",
+			body: `{"success": true, "data": {"urls": {"terms": 3}, "features": {"x": [1]}}}`,
+			want: []string{"data.urls.*: expected string, got integer"},
+		},
+		{
+			name: "missing envelope",
+			path: "/api/v0.1/content/promotional-banners",
+			body: `[]`,
+			want: []string{"(root): expected object, got array"},
+		},
+		{
+			name: "undocumented path",
+			path: "/api/v0.2/unknown",
+			body: `{"anything": true}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues, err := v.Validate(http.MethodGet, tt.path, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("Validate failed: %v", err)
+			}
+			var got []string
+			for _, issue := range issues {
+				s := issue.String()
+				if issue.Count > 1 {
+					s += fmt.Sprintf(" (x%d)", issue.Count)
+				}
+				got = append(got, s)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Expected issues:\n%s\ngot:\n%s", strings.Join(tt.want, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+
+	if _, err := v.Validate(http.MethodGet, "/api/v0.1/venues", []byte("{")); err == nil {
+		t.Error("Expected an error for malformed JSON")
+	}
+}
+
+func TestSchemaValidatorFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "fixtures", "*.json"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("No fixtures found: %v", err)
+	}
+	v := DefaultSchemaValidator()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		var f Fixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			t.Fatalf("Invalid fixture %s: %v", path, err)
+		}
+		issues, err := v.Validate(f.Request.Method, f.Request.Path, f.Response.Body)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+		for _, issue := range issues {
+			t.Errorf("%s: %s", filepath.Base(path), issue)
+		}
+	}
+}
+
+func TestClientSchemaValidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success": true, "data": [{"id": 1, "venueRef": 7001, "name": "The Moon", "rating": 5}]}`)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := NewClient("1.2.3", "test-token", "test-ua",
+		WithSchemaValidation(DefaultSchemaValidator()),
+		WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	client.SetBaseURL(server.URL)
+	var seen []RequestInfo
+	client.SetRequestObserver(func(info RequestInfo) {
+		seen = append(seen, info)
+	})
+
+	venues, err := client.GetVenues()
+	if err != nil {
+		t.Fatalf("Expected drift not to fail the request, got %v", err)
+	}
+	if len(venues) != 1 {
+		t.Errorf("Expected 1 venue, got %d", len(venues))
+	}
+	if len(seen) != 1 || len(seen[0].SchemaIssues) != 1 {
+		t.Fatalf("Expected 1 schema issue, got %+v", seen)
+	}
+	want := SchemaIssue{Endpoint: "/api/v0.1/venues", Field: "data[].rating", Kind: IssueUnknownField, Count: 1}
+	if seen[0].SchemaIssues[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, seen[0].SchemaIssues[0])
+	}
+	if !strings.Contains(buf.String(), `msg="jdw schema drift"`) || !strings.Contains(buf.String(), "data[].rating: unknown field") {
+		t.Errorf("Expected a drift warning, got %q", buf.String())
+	}
+
+	client.SetSchemaValidator(nil)
+	seen = nil
+	if _, err := client.GetVenues(); err != nil {
+		t.Fatalf("GetVenues failed: %v", err)
+	}
+	if len(seen[0].SchemaIssues) != 0 {
+		t.Errorf("Expected no issues without a validator, got %+v", seen[0].SchemaIssues)
+	}
+}
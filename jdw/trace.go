@@ -0,0 +1,76 @@
+package jdw
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name of the client's tracer.
+const TracerName = "github.com/KRoperUK/get_spoons/jdw"
+
+// Span attributes identifying what an API call fetched.
+const (
+	AttrVenueRef    = attribute.Key("jdw.venue_ref")
+	AttrSalesAreaID = attribute.Key("jdw.sales_area_id")
+	AttrMenuID      = attribute.Key("jdw.menu_id")
+)
+
+// spanNames maps endpoints to the client methods that call them.
+var spanNames = map[string]string{
+	EndpointVenues:       "GetVenues",
+	EndpointVenueDetails: "GetVenueDetails",
+	EndpointMenus:        "GetMenus",
+	EndpointMenuItems:    "GetMenuItems",
+	EndpointSettings:     "GetSettings",
+	EndpointBanners:      "GetBanners",
+}
+
+// WithTracerProvider sets the OpenTelemetry tracer provider used to trace
+// API calls. By default the global provider (otel.GetTracerProvider) is used,
+// which records nothing unless one has been installed.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *Client) {
+		c.tracers = tp
+	}
+}
+
+func (c *Client) tracer() trace.Tracer {
+	tp := c.tracers
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(TracerName)
+}
+
+// startSpan starts a client span for an API call. Calls made with the
+// context-less methods start a new trace.
+func (c *Client) startSpan(ctx context.Context, info *RequestInfo, attrs []attribute.KeyValue) (context.Context, trace.Span) {
+	name, ok := spanNames[info.Endpoint]
+	if !ok {
+		name = info.Endpoint
+	}
+	attrs = append(attrs,
+		attribute.String("jdw.endpoint", info.Endpoint),
+		attribute.String("jdw.request_id", info.ID),
+		attribute.String("http.request.method", info.Method),
+		attribute.String("url.path", info.Path),
+	)
+	return c.tracer().Start(ctx, "jdw."+name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endSpan records the outcome of an API call and ends its span.
+func endSpan(span trace.Span, info *RequestInfo) {
+	if info.StatusCode != 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", info.StatusCode))
+	}
+	span.SetAttributes(attribute.Bool("jdw.cached", info.Cached))
+	if info.Err != nil {
+		span.RecordError(info.Err)
+		span.SetStatus(codes.Error, info.Err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,78 @@
+package jdw
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTracing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v0.1/jdw/venues/10/sales-areas/20/menus" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"success": true, "data": {}}`)
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client := NewClient("1.2.3", "test-token", "test-ua", WithTracerProvider(tp))
+	client.SetBaseURL(server.URL)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "crawl")
+	if _, err := client.GetMenuItemsContext(ctx, 10, 20, 30); err != nil {
+		t.Fatalf("GetMenuItemsContext failed: %v", err)
+	}
+	if _, err := client.GetMenusContext(ctx, 10, 20); err == nil {
+		t.Fatal("Expected GetMenusContext to fail")
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+
+	items, menus := spans[0], spans[1]
+	if items.Name() != "jdw.GetMenuItems" || menus.Name() != "jdw.GetMenus" {
+		t.Errorf("Unexpected span names %q and %q", items.Name(), menus.Name())
+	}
+	for _, s := range []sdktrace.ReadOnlySpan{items, menus} {
+		if s.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("Expected %s to be a child of the crawl span", s.Name())
+		}
+	}
+	if v, _ := spanAttr(items, AttrMenuID); v.AsInt64() != 30 {
+		t.Errorf("Expected menu id 30, got %v", v.Emit())
+	}
+	if v, _ := spanAttr(items, AttrSalesAreaID); v.AsInt64() != 20 {
+		t.Errorf("Expected sales area 20, got %v", v.Emit())
+	}
+	if v, _ := spanAttr(items, AttrVenueRef); v.AsInt64() != 10 {
+		t.Errorf("Expected venue ref 10, got %v", v.Emit())
+	}
+	if v, _ := spanAttr(menus, "http.response.status_code"); v.AsInt64() != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %v", v.Emit())
+	}
+	if menus.Status().Code != codes.Error {
+		t.Errorf("Expected an error status, got %v", menus.Status())
+	}
+}
@@ -0,0 +1,153 @@
+package jdw
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CompareVersions compares two semantic versions such as "6.7.1" and
+// returns -1, 0 or 1 when a is lower than, equal to or higher than b. A
+// leading "v" is ignored, missing minor and patch numbers count as zero and
+// build metadata ("+...") is ignored. A pre-release ("6.8.0-beta.1") is
+// lower than its release, and pre-release identifiers are compared as in
+// semver 2.0.
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range va.core {
+		if c := compareInts(va.core[i], vb.core[i]); c != 0 {
+			return c, nil
+		}
+	}
+	return comparePrerelease(va.pre, vb.pre), nil
+}
+
+type version struct {
+	core [3]int
+	pre  []string
+}
+
+func parseVersion(s string) (version, error) {
+	var v version
+	raw := s
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		if pre == "" {
+			return v, fmt.Errorf("invalid version %q", raw)
+		}
+		v.pre = strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q", raw)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", raw)
+		}
+		v.core[i] = n
+	}
+	return v, nil
+}
+
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, errA := strconv.Atoi(a[i])
+		nb, errB := strconv.Atoi(b[i])
+		switch {
+		case errA == nil && errB == nil:
+			if c := compareInts(na, nb); c != 0 {
+				return c
+			}
+		case errA == nil:
+			return -1 // numeric identifiers sort before alphanumeric ones
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInts(len(a), len(b))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// VersionCheck is the result of comparing the client's App-Version with the
+// minimum version the API accepts.
+type VersionCheck struct {
+	// Configured is the App-Version the client was created with.
+	Configured string
+	// Minimum is Settings.MinVersion.
+	Minimum string
+	// Compatible reports whether Configured is at least Minimum.
+	Compatible bool
+	// Bumped reports whether the client now sends Minimum instead.
+	Bumped bool
+}
+
+// AppVersion returns the App-Version header sent with each request.
+func (c *Client) AppVersion() string {
+	return c.appVersion
+}
+
+// SetAppVersion changes the App-Version header sent with each request.
+func (c *Client) SetAppVersion(v string) {
+	c.appVersion = v
+}
+
+// NegotiateAppVersion fetches the settings and compares the client's
+// App-Version with MinVersion. When the configured version is too old and
+// bump is true, the client switches to sending MinVersion.
+func (c *Client) NegotiateAppVersion(bump bool) (*VersionCheck, error) {
+	settings, err := c.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	check := &VersionCheck{Configured: c.appVersion, Minimum: settings.MinVersion, Compatible: true}
+	if settings.MinVersion == "" {
+		return check, nil
+	}
+
+	cmp, err := CompareVersions(c.appVersion, settings.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	if cmp < 0 {
+		check.Compatible = false
+		if bump {
+			c.appVersion = settings.MinVersion
+			check.Bumped = true
+		}
+	}
+	return check, nil
+}
@@ -0,0 +1,82 @@
+package jdw
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"6.7.1", "6.7.1", 0},
+		{"6.7.1", "6.10.0", -1},
+		{"6.10.0", "6.9.9", 1},
+		{"v6.7", "6.7.0", 0},
+		{"7", "6.99.99", 1},
+		{"6.8.0-beta.1", "6.8.0", -1},
+		{"6.8.0-beta.2", "6.8.0-beta.10", -1},
+		{"6.8.0-1", "6.8.0-alpha", -1},
+		{"6.8.0-alpha", "6.8.0-alpha.1", -1},
+		{"6.8.0+build.5", "6.8.0", 0},
+	}
+	for _, tt := range tests {
+		got, err := CompareVersions(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("CompareVersions(%q, %q) failed: %v", tt.a, tt.b, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("CompareVersions(%q, %q): expected %d, got %d", tt.a, tt.b, tt.want, got)
+		}
+	}
+
+	for _, bad := range []string{"", "six", "6.7.1.2", "6.-1", "6.7.1-"} {
+		if _, err := CompareVersions(bad, "6.7.1"); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestNegotiateAppVersion(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("App-Version"))
+		fmt.Fprint(w, `{"success": true, "data": {"minVersion": "6.10.0"}}`)
+	}))
+	defer server.Close()
+
+	client := NewClient("6.7.1", "test-token", "test-ua")
+	client.SetBaseURL(server.URL)
+
+	check, err := client.NegotiateAppVersion(false)
+	if err != nil {
+		t.Fatalf("NegotiateAppVersion failed: %v", err)
+	}
+	if check.Compatible || check.Bumped || check.Minimum != "6.10.0" || client.AppVersion() != "6.7.1" {
+		t.Errorf("Unexpected check without bump: %+v (sending %s)", check, client.AppVersion())
+	}
+
+	check, err = client.NegotiateAppVersion(true)
+	if err != nil {
+		t.Fatalf("NegotiateAppVersion failed: %v", err)
+	}
+	if !check.Bumped || client.AppVersion() != "6.10.0" {
+		t.Errorf("Expected the client to bump to 6.10.0, got %+v (sending %s)", check, client.AppVersion())
+	}
+
+	if _, err := client.GetSettings(); err != nil {
+		t.Fatalf("GetSettings failed: %v", err)
+	}
+	if seen[len(seen)-1] != "6.10.0" {
+		t.Errorf("Expected the bumped App-Version header, got %s", seen[len(seen)-1])
+	}
+
+	check, _ = client.NegotiateAppVersion(true)
+	if !check.Compatible || check.Bumped {
+		t.Errorf("Expected a compatible version after bumping, got %+v", check)
+	}
+}